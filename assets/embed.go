@@ -1,19 +1,60 @@
 // Package assets provides embedded template files for elmos.
 package assets
 
-import "embed"
+import (
+	"embed"
+	"io/fs"
+	"path"
+	"strings"
+)
 
 //go:embed templates/*
 var Templates embed.FS
 
-// GetModuleTemplate returns the module source template.
-func GetModuleTemplate() ([]byte, error) {
-	return Templates.ReadFile("templates/module/module.c.tmpl")
+//go:embed arches/*.yaml
+var Arches embed.FS
+
+//go:embed fragments/*.config
+var Fragments embed.FS
+
+// moduleTemplatesDir holds one directory per module template.
+const moduleTemplatesDir = "templates/module"
+
+// GetModuleTemplateNames returns the names of the embedded module templates.
+func GetModuleTemplateNames() ([]string, error) {
+	entries, err := Templates.ReadDir(moduleTemplatesDir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
 }
 
-// GetModuleMakefile returns the module Makefile template.
-func GetModuleMakefile() ([]byte, error) {
-	return Templates.ReadFile("templates/module/Makefile.tmpl")
+// GetModuleTemplate returns the files of an embedded module template keyed by
+// their slash-separated path within the template.
+func GetModuleTemplate(name string) (map[string][]byte, error) {
+	root := path.Join(moduleTemplatesDir, name)
+	files := make(map[string][]byte)
+	err := fs.WalkDir(Templates, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := Templates.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[strings.TrimPrefix(p, root+"/")] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
 }
 
 // GetAppTemplate returns the app source template.
@@ -40,3 +81,37 @@ func GetGuestSync() ([]byte, error) {
 func GetConfigTemplate() ([]byte, error) {
 	return Templates.ReadFile("templates/configs/elmos.yaml.tmpl")
 }
+
+// GetArchDefinitions returns the embedded architecture definitions keyed by file name.
+func GetArchDefinitions() (map[string][]byte, error) {
+	entries, err := Arches.ReadDir("arches")
+	if err != nil {
+		return nil, err
+	}
+	defs := make(map[string][]byte, len(entries))
+	for _, e := range entries {
+		data, err := Arches.ReadFile(path.Join("arches", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		defs[e.Name()] = data
+	}
+	return defs, nil
+}
+
+// GetFragments returns the embedded kernel config fragments keyed by file name.
+func GetFragments() (map[string][]byte, error) {
+	entries, err := Fragments.ReadDir("fragments")
+	if err != nil {
+		return nil, err
+	}
+	frags := make(map[string][]byte, len(entries))
+	for _, e := range entries {
+		data, err := Fragments.ReadFile(path.Join("fragments", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		frags[e.Name()] = data
+	}
+	return frags, nil
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/NguyenTrongPhuc552003/elmos/core/config"
 	elcontext "github.com/NguyenTrongPhuc552003/elmos/core/context"
 	"github.com/NguyenTrongPhuc552003/elmos/core/domain/builder"
+	"github.com/NguyenTrongPhuc552003/elmos/core/domain/cache"
 	"github.com/NguyenTrongPhuc552003/elmos/core/domain/doctor"
 	"github.com/NguyenTrongPhuc552003/elmos/core/domain/emulator"
 	"github.com/NguyenTrongPhuc552003/elmos/core/domain/patch"
@@ -28,15 +29,19 @@ type App struct {
 	KernelBuilder    *builder.KernelBuilder
 	ModuleBuilder    *builder.ModuleBuilder
 	AppBuilder       *builder.AppBuilder
+	Watcher          *builder.Watcher
 	QEMURunner       *emulator.QEMURunner
 	HealthChecker    *doctor.HealthChecker
 	AutoFixer        *doctor.AutoFixer
 	RootfsCreator    *rootfs.Creator
 	PatchManager     *patch.Manager
 	ToolchainManager *toolchain.Manager
+	CacheManager     *cache.Manager
 	Printer          *ui.Printer
 	Verbose          bool
 	ConfigFile       string
+	Profile          string
+	Output           string
 }
 
 // New creates a new App with all dependencies wired up.
@@ -44,21 +49,26 @@ func New(exec executor.Executor, fs filesystem.FileSystem, cfg *config.Config) *
 	ctx := elcontext.New(cfg, exec, fs)
 	printer := ui.NewPrinter()
 	tm := toolchain.NewManager(exec, fs, cfg, printer)
+	cm := cache.NewManager(exec, fs, cfg, ctx)
+	mb := builder.NewModuleBuilder(exec, fs, cfg, ctx, tm)
+	ab := builder.NewAppBuilder(exec, fs, cfg, ctx, tm)
 
 	return &App{
 		Exec:             exec,
 		FS:               fs,
 		Config:           cfg,
 		Context:          ctx,
-		KernelBuilder:    builder.NewKernelBuilder(exec, fs, cfg, ctx, tm),
-		ModuleBuilder:    builder.NewModuleBuilder(exec, fs, cfg, ctx, tm),
-		AppBuilder:       builder.NewAppBuilder(exec, fs, cfg, ctx, tm),
+		KernelBuilder:    builder.NewKernelBuilder(exec, fs, cfg, ctx, tm, cm),
+		ModuleBuilder:    mb,
+		AppBuilder:       ab,
+		Watcher:          builder.NewWatcher(fs, cfg, ctx, mb, ab),
 		QEMURunner:       emulator.NewQEMURunner(exec, fs, cfg, ctx),
 		HealthChecker:    doctor.NewHealthChecker(exec, fs, cfg, tm),
 		AutoFixer:        doctor.NewAutoFixer(fs, cfg),
 		RootfsCreator:    rootfs.NewCreator(exec, fs, cfg),
 		PatchManager:     patch.NewManager(exec, fs, cfg),
 		ToolchainManager: tm,
+		CacheManager:     cm,
 		Printer:          printer,
 	}
 }
@@ -79,6 +89,11 @@ Common workflow:
   elmos tui               # Launch interactive TUI`,
 		Version: version.Get().String(),
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			format, err := ui.ParseOutputFormat(a.Output)
+			if err != nil {
+				return err
+			}
+			a.Printer.SetFormat(format)
 			if cmd.Name() == "version" || cmd.Name() == "help" || cmd.Name() == "completion" || cmd.Name() == "tui" || cmd.Name() == "init" {
 				return nil
 			}
@@ -91,6 +106,12 @@ Common workflow:
 				// update the struct contents so pointers passed to builders remain valid
 				*a.Config = *newCfg
 			}
+			// Apply a named profile for this invocation only
+			if a.Profile != "" {
+				if err := a.Config.OverlayProfile(a.Profile); err != nil {
+					return err
+				}
+			}
 			a.Context.Verbose = a.Verbose
 			return nil
 		},
@@ -98,6 +119,8 @@ Common workflow:
 
 	rootCmd.PersistentFlags().BoolVarP(&a.Verbose, "verbose", "e", false, "enable verbose output")
 	rootCmd.PersistentFlags().StringVarP(&a.ConfigFile, "config", "c", "", "config file (default is elmos.yaml)")
+	rootCmd.PersistentFlags().StringVarP(&a.Profile, "profile", "p", "", "apply a named config profile for this command")
+	rootCmd.PersistentFlags().StringVarP(&a.Output, "output", "o", "text", "output format for status and list commands (text, json, yaml)")
 
 	// Create command context and register all commands
 	cmdCtx := &commands.Context{
@@ -108,15 +131,18 @@ Common workflow:
 		KernelBuilder:    a.KernelBuilder,
 		ModuleBuilder:    a.ModuleBuilder,
 		AppBuilder:       a.AppBuilder,
+		Watcher:          a.Watcher,
 		QEMURunner:       a.QEMURunner,
 		HealthChecker:    a.HealthChecker,
 		AutoFixer:        a.AutoFixer,
 		RootfsCreator:    a.RootfsCreator,
 		PatchManager:     a.PatchManager,
 		ToolchainManager: a.ToolchainManager,
+		CacheManager:     a.CacheManager,
 		Printer:          a.Printer,
 		Verbose:          &a.Verbose,
 		ConfigFile:       &a.ConfigFile,
+		Profile:          &a.Profile,
 	}
 
 	commands.Register(cmdCtx, rootCmd)
@@ -2,6 +2,8 @@ package commands
 
 import (
 	"github.com/spf13/cobra"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/domain/builder"
 )
 
 // BuildApps creates the app command tree for userspace application management.
@@ -11,22 +13,33 @@ func BuildApps(ctx *Context) *cobra.Command {
 		Short: "Manage userspace applications",
 	}
 
+	var buildOpts builder.TargetBuildOptions
 	buildCmd := &cobra.Command{
 		Use:   "build [name]",
 		Short: "Build apps",
+		Long: `Build all apps, or one app.
+
+Apps are built in parallel (--jobs, default build.jobs), with each app's
+output written to <workspace>/logs/app-<name>.log. Apps without a Makefile
+are skipped when their binary is newer than their sources, unless --force
+is given; apps with a Makefile always run make.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			name := ""
 			if len(args) > 0 {
 				name = args[0]
 			}
 			ctx.Printer.Step("Building apps...")
-			if err := ctx.AppBuilder.Build(cmd.Context(), name); err != nil {
+			err := runTargetBuild(ctx, "app", buildOpts, func(opts builder.TargetBuildOptions) (*builder.BuildReport, error) {
+				return ctx.AppBuilder.BuildWithOptions(cmd.Context(), name, opts)
+			})
+			if err != nil {
 				return err
 			}
 			ctx.Printer.Success("Apps built!")
 			return nil
 		},
 	}
+	addTargetBuildFlags(buildCmd, &buildOpts)
 
 	listCmd := &cobra.Command{
 		Use:   "list",
@@ -36,6 +49,9 @@ func BuildApps(ctx *Context) *cobra.Command {
 			if err != nil {
 				return err
 			}
+			if ctx.Printer.Structured() {
+				return ctx.Printer.Emit(map[string]interface{}{"apps": nonNil(apps)})
+			}
 			if len(apps) == 0 {
 				ctx.Printer.Info("No apps found")
 				return nil
@@ -11,14 +11,20 @@ import (
 
 // BuildArch creates the arch command for architecture management.
 func BuildArch(ctx *Context) *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "arch [target]",
 		Short: "Set or show target architecture",
 		Long: `Manage target architecture for cross-compilation.
 
+Architectures are built in or declared in YAML, either under "arches:" in
+elmos.yaml or as *.yaml files in an arches.d/ directory (next to elmos.yaml,
+in the project root or in ~/.config/elmos). A definition may inherit from
+another with "base:" and only override what differs.
+
 Examples:
   elmos arch           # Show current config (or init if none)
   elmos arch arm64     # Set architecture to arm64
+  elmos arch list      # List all available architectures
   elmos arch show      # Show detailed configuration`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if len(args) == 0 {
@@ -31,6 +37,43 @@ Examples:
 			return setArchTarget(ctx, cmd, target)
 		},
 	}
+	cmd.AddCommand(buildArchListCmd(ctx))
+	return cmd
+}
+
+// buildArchListCmd creates the arch list subcommand.
+func buildArchListCmd(ctx *Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List available architectures with their QEMU and toolchain settings",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx.Printer.Print("  %-14s %-18s %-24s %-20s %-11s %-8s %-36s %s",
+				"NAME", "KERNEL", "QEMU", "MACHINE", "CPU", "CONSOLE", "TOOLCHAIN", "SOURCE")
+			for _, name := range config.SupportedArchitectures() {
+				a := config.GetArchConfig(name)
+				marker := " "
+				if name == ctx.Config.Build.Arch {
+					marker = "*"
+				}
+				ctx.Printer.Print("%s %-14s %-18s %-24s %-20s %-11s %-8s %-36s %s", marker, name,
+					a.KernelArch+"/"+a.KernelImage, a.QEMUBinary, a.QEMUMachine, valueOrDash(a.QEMUCPU),
+					a.Console, valueOrDash(a.GCCBinary), a.Source)
+			}
+			for _, err := range config.ArchDefinitionErrors() {
+				ctx.Printer.Warn("Skipped invalid definition: %v", err)
+			}
+			return nil
+		},
+	}
+}
+
+// valueOrDash returns s, or "-" when it is empty.
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
 }
 
 // --- Helper functions to reduce BuildArch complexity ---
@@ -65,7 +108,7 @@ func showArchConfig(ctx *Context) error {
 	ctx.Printer.Print("  LLVM:          %v", ctx.Config.Build.LLVM)
 	ctx.Printer.Print("  Memory:        %s", ctx.Config.QEMU.Memory)
 	ctx.Printer.Print("  Project Root:  %s", ctx.Config.Paths.ProjectRoot)
-	ctx.Printer.Print("  Volume:        %s", ctx.Config.Image.MountPoint)
+	ctx.Printer.Print("  Volume:        %s (%s)", ctx.Config.Image.MountPoint, ctx.Config.Image.Backend)
 	ctx.Printer.Print("  Config File:   %s", ctx.Config.ConfigFile)
 	return nil
 }
@@ -108,8 +151,15 @@ func resolveArchAndToolchain(target string) (archName, toolchainTarget string) {
 
 // inferArchFromToolchain guesses architecture from toolchain name.
 func inferArchFromToolchain(target string) string {
+	for _, name := range config.SupportedArchitectures() {
+		if a := config.GetArchConfig(name); a.GCCBinary == target+"-gcc" && a.Base == "" {
+			return name
+		}
+	}
 	if containsIgnoreCase(target, "riscv") {
 		return "riscv"
+	} else if containsIgnoreCase(target, "x86_64") || containsIgnoreCase(target, "amd64") {
+		return "x86_64"
 	} else if containsIgnoreCase(target, "arm64") || containsIgnoreCase(target, "aarch64") {
 		return "arm64"
 	} else if containsIgnoreCase(target, "arm") {
@@ -120,12 +170,10 @@ func inferArchFromToolchain(target string) string {
 
 // saveArchConfig saves the architecture configuration to file.
 func saveArchConfig(ctx *Context, archName string) error {
-	ctx.Config.Build.Arch = archName
-	configPath := ctx.Config.ConfigFile
-	if configPath == "" {
-		configPath = filepath.Join(ctx.Config.Paths.ProjectRoot, "elmos.yaml")
+	if err := ctx.Config.SetValue("build.arch", archName); err != nil {
+		return err
 	}
-	if err := ctx.Config.Save(configPath); err != nil {
+	if err := ctx.Config.Save(resolveConfigPath(ctx)); err != nil {
 		return err
 	}
 	ctx.Printer.Success("Architecture set to: %s", archName)
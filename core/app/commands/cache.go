@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/domain/cache"
+)
+
+// BuildCache creates the cache command tree for compiler cache management.
+func BuildCache(ctx *Context) *cobra.Command {
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the compiler cache (ccache/sccache)",
+		Long: `Manage the compiler cache used by kernel, module and app builds.
+
+Kernel builds pass CC="ccache clang" to make and apps wrap the cross GCC,
+so rebuilds after switching kernel versions or architectures reuse earlier
+object files. The cache lives on the workspace volume (cache.dir, default
+<mount_point>/cache). Set cache.tool to ccache, sccache or none to override
+the automatic choice.
+
+Examples:
+  elmos cache stats       # Hit rate and size
+  elmos cache limit 20G   # Set the maximum size
+  elmos cache clear       # Empty the cache`,
+	}
+
+	cacheCmd.AddCommand(
+		buildCacheStatsCmd(ctx),
+		buildCacheClearCmd(ctx),
+		buildCacheLimitCmd(ctx),
+	)
+	return cacheCmd
+}
+
+// buildCacheStatsCmd creates the cache stats subcommand.
+func buildCacheStatsCmd(ctx *Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "stats",
+		Short: "Show compiler cache hit rate and size",
+		Args:  cobra.NoArgs,
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			stats, err := ctx.CacheManager.Stats(cmd.Context())
+			if err != nil {
+				return err
+			}
+			printCacheStats(ctx, stats)
+			return nil
+		}),
+	}
+}
+
+// buildCacheClearCmd creates the cache clear subcommand.
+func buildCacheClearCmd(ctx *Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "clear",
+		Short: "Empty the compiler cache",
+		Args:  cobra.NoArgs,
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			ctx.Printer.Step("Clearing %s cache...", ctx.CacheManager.Tool())
+			if err := ctx.CacheManager.Clear(cmd.Context()); err != nil {
+				return err
+			}
+			ctx.Printer.Success("Compiler cache cleared")
+			return nil
+		}),
+	}
+}
+
+// buildCacheLimitCmd creates the cache limit subcommand.
+func buildCacheLimitCmd(ctx *Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "limit <size>",
+		Short: "Set the maximum compiler cache size (e.g. 20G)",
+		Args:  cobra.ExactArgs(1),
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			if err := ctx.CacheManager.SetLimit(cmd.Context(), args[0]); err != nil {
+				return err
+			}
+			if err := ctx.Config.Save(resolveConfigPath(ctx)); err != nil {
+				return err
+			}
+			ctx.Printer.Success("Compiler cache limit set to %s", args[0])
+			return nil
+		}),
+	}
+}
+
+// printCacheStats prints compiler cache statistics.
+func printCacheStats(ctx *Context, stats *cache.Stats) {
+	ctx.Printer.Print("  Tool:     %s", stats.Tool)
+	ctx.Printer.Print("  Dir:      %s", stats.Dir)
+	ctx.Printer.Print("  Hit rate: %.1f%% (%d hits, %d misses)", stats.HitRate(), stats.Hits, stats.Misses)
+	if stats.MaxSize != "" {
+		ctx.Printer.Print("  Size:     %s of %s", cache.FormatSize(stats.Size), stats.MaxSize)
+	} else {
+		ctx.Printer.Print("  Size:     %s", cache.FormatSize(stats.Size))
+	}
+}
@@ -0,0 +1,156 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/config"
+)
+
+// BuildConfig creates the config command tree for reading and writing elmos.yaml.
+func BuildConfig(ctx *Context) *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Get, set, edit and validate configuration",
+		Long: `Read and write elmos.yaml settings using dotted keys.
+
+Short aliases are accepted for common keys: arch, jobs, llvm,
+cross_compile, fragments, memory, smp, gdb_port, ssh_port, backend, size.
+
+Examples:
+  elmos config get                     # Show all settings
+  elmos config get qemu.memory         # Show one setting
+  elmos config set arch riscv          # Same as build.arch
+  elmos config set build.fragments debug kasan
+  elmos config set profiles.dbg.memory 4G
+  elmos config edit                    # Open in $EDITOR
+  elmos config validate                # Check for unknown keys and bad values`,
+	}
+
+	configCmd.AddCommand(
+		buildConfigGetCmd(ctx),
+		buildConfigSetCmd(ctx),
+		buildConfigEditCmd(ctx),
+		buildConfigValidateCmd(ctx),
+	)
+	return configCmd
+}
+
+// buildConfigGetCmd creates the config get subcommand.
+func buildConfigGetCmd(ctx *Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get [key]",
+		Short: "Show a setting (or all settings)",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				for _, s := range ctx.Config.Settings() {
+					ctx.Printer.Print("%-28s %s", s.Key, s.Value)
+				}
+				return nil
+			}
+			value, err := ctx.Config.GetValue(args[0])
+			if err != nil {
+				return err
+			}
+			ctx.Printer.Print("%s", value)
+			return nil
+		},
+	}
+}
+
+// buildConfigSetCmd creates the config set subcommand.
+func buildConfigSetCmd(ctx *Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <key> <value> [value...]",
+		Short: "Change a setting and save it",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key := config.ResolveKey(args[0])
+			if err := ctx.Config.SetValue(key, args[1:]...); err != nil {
+				return err
+			}
+			if err := ctx.Config.Save(resolveConfigPath(ctx)); err != nil {
+				return err
+			}
+			value, _ := ctx.Config.GetValue(key)
+			ctx.Printer.Success("%s = %s", key, value)
+			return nil
+		},
+	}
+}
+
+// buildConfigEditCmd creates the config edit subcommand.
+func buildConfigEditCmd(ctx *Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "edit",
+		Short: "Open elmos.yaml in $EDITOR and validate it",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := resolveConfigPath(ctx)
+			if !ctx.FS.Exists(path) {
+				if err := ctx.Config.Save(path); err != nil {
+					return err
+				}
+			}
+
+			editor := os.Getenv("VISUAL")
+			if editor == "" {
+				editor = os.Getenv("EDITOR")
+			}
+			if editor == "" {
+				editor = "vi"
+			}
+			// Allow editors with arguments, e.g. EDITOR="code --wait"
+			parts := strings.Fields(editor)
+			if err := ctx.Exec.Run(cmd.Context(), parts[0], append(parts[1:], path)...); err != nil {
+				return fmt.Errorf("editor failed: %w", err)
+			}
+			return validateConfigFile(ctx, path)
+		},
+	}
+}
+
+// buildConfigValidateCmd creates the config validate subcommand.
+func buildConfigValidateCmd(ctx *Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate [file]",
+		Short: "Check elmos.yaml for unknown keys and invalid values",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := resolveConfigPath(ctx)
+			if len(args) > 0 {
+				path = args[0]
+			}
+			return validateConfigFile(ctx, path)
+		},
+	}
+}
+
+// validateConfigFile validates a config file and prints any issues found.
+func validateConfigFile(ctx *Context, path string) error {
+	if !ctx.FS.Exists(path) {
+		return fmt.Errorf("config file not found: %s", path)
+	}
+	issues, err := config.ValidateFile(path)
+	if err != nil {
+		return err
+	}
+	// Definitions from arches.d are loaded alongside the config
+	for _, archErr := range config.ArchDefinitionErrors() {
+		if archErr.Source != config.ArchSourceConfig {
+			ctx.Printer.Warn("Skipped invalid architecture definition: %v", archErr)
+		}
+	}
+	if len(issues) == 0 {
+		ctx.Printer.Success("Config is valid: %s", path)
+		return nil
+	}
+	ctx.Printer.Error("Found %d issue(s) in %s:", len(issues), path)
+	for _, issue := range issues {
+		ctx.Printer.Print("  %-28s %s", issue.Key, issue.Message)
+	}
+	return fmt.Errorf("config validation failed")
+}
@@ -4,14 +4,30 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/domain/doctor"
 )
 
+// doctorReport is the structured form of 'elmos doctor'.
+type doctorReport struct {
+	Checks []doctor.CheckResult `json:"checks" yaml:"checks"`
+	Issues int                  `json:"issues" yaml:"issues"` // Failed required checks left after auto-fixes
+}
+
 // BuildDoctor creates the doctor command for environment checking.
 func BuildDoctor(ctx *Context) *cobra.Command {
 	return &cobra.Command{
 		Use:   "doctor",
 		Short: "Check environment and dependencies",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if ctx.Printer.Structured() {
+				results, issues := ctx.HealthChecker.CheckAll(cmd.Context())
+				if ctx.AutoFixer.CanFixElfH() && ctx.AutoFixer.FixElfH() == nil {
+					issues--
+				}
+				return ctx.Printer.Emit(doctorReport{Checks: results, Issues: issues})
+			}
+
 			ctx.Printer.Info("ELMOS Doctor - Environment Check")
 			ctx.Printer.Print("")
 			results, issues := ctx.HealthChecker.CheckAll(cmd.Context())
@@ -1,10 +1,8 @@
 package commands
 
 import (
-	"context"
 	"fmt"
-	"regexp"
-	"strings"
+	"path/filepath"
 
 	"github.com/NguyenTrongPhuc552003/elmos/core/config"
 	"github.com/NguyenTrongPhuc552003/elmos/core/ui"
@@ -22,6 +20,11 @@ Arguments:
   workspace_name  Optional name for the workspace volume (default: "elmos")
   size           Optional volume size (default: "40G", minimum: 40G)
 
+The volume backend is chosen by image.backend in elmos.yaml:
+  hdiutil  Case-sensitive APFS sparse image (macOS default)
+  loop     ext4 image attached through a loop device (Linux, needs sudo)
+  dir      Plain directory under data/volumes/ (Linux default)
+
 Examples:
   elmos init                    # Create /Volumes/elmos/ with 40GB
   elmos init my_workspace       # Create /Volumes/my_workspace/ with 40GB
@@ -69,31 +72,27 @@ func runInit(ctx *Context, cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-// ensureWorkspaceVolume creates and mounts the disk image if needed.
+// ensureWorkspaceVolume creates and mounts the workspace volume if needed.
 func ensureWorkspaceVolume(ctx *Context, cmd *cobra.Command) error {
-	// Create disk image if it doesn't exist
-	if !ctx.FS.Exists(ctx.Config.Image.Path) {
-		ctx.Printer.Step("Creating sparse disk image...")
-		if err := ctx.Exec.Run(cmd.Context(), "hdiutil", "create",
-			"-size", ctx.Config.Image.Size,
-			"-fs", "Case-sensitive APFS",
-			"-volname", ctx.Config.Image.VolumeName,
-			"-type", "SPARSE",
-			ctx.Config.Image.Path,
-		); err != nil {
-			return fmt.Errorf("failed to create disk image: %w", err)
+	vol, err := ctx.AppContext.Volume()
+	if err != nil {
+		return err
+	}
+
+	// Create backing storage if it doesn't exist
+	if !vol.Exists() {
+		ctx.Printer.Step("Creating %s workspace volume...", vol.Backend())
+		if err := vol.Create(cmd.Context()); err != nil {
+			return err
 		}
-		ctx.Printer.Success("Disk image created!")
+		ctx.Printer.Success("Workspace volume created!")
 	}
 
 	// Mount volume if not already mounted
-	if !ctx.AppContext.IsMounted() {
+	if !vol.IsMounted() {
 		ctx.Printer.Step("Mounting volume...")
-		if err := ctx.Exec.Run(cmd.Context(), "hdiutil", "attach",
-			"-mountpoint", ctx.Config.Image.MountPoint,
-			ctx.Config.Image.Path,
-		); err != nil {
-			return fmt.Errorf("failed to mount: %w", err)
+		if err := vol.Mount(cmd.Context()); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -113,10 +112,11 @@ func updateInitConfig(ctx *Context, workspaceName, volumeSize string) error {
 	}
 
 	// Update derived paths
-	ctx.Config.Image.MountPoint = fmt.Sprintf("/Volumes/%s", workspaceName)
-	ctx.Config.Image.Path = fmt.Sprintf("%s/data/%s.sparseimage",
-		ctx.Config.Paths.ProjectRoot, workspaceName)
-	ctx.Config.Paths.ToolchainsDir = fmt.Sprintf("/Volumes/%s/toolchains", workspaceName)
+	backend := ctx.Config.Image.Backend
+	root := ctx.Config.Paths.ProjectRoot
+	ctx.Config.Image.MountPoint = config.DefaultMountPoint(root, workspaceName, backend)
+	ctx.Config.Image.Path = config.DefaultImagePath(root, workspaceName, backend)
+	ctx.Config.Paths.ToolchainsDir = filepath.Join(ctx.Config.Image.MountPoint, "toolchains")
 
 	// Determine config file path
 	configPath := ctx.Config.ConfigFile
@@ -178,26 +178,17 @@ func BuildExit(ctx *Context) *cobra.Command {
 		Use:   "exit",
 		Short: "Exit workspace (unmount volume)",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if !ctx.AppContext.IsMounted() {
+			vol, err := ctx.AppContext.Volume()
+			if err != nil {
+				return err
+			}
+			if !vol.IsMounted() {
 				ctx.Printer.Info("Volume not mounted")
 				return nil
 			}
 			ctx.Printer.Step("Unmounting volume...")
-
-			// Find the disk device for our image from hdiutil info
-			diskDevice, err := findDiskDevice(ctx)
-			if err != nil {
-				return fmt.Errorf("failed to find disk device: %w", err)
-			}
-
-			// Prepare args - use disk device which is reliable
-			runArgs := []string{"detach", diskDevice}
-			if force {
-				runArgs = append(runArgs, "-force")
-			}
-
-			if err := ctx.Exec.Run(cmd.Context(), "hdiutil", runArgs...); err != nil {
-				return fmt.Errorf("failed to unmount: %w", err)
+			if err := vol.Unmount(cmd.Context(), force); err != nil {
+				return err
 			}
 			ctx.Printer.Success("Volume unmounted")
 			return nil
@@ -206,38 +197,3 @@ func BuildExit(ctx *Context) *cobra.Command {
 	cmd.Flags().BoolVarP(&force, "force", "f", false, "Force unmount (needed if resource is busy)")
 	return cmd
 }
-
-// findDiskDevice finds the disk device for our mounted image from hdiutil info.
-// Returns the disk device path like "/dev/disk4" that can be used with hdiutil detach.
-func findDiskDevice(ctx *Context) (string, error) {
-	out, err := ctx.Exec.Output(context.Background(), "hdiutil", "info")
-	if err != nil {
-		return "", err
-	}
-
-	return parseDiskDeviceFromHdiutil(string(out), ctx.Config.Image.Path)
-}
-
-// parseDiskDeviceFromHdiutil extracts the disk device for an image from hdiutil info output.
-func parseDiskDeviceFromHdiutil(output, imagePath string) (string, error) {
-	// Simple O(N) regex search instead of multi-pass line parsing
-	// Look for: image-path ... /dev/diskXsY or /dev/diskX
-	// But hdiutil output structure is:
-	// image-path: ...
-	// /dev/disk...
-	//
-	// We need to find the block for our image.
-
-	// Split by block for safety
-	blocks := strings.Split(output, "===")
-	for _, block := range blocks {
-		if strings.Contains(block, imagePath) {
-			re := regexp.MustCompile(`/dev/disk\d+`)
-			if match := re.FindString(block); match != "" {
-				return match, nil
-			}
-		}
-	}
-
-	return "", fmt.Errorf("disk device not found for image: %s", imagePath)
-}
@@ -1,13 +1,17 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/NguyenTrongPhuc552003/elmos/core/domain/builder"
+	"github.com/NguyenTrongPhuc552003/elmos/core/domain/emulator"
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/kconfig"
 )
 
 // BuildKernel creates the kernel command tree for kernel management.
@@ -25,6 +29,7 @@ func BuildKernel(ctx *Context) *cobra.Command {
 		buildKernelResetCmd(ctx),
 		buildKernelSwitchCmd(ctx),
 		buildKernelPullCmd(ctx),
+		buildKernelPrepareCmd(ctx),
 		buildKernelBuildCmd(ctx),
 	)
 
@@ -33,16 +38,26 @@ func BuildKernel(ctx *Context) *cobra.Command {
 
 // buildKernelConfigCmd creates the kernel config subcommand.
 func buildKernelConfigCmd(ctx *Context) *cobra.Command {
-	var enableOpts []string
+	var enableOpts, fragments []string
 	cmd := &cobra.Command{
 		Use:   "config [type]",
 		Short: "Configure the kernel",
 		Long: `Configure the kernel with a config target or enable specific options.
 
+After a base config (defconfig, tinyconfig, kvm_guest.config, all*config)
+the fragments listed in build.fragments are merged on top, followed by any
+given with --fragment.
+
 Examples:
-  elmos kernel config              # Run defconfig
+  elmos kernel config              # Run the arch's defconfig
   elmos kernel config menuconfig   # Interactive config
-  elmos kernel config -E NETFILTER # Enable CONFIG_NETFILTER`,
+  elmos kernel config -F debug,9p  # defconfig plus the debug and 9p fragments
+  elmos kernel config -E NETFILTER # Enable CONFIG_NETFILTER
+  elmos kernel config fragment list
+  elmos kernel config get DEBUG_INFO
+  elmos kernel config set KASAN=y
+  elmos kernel config diff old.config # Compare with the current .config
+  elmos kernel config check -r KVM    # Check QEMU prerequisites and KVM`,
 		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
 			// Handle --enable options
 			if len(enableOpts) > 0 {
@@ -53,21 +68,277 @@ Examples:
 			if len(args) > 0 {
 				configType = args[0]
 			}
+			// Some targets (rv32, ppc64le) need a named defconfig instead of the ARCH default
+			if archCfg := ctx.Config.GetArchConfig(); archCfg != nil && configType == "defconfig" {
+				configType = archCfg.DefconfigTarget()
+			}
 			ctx.Printer.Step("Running kernel %s...", configType)
 			if err := ctx.KernelBuilder.Configure(cmd.Context(), configType); err != nil {
 				return err
 			}
+			if names := ctx.KernelBuilder.ConfigFragments(configType, fragments); len(names) > 0 {
+				if err := mergeConfigFragments(ctx, cmd, names); err != nil {
+					return err
+				}
+			}
 			ctx.Printer.Success("Kernel configured!")
 			return nil
 		}),
 	}
 	cmd.Flags().StringArrayVarP(&enableOpts, "enable", "E", nil, "Enable kernel config option (e.g., NETFILTER)")
+	cmd.Flags().StringSliceVarP(&fragments, "fragment", "F", nil, "Merge config fragment(s) after configuring")
+	cmd.AddCommand(
+		buildKernelFragmentCmd(ctx),
+		buildKernelConfigGetCmd(ctx),
+		buildKernelConfigSetCmd(ctx),
+		buildKernelConfigDiffCmd(ctx),
+		buildKernelConfigCheckCmd(ctx),
+	)
 	return cmd
 }
 
+// buildKernelConfigGetCmd creates the kernel config get subcommand.
+func buildKernelConfigGetCmd(ctx *Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <symbol>...",
+		Short: "Print kernel config symbol values",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := ctx.KernelBuilder.LoadConfig("")
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				ctx.Printer.Print("%s", cfg.Value(args[0]))
+				return nil
+			}
+			for _, name := range args {
+				ctx.Printer.Print("%s=%s", kconfig.Name(name), cfg.Value(name))
+			}
+			return nil
+		},
+	}
+}
+
+// buildKernelConfigSetCmd creates the kernel config set subcommand.
+func buildKernelConfigSetCmd(ctx *Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <symbol=value>...",
+		Short: "Set kernel config symbols and run olddefconfig",
+		Long: `Set kernel config symbols and run olddefconfig.
+
+Values are y, m, n, numbers or quoted strings. Symbols whose value Kconfig
+changed afterwards (usually because of unmet dependencies) are reported.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			var symbols []kconfig.Symbol
+			for _, arg := range args {
+				name, value, ok := strings.Cut(arg, "=")
+				if !ok || name == "" || value == "" {
+					return fmt.Errorf("invalid assignment %q (use SYMBOL=value)", arg)
+				}
+				symbols = append(symbols, kconfig.Symbol{Name: kconfig.Name(name), Value: value})
+			}
+			dropped, err := ctx.KernelBuilder.ApplyConfigSymbols(cmd.Context(), symbols)
+			if err != nil {
+				return err
+			}
+			if len(dropped) > 0 {
+				for _, c := range dropped {
+					ctx.Printer.Warn("%s: requested %s, olddefconfig set %s", c.Symbol, c.Old, c.New)
+				}
+				return fmt.Errorf("%d symbol(s) could not be set", len(dropped))
+			}
+			ctx.Printer.Success("Config updated! Run 'elmos kernel build' to apply changes.")
+			return nil
+		}),
+	}
+}
+
+// buildKernelConfigDiffCmd creates the kernel config diff subcommand.
+func buildKernelConfigDiffCmd(ctx *Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff <a> [b]",
+		Short: "Compare two kernel configs (b defaults to the current .config)",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pathB := ""
+			if len(args) == 2 {
+				pathB = args[1]
+			}
+			changes, err := ctx.KernelBuilder.DiffConfigs(args[0], pathB)
+			if err != nil {
+				return err
+			}
+			if len(changes) == 0 {
+				ctx.Printer.Info("Configs are identical")
+				return nil
+			}
+			for _, c := range changes {
+				ctx.Printer.Print("%s", c)
+			}
+			return nil
+		},
+	}
+}
+
+// buildKernelConfigCheckCmd creates the kernel config check subcommand.
+func buildKernelConfigCheckCmd(ctx *Context) *cobra.Command {
+	var required []string
+	var debug, graphical bool
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check the kernel config for required symbols",
+		Long: `Check the kernel .config against the symbols 'elmos qemu run' needs for
+the current architecture (root disk, console driver, virtio transport, 9p
+share) plus any given with --require. Optional symbols only warn.
+
+A requirement is SYMBOL (built in or module) or SYMBOL=value.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			reqs := ctx.QEMURunner.BootRequirements(emulator.RunOptions{Graphical: graphical})
+			for _, r := range required {
+				req, err := kconfig.ParseRequirement(r)
+				if err != nil {
+					return err
+				}
+				reqs = append(reqs, emulator.BootRequirement{Requirement: req, Reason: "--require"})
+			}
+			cfg, err := ctx.KernelBuilder.LoadConfig("")
+			if err != nil {
+				return err
+			}
+			var debugErr error
+			if debug {
+				debugErr = ctx.QEMURunner.CheckDebugConfig()
+			}
+
+			failed, missing := 0, 0
+			for _, r := range reqs {
+				if r.Satisfied(cfg) {
+					continue
+				}
+				if missing == 0 {
+					ctx.Printer.Print("%-9s %-36s %-4s %-4s %s", "STATUS", "SYMBOL", "WANT", "HAVE", "NEEDED FOR")
+				}
+				missing++
+				status := "optional"
+				if !r.Optional {
+					status = "missing"
+					failed++
+				}
+				ctx.Printer.Print("%-9s %-36s %-4s %-4s %s", status, r.Symbol, wantLabel(r.Want), cfg.Value(r.Symbol), r.Reason)
+			}
+			if debugErr != nil {
+				ctx.Printer.Error("%v", debugErr)
+			}
+			if failed > 0 || debugErr != nil {
+				ctx.Printer.Print("Fix with 'elmos qemu run --fix' or 'elmos kernel config set'")
+				return fmt.Errorf("kernel config check failed")
+			}
+			ctx.Printer.Success("All required symbols met (%d checked)", len(reqs))
+			return nil
+		},
+	}
+	cmd.Flags().StringArrayVarP(&required, "require", "r", nil, "Additional required symbol (SYMBOL or SYMBOL=value)")
+	cmd.Flags().BoolVar(&debug, "debug", false, "Also require debug info for GDB")
+	cmd.Flags().BoolVarP(&graphical, "graphical", "g", false, "Include the requirements of 'qemu run --graphical'")
+	return cmd
+}
+
+// buildKernelFragmentCmd creates the kernel config fragment subcommand tree.
+func buildKernelFragmentCmd(ctx *Context) *cobra.Command {
+	fragmentCmd := &cobra.Command{
+		Use:   "fragment",
+		Short: "Manage kernel config fragments",
+		Long: `Manage named kernel config fragments (*.config files of CONFIG_ lines).
+
+Fragments are bundled with elmos, kept in the repo (paths.fragments_dir,
+default <project>/fragments) or in the workspace (<mount_point>/fragments).
+A repo fragment overrides a bundled one of the same name and a workspace
+fragment overrides both.`,
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List available fragments",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fragments, err := ctx.KernelBuilder.ListFragments()
+			if err != nil {
+				return err
+			}
+			enabled := make(map[string]bool)
+			for _, name := range ctx.Config.Build.Fragments {
+				enabled[name] = true
+			}
+			ctx.Printer.Print("  %-16s %-10s %s", "NAME", "SOURCE", "DESCRIPTION")
+			for _, f := range fragments {
+				marker := " "
+				if enabled[f.Name] {
+					marker = "*"
+				}
+				ctx.Printer.Print("%s %-16s %-10s %s", marker, f.Name, f.Source, f.Description)
+			}
+			return nil
+		},
+	}
+
+	showCmd := &cobra.Command{
+		Use:   "show <name>",
+		Short: "Print a fragment",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := ctx.KernelBuilder.GetFragment(args[0])
+			if err != nil {
+				return err
+			}
+			_, err = ctx.Printer.Writer().Write(f.Content())
+			return err
+		},
+	}
+
+	mergeCmd := &cobra.Command{
+		Use:   "merge <name|file>...",
+		Short: "Merge fragments into the current .config",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			if err := mergeConfigFragments(ctx, cmd, args); err != nil {
+				return err
+			}
+			ctx.Printer.Success("Config updated! Run 'elmos kernel build' to apply changes.")
+			return nil
+		}),
+	}
+
+	fragmentCmd.AddCommand(listCmd, showCmd, mergeCmd)
+	return fragmentCmd
+}
+
+// mergeConfigFragments merges fragments and reports symbols that did not survive.
+func mergeConfigFragments(ctx *Context, cmd *cobra.Command, names []string) error {
+	ctx.Printer.Step("Merging fragments: %s", strings.Join(names, ", "))
+	result, err := ctx.KernelBuilder.MergeFragments(cmd.Context(), names)
+	if err != nil {
+		return err
+	}
+	for _, c := range result.Redefined {
+		ctx.Printer.Info("%s redefined by %s: %s -> %s", c.Symbol, c.Fragment, c.Old, c.New)
+	}
+	if len(result.Dropped) == 0 {
+		ctx.Printer.Success("All %d requested symbol(s) applied", result.Requested)
+		return nil
+	}
+	ctx.Printer.Warn("%d of %d requested symbol(s) did not survive olddefconfig:", len(result.Dropped), result.Requested)
+	for _, c := range result.Dropped {
+		ctx.Printer.Print("  %-36s requested %-6s actual %-6s (%s)", c.Symbol, c.Old, c.New, c.Fragment)
+	}
+	return nil
+}
+
 // enableKernelConfigs enables specific kernel config options and runs oldconfig.
 func enableKernelConfigs(ctx *Context, cmd *cobra.Command, opts []string) error {
-	configPath := ctx.Config.Paths.KernelDir + "/.config"
+	configPath := ctx.AppContext.GetKernelConfig()
 	scriptsConfig := ctx.Config.Paths.KernelDir + "/scripts/config"
 
 	// Enable each option
@@ -95,11 +366,25 @@ func enableKernelConfigs(ctx *Context, cmd *cobra.Command, opts []string) error
 
 // buildKernelCleanCmd creates the kernel clean subcommand.
 func buildKernelCleanCmd(ctx *Context) *cobra.Command {
-	return &cobra.Command{
+	var source bool
+	cmd := &cobra.Command{
 		Use:   "clean",
 		Short: "Clean kernel build artifacts",
+		Long: `Clean the kernel build tree of the current architecture (make distclean).
+
+With build.out_of_tree only <build_dir>/<arch> is cleaned and the trees of
+other architectures stay warm. Use --source to run mrproper in the source
+tree, which kbuild requires before its first out-of-tree build.`,
 		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
-			ctx.Printer.Step("Cleaning...")
+			if source {
+				ctx.Printer.Step("Cleaning source tree %s...", ctx.Config.Paths.KernelDir)
+				if err := ctx.KernelBuilder.CleanSource(cmd.Context()); err != nil {
+					return err
+				}
+				ctx.Printer.Success("Source tree cleaned!")
+				return nil
+			}
+			ctx.Printer.Step("Cleaning %s...", ctx.AppContext.GetKernelOutDir())
 			if err := ctx.KernelBuilder.Clean(cmd.Context()); err != nil {
 				return err
 			}
@@ -107,6 +392,8 @@ func buildKernelCleanCmd(ctx *Context) *cobra.Command {
 			return nil
 		}),
 	}
+	cmd.Flags().BoolVar(&source, "source", false, "Run mrproper in the kernel source tree")
+	return cmd
 }
 
 // buildKernelCloneCmd creates the kernel clone subcommand.
@@ -128,6 +415,7 @@ func buildKernelCloneCmd(ctx *Context) *cobra.Command {
 				return fmt.Errorf("failed to clone: %w", err)
 			}
 			ctx.Printer.Success("Kernel cloned to %s", ctx.Config.Paths.KernelDir)
+			suggestPrepare(ctx, cmd)
 			return nil
 		}),
 	}
@@ -148,6 +436,7 @@ func buildKernelStatusCmd(ctx *Context) *cobra.Command {
 			ctx.Printer.Print("")
 			printKernelGitInfo(ctx, cmd)
 			printKernelBuildStatus(ctx)
+			printKernelPatchStatus(ctx, cmd)
 			return nil
 		}),
 	}
@@ -171,6 +460,7 @@ func buildKernelResetCmd(ctx *Context) *cobra.Command {
 				return fmt.Errorf("failed to clone: %w", err)
 			}
 			ctx.Printer.Success("Kernel reset complete!")
+			suggestPrepare(ctx, cmd)
 			return nil
 		}),
 	}
@@ -221,29 +511,245 @@ func buildKernelPullCmd(ctx *Context) *cobra.Command {
 	}
 }
 
+// buildKernelPrepareCmd creates the kernel prepare subcommand.
+func buildKernelPrepareCmd(ctx *Context) *cobra.Command {
+	var kernelVersion string
+	cmd := &cobra.Command{
+		Use:   "prepare",
+		Short: "Apply the patches matching the checked-out kernel",
+		Long: `Detect the version of the checked-out kernel (from its Makefile, or
+'git describe') and apply the matching patches for the current architecture
+plus "generic". Patch directories match by version: v6.18 applies to 6.18.x,
+v6.18.3 to that release only and v6.18+ to 6.18 and every later kernel.
+
+Already applied patches are skipped, so prepare can be re-run after every
+clone, switch or pull. See 'elmos patch --help' for the series layout.`,
+		Args: cobra.NoArgs,
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			if !ctx.AppContext.KernelExists() {
+				ctx.Printer.Info("Kernel source not found. Run 'elmos kernel clone' first.")
+				return nil
+			}
+			kv, series, err := resolveSeries(ctx, cmd, kernelVersion)
+			if err != nil {
+				return err
+			}
+			ctx.Printer.Step("Preparing Linux %s for %s...", kv, ctx.Config.Build.Arch)
+			return applySeries(ctx, cmd, kv, series)
+		}),
+	}
+	cmd.Flags().StringVarP(&kernelVersion, "kernel-version", "k", "", "Kernel version to select patches for (default: detected)")
+	return cmd
+}
+
+// suggestPrepare points at 'kernel prepare' when matching patches are not applied yet.
+func suggestPrepare(ctx *Context, cmd *cobra.Command) {
+	kv, series, err := resolveSeries(ctx, cmd, "")
+	if err != nil || len(series) == 0 {
+		return
+	}
+	entries, err := ctx.PatchManager.Status(series)
+	if err != nil {
+		return
+	}
+	pending := 0
+	for _, e := range entries[:len(series)] {
+		if !e.Applied {
+			pending++
+		}
+	}
+	if pending > 0 {
+		ctx.Printer.Info("%d patch(es) match Linux %s; run 'elmos kernel prepare' to apply them", pending, kv)
+	}
+}
+
 // buildKernelBuildCmd creates the kernel build subcommand.
 func buildKernelBuildCmd(ctx *Context) *cobra.Command {
 	var jobs int
+	var lastErrors bool
 	cmd := &cobra.Command{
 		Use:   "build [targets...]",
 		Short: "Build the Linux kernel",
+		Long: `Build the Linux kernel.
+
+Output is also written to a timestamped log under <workspace>/logs.
+On failure, errors and warnings are extracted from the log and summarized.
+Every build is timed and recorded; see 'elmos kernel build history'.
+
+Examples:
+  elmos kernel build                # Build default targets
+  elmos kernel build -j8 Image      # Build a specific target
+  elmos kernel build --last-errors  # Re-print the summary of the last build
+  elmos kernel build history        # Build times and regressions`,
 		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			if lastErrors {
+				summary, err := ctx.KernelBuilder.LastBuildSummary()
+				if err != nil {
+					return err
+				}
+				printBuildSummary(ctx, summary)
+				return nil
+			}
+
 			targets := args
 			if len(targets) == 0 {
 				targets = ctx.KernelBuilder.GetDefaultTargets()
 			}
 			ctx.Printer.Step("Building kernel for %s...", ctx.Config.Build.Arch)
 			if err := ctx.KernelBuilder.Build(cmd.Context(), builder.BuildOptions{Jobs: jobs, Targets: targets}); err != nil {
+				var failure *builder.BuildFailure
+				if errors.As(err, &failure) {
+					printBuildSummary(ctx, failure.Summary)
+				}
 				return err
 			}
 			ctx.Printer.Success("Build complete!")
+			printLastBuildTrend(ctx)
 			return nil
 		}),
 	}
 	cmd.Flags().IntVarP(&jobs, "jobs", "j", 0, "Number of parallel build jobs")
+	cmd.Flags().BoolVar(&lastErrors, "last-errors", false, "Show the error summary of the last build")
+	cmd.AddCommand(buildKernelBuildHistoryCmd(ctx))
 	return cmd
 }
 
+// buildKernelBuildHistoryCmd creates the kernel build history subcommand.
+func buildKernelBuildHistoryCmd(ctx *Context) *cobra.Command {
+	var limit int
+	var arch string
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show kernel build times and flag regressions",
+		Long: `Show recorded kernel builds with their wall-clock time, jobs, targets,
+compiler cache hit rate and kernel git HEAD.
+
+Each successful build is compared with the median of up to five earlier
+builds of the same arch and targets. Builds at least 30% (and 30s) slower
+are flagged, together with what changed since the previous build: compiler
+version, toolchain, .config, HEAD, jobs or cache hit rate.
+
+The history is kept in <workspace>/logs/kernel-build-history.jsonl.`,
+		Args: cobra.NoArgs,
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			records, err := ctx.KernelBuilder.History()
+			if err != nil {
+				return err
+			}
+			trends := builder.BuildTrends(records)
+			var shown []builder.BuildTrend
+			for _, t := range trends {
+				if arch == "" || t.Record.Arch == arch {
+					shown = append(shown, t)
+				}
+			}
+			if len(shown) == 0 {
+				ctx.Printer.Info("No builds recorded yet")
+				return nil
+			}
+			if limit > 0 && len(shown) > limit {
+				shown = shown[len(shown)-limit:]
+			}
+
+			ctx.Printer.Print("%-19s %-8s %-24s %-4s %-9s %-9s %-6s %s", "STARTED", "ARCH", "TARGETS", "JOBS", "TIME", "VS BASE", "CACHE", "HEAD")
+			var regressions []builder.BuildTrend
+			for _, t := range shown {
+				r := t.Record
+				elapsed := r.Duration().Round(time.Second).String()
+				if !r.Success {
+					elapsed = "failed"
+				}
+				vsBase := "-"
+				if t.Baseline > 0 {
+					vsBase = fmt.Sprintf("%+.0f%%", (t.Slowdown()-1)*100)
+					if t.Regression {
+						vsBase += " !"
+						regressions = append(regressions, t)
+					}
+				}
+				hitRate := "-"
+				if rate := r.CacheHitRate(); rate >= 0 {
+					hitRate = fmt.Sprintf("%.0f%%", rate)
+				}
+				ctx.Printer.Print("%-19s %-8s %-24s %-4d %-9s %-9s %-6s %s", r.Started.Format("2006-01-02 15:04:05"), r.Arch,
+					truncate(strings.Join(r.Targets, " "), 24), r.Jobs, elapsed, vsBase, hitRate, valueOrDash(r.GitHead))
+			}
+
+			for _, t := range regressions {
+				ctx.Printer.Print("")
+				printBuildRegression(ctx, t)
+			}
+			return nil
+		}),
+	}
+	cmd.Flags().IntVarP(&limit, "limit", "n", 20, "Number of builds to show (0 for all)")
+	cmd.Flags().StringVar(&arch, "arch", "", "Only show builds for this architecture")
+	return cmd
+}
+
+// printLastBuildTrend reports the time of the build just recorded and warns on a regression.
+func printLastBuildTrend(ctx *Context) {
+	records, err := ctx.KernelBuilder.History()
+	if err != nil || len(records) == 0 {
+		return
+	}
+	trends := builder.BuildTrends(records)
+	last := trends[len(trends)-1]
+	ctx.Printer.Print("  Build time: %s", last.Record.Duration().Round(time.Second))
+	if last.Regression {
+		printBuildRegression(ctx, last)
+	}
+}
+
+// printBuildRegression explains a build that was markedly slower than its baseline.
+func printBuildRegression(ctx *Context, t builder.BuildTrend) {
+	ctx.Printer.Warn("Build on %s took %s, %.1fx the usual %s",
+		t.Record.Started.Format("2006-01-02 15:04"), t.Record.Duration().Round(time.Second),
+		t.Slowdown(), t.Baseline.Round(time.Second))
+	if len(t.Changes) == 0 {
+		ctx.Printer.Print("  No recorded input changed since the previous build")
+		return
+	}
+	for _, c := range t.Changes {
+		ctx.Printer.Print("  %s", c)
+	}
+}
+
+// truncate shortens s to at most n characters, marking the cut with "...".
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
+
+// maxSummaryIssues limits how many diagnostics of each severity are printed.
+const maxSummaryIssues = 20
+
+// printBuildSummary prints the errors and warnings extracted from a build log.
+func printBuildSummary(ctx *Context, summary *builder.BuildLogSummary) {
+	ctx.Printer.Print("")
+	ctx.Printer.Step("Build summary: %d error(s), %d warning(s)", len(summary.Errors), len(summary.Warnings))
+	printBuildIssues(ctx, "Errors", summary.Errors)
+	printBuildIssues(ctx, "Warnings", summary.Warnings)
+	ctx.Printer.Print("  Log: %s", summary.LogPath)
+}
+
+// printBuildIssues prints up to maxSummaryIssues diagnostics under a heading.
+func printBuildIssues(ctx *Context, heading string, issues []builder.BuildIssue) {
+	if len(issues) == 0 {
+		return
+	}
+	ctx.Printer.Print("  %s:", heading)
+	for i, issue := range issues {
+		if i == maxSummaryIssues {
+			ctx.Printer.Print("    ... and %d more", len(issues)-maxSummaryIssues)
+			break
+		}
+		ctx.Printer.Print("    %s", issue.String())
+	}
+}
+
 // --- Helper functions to reduce RunE complexity ---
 
 // printKernelGitInfo prints git branch/tag and commit info for status command.
@@ -264,12 +770,44 @@ func printKernelGitInfo(ctx *Context, cmd *cobra.Command) {
 	if err == nil {
 		ctx.Printer.Print("  Commit: %s", strings.TrimSpace(string(commit)))
 	}
+	if kv, err := ctx.AppContext.KernelVersion(cmd.Context()); err == nil {
+		ctx.Printer.Print("  Version: %s", kv)
+	}
+}
+
+// printKernelPatchStatus prints how many of the matching patches are applied.
+func printKernelPatchStatus(ctx *Context, cmd *cobra.Command) {
+	kv, series, err := resolveSeries(ctx, cmd, "")
+	if err != nil || len(series) == 0 {
+		return
+	}
+	entries, err := ctx.PatchManager.Status(series)
+	if err != nil {
+		return
+	}
+	applied := 0
+	for _, e := range entries[:len(series)] {
+		if e.Applied {
+			applied++
+		}
+	}
+	ctx.Printer.Print("")
+	ctx.Printer.Step("Patches for %s:", kv)
+	if applied == len(series) {
+		ctx.Printer.Print("  ✓ All %d applied", applied)
+	} else {
+		ctx.Printer.Print("  ○ %d of %d applied (run 'elmos kernel prepare')", applied, len(series))
+	}
 }
 
 // printKernelBuildStatus prints kernel config and image status.
 func printKernelBuildStatus(ctx *Context) {
 	ctx.Printer.Print("")
 	ctx.Printer.Step("Build status:")
+	ctx.Printer.Print("  Output: %s", ctx.AppContext.GetKernelOutDir())
+	if ctx.AppContext.HasInTreeBuild() {
+		ctx.Printer.Print("  ✗ In-tree build blocks out-of-tree builds (run 'elmos kernel clean --source')")
+	}
 	if ctx.AppContext.HasConfig() {
 		ctx.Printer.Print("  ✓ Kernel configured (.config exists)")
 	} else {
@@ -314,6 +852,14 @@ func listKernelRefs(ctx *Context, cmd *cobra.Command) error {
 
 // switchKernelRef switches to a specific branch or tag.
 func switchKernelRef(ctx *Context, cmd *cobra.Command, ref string) error {
+	if applied, _ := ctx.PatchManager.Applied(); len(applied) > 0 {
+		ctx.Printer.Warn("%d patch(es) are applied to the kernel tree and may be lost or conflict:", len(applied))
+		printPatches(ctx, "Applied", applied)
+		ctx.Printer.Print("  Run 'elmos patch pop -a' first to remove them cleanly")
+		if isInteractive() && !confirm(ctx, "Switch anyway?") {
+			return nil
+		}
+	}
 	ctx.Printer.Step("Switching to: %s", ref)
 	if err := ctx.Exec.Run(cmd.Context(), "git", "-C", ctx.Config.Paths.KernelDir, "checkout", ref); err != nil {
 		ctx.Printer.Info("Not found locally, fetching...")
@@ -323,6 +869,7 @@ func switchKernelRef(ctx *Context, cmd *cobra.Command, ref string) error {
 		}
 	}
 	ctx.Printer.Success("Now on: %s", ref)
+	suggestPrepare(ctx, cmd)
 	return nil
 }
 
@@ -1,7 +1,18 @@
 package commands
 
 import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
 	"github.com/spf13/cobra"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/domain/builder"
+	"github.com/NguyenTrongPhuc552003/elmos/core/domain/emulator"
 )
 
 // BuildModule creates the module command tree for kernel module management.
@@ -15,30 +26,52 @@ func BuildModule(ctx *Context) *cobra.Command {
 		buildModuleBuildCmd(ctx),
 		buildModuleListCmd(ctx),
 		buildModuleNewCmd(ctx),
+		buildModuleTemplatesCmd(ctx),
 		buildModuleCleanCmd(ctx),
 		buildModuleHeaderCmd(ctx),
+		buildModuleReloadCmd(ctx),
+		buildModuleInfoCmd(ctx),
 	)
 	return modCmd
 }
 
 // buildModuleBuildCmd creates the module build subcommand.
 func buildModuleBuildCmd(ctx *Context) *cobra.Command {
-	return &cobra.Command{
+	var opts builder.TargetBuildOptions
+	cmd := &cobra.Command{
 		Use:   "build [name]",
 		Short: "Build kernel modules",
+		Long: `Build all kernel modules, or one module and the modules it depends on.
+
+Independent modules are built in parallel (--jobs, default build.jobs),
+with each module's output written to <workspace>/logs/module-<name>.log.
+A module depends on another when its Makefile lists the other module's
+Module.symvers in KBUILD_EXTRA_SYMBOLS; it is built after it and rebuilt
+whenever it changes. Modules whose .ko is newer than their sources and the
+kernel are skipped unless --force is given.
+
+Examples:
+  elmos module build              # Build what changed
+  elmos module build -k           # Report every failure, not just the first
+  elmos module build hello -f     # Rebuild hello and its dependencies`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := ctx.AppContext.EnsureMounted(); err != nil {
 				return err
 			}
 			name := getOptionalArg(args)
 			ctx.Printer.Step("Building modules...")
-			if err := ctx.ModuleBuilder.Build(cmd.Context(), name); err != nil {
+			err := runTargetBuild(ctx, "module", opts, func(opts builder.TargetBuildOptions) (*builder.BuildReport, error) {
+				return ctx.ModuleBuilder.BuildWithOptions(cmd.Context(), name, opts)
+			})
+			if err != nil {
 				return err
 			}
 			ctx.Printer.Success("Modules built!")
 			return nil
 		},
 	}
+	addTargetBuildFlags(cmd, &opts)
+	return cmd
 }
 
 // buildModuleListCmd creates the module list subcommand.
@@ -51,18 +84,29 @@ func buildModuleListCmd(ctx *Context) *cobra.Command {
 			if err != nil {
 				return err
 			}
+			if ctx.Printer.Structured() {
+				return ctx.Printer.Emit(map[string]interface{}{"modules": nonNil(mods)})
+			}
 			if len(mods) == 0 {
 				ctx.Printer.Info("No modules found")
 				return nil
 			}
 			ctx.Printer.Print("Modules:")
+			mismatched := false
 			for i, m := range mods {
 				status := ""
 				if m.Built {
 					status = " (built)"
 				}
+				if len(m.VermagicMismatch) > 0 {
+					status = " (built, vermagic mismatch: " + strings.Join(m.VermagicMismatch, "; ") + ")"
+					mismatched = true
+				}
 				ctx.Printer.Print("  %d. %s%s", i+1, m.Name, status)
 			}
+			if mismatched {
+				ctx.Printer.Warn("Modules with a vermagic mismatch will not load; rebuild them with 'elmos module build -f'")
+			}
 			return nil
 		},
 	}
@@ -70,15 +114,93 @@ func buildModuleListCmd(ctx *Context) *cobra.Command {
 
 // buildModuleNewCmd creates the module new subcommand.
 func buildModuleNewCmd(ctx *Context) *cobra.Command {
-	return &cobra.Command{
+	var opts builder.ModuleScaffoldOptions
+	cmd := &cobra.Command{
 		Use:   "new [name]",
 		Short: "Create new module",
-		Args:  cobra.ExactArgs(1),
+		Long: `Create a module in the modules directory from a template.
+
+The basic template is a single source file. The others produce a Kbuild
+layout with a header, a <name>_main.c holding init/exit and the module
+information, and one more source for the driver itself:
+
+  char       Character device with a read/write buffer
+  misc       Misc device with a dynamic minor
+  platform   Platform driver with a test device
+  netfilter  IPv4 netfilter hooks counting packets
+  sysfs      Attributes under /sys/kernel
+  debugfs    Files under /sys/kernel/debug
+  kthread    Kernel thread running a periodic worker
+
+A directory in <mount_point>/templates/module/ overrides the built-in
+template of the same name or adds a new one (see 'elmos module templates').
+
+Examples:
+  elmos module new hello
+  elmos module new mydev -t char --author "Jane Doe <jane@example.com>"
+  elmos module new filter -t netfilter --license "Dual MIT/GPL" -d "Packet counter"`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			files, err := ctx.ModuleBuilder.CreateModule(cmd.Context(), args[0], opts)
+			if err != nil {
+				return err
+			}
+			tmpl := opts.Template
+			if tmpl == "" {
+				tmpl = builder.DefaultModuleTemplate
+			}
+			ctx.Printer.Success("Created module: %s (template %s)", args[0], tmpl)
+			for _, f := range files {
+				ctx.Printer.Print("  %s", filepath.Join(ctx.Config.Paths.ModulesDir, args[0], f))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Template, "template", "t", "", "Module template (default \"basic\")")
+	cmd.Flags().StringVar(&opts.Author, "author", "", "MODULE_AUTHOR (default: git user.name and user.email)")
+	cmd.Flags().StringVar(&opts.License, "license", "", "MODULE_LICENSE, e.g. \"GPL\" or \"Dual MIT/GPL\" (default \"GPL\")")
+	cmd.Flags().StringVarP(&opts.Description, "description", "d", "", "MODULE_DESCRIPTION")
+	return cmd
+}
+
+// buildModuleTemplatesCmd creates the module templates subcommand.
+func buildModuleTemplatesCmd(ctx *Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "templates",
+		Short: "List module templates",
+		Long: `List the templates 'elmos module new --template' accepts.
+
+Templates are bundled with elmos or kept in the workspace, one directory
+per template in <mount_point>/templates/module/. A workspace template
+overrides a bundled one of the same name. Every file in it is executed as
+a Go text/template, and so is its name, with these fields:
+
+  {{.Name}}         module name
+  {{.CName}}        module name as a C identifier
+  {{.Upper}}        CName in upper case
+  {{.Author}}       --author
+  {{.License}}      --license
+  {{.SPDX}}         SPDX identifier of the license
+  {{.Description}}  --description
+
+A .tmpl suffix is dropped from file names.`,
+		Args: cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if err := ctx.ModuleBuilder.CreateModule(args[0]); err != nil {
+			templates, err := ctx.ModuleBuilder.ListModuleTemplates()
+			if err != nil {
 				return err
 			}
-			ctx.Printer.Success("Created module: %s", args[0])
+			if ctx.Printer.Structured() {
+				return ctx.Printer.Emit(templates)
+			}
+			ctx.Printer.Print("%-12s %-10s %s", "NAME", "SOURCE", "DESCRIPTION")
+			for _, t := range templates {
+				desc := t.Description
+				if desc == "" {
+					desc = t.Path
+				}
+				ctx.Printer.Print("%-12s %-10s %s", t.Name, t.Source, desc)
+			}
 			return nil
 		},
 	}
@@ -124,6 +246,247 @@ func buildModuleHeaderCmd(ctx *Context) *cobra.Command {
 	}
 }
 
+// moduleInfoReport is the structured output of module info.
+type moduleInfoReport struct {
+	File             string `json:"file" yaml:"file"`
+	*builder.ModInfo `yaml:",inline"`
+	KernelRelease    string   `json:"kernel_release,omitempty" yaml:"kernel_release,omitempty"`
+	VermagicMismatch []string `json:"vermagic_mismatch" yaml:"vermagic_mismatch"`
+}
+
+// buildModuleInfoCmd creates the module info subcommand.
+func buildModuleInfoCmd(ctx *Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "info <name>",
+		Short: "Show the metadata of a built module",
+		Long: `Show the .modinfo metadata of a built module (license, author, version,
+vermagic, dependencies, parameters and aliases), read from its .ko file,
+and check that its vermagic matches the current kernel build.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mods, err := ctx.ModuleBuilder.GetModules(args[0])
+			if err != nil {
+				return err
+			}
+			mod := mods[0]
+			if !mod.Built {
+				return fmt.Errorf("module %s is not built (run 'elmos module build %s')", mod.Name, mod.Name)
+			}
+			ko := filepath.Join(mod.Path, mod.Name+".ko")
+			info, err := ctx.ModuleBuilder.ReadModInfo(ko)
+			if err != nil {
+				return err
+			}
+			mismatches, checked := ctx.ModuleBuilder.CheckVermagic(info)
+
+			if ctx.Printer.Structured() {
+				return ctx.Printer.Emit(moduleInfoReport{
+					File:             ko,
+					ModInfo:          info,
+					KernelRelease:    ctx.ModuleBuilder.KernelRelease(),
+					VermagicMismatch: nonNil(mismatches),
+				})
+			}
+			printModInfo(ctx, ko, info)
+			switch {
+			case !checked:
+				ctx.Printer.Info("No kernel build to check vermagic against")
+			case len(mismatches) == 0:
+				ctx.Printer.Success("vermagic matches the kernel build")
+			default:
+				for _, mm := range mismatches {
+					ctx.Printer.Warn("vermagic mismatch: %s", mm)
+				}
+				ctx.Printer.Print("  The kernel will refuse to load it; rebuild with 'elmos module build %s -f'", mod.Name)
+			}
+			return nil
+		},
+	}
+}
+
+// printModInfo prints the .modinfo metadata of a module.
+func printModInfo(ctx *Context, ko string, info *builder.ModInfo) {
+	ctx.Printer.Print("Module: %s", valueOrDash(info.Name))
+	ctx.Printer.Print("  File:        %s", ko)
+	ctx.Printer.Print("  Description: %s", valueOrDash(info.Description))
+	ctx.Printer.Print("  License:     %s", valueOrDash(info.License))
+	ctx.Printer.Print("  Author:      %s", valueOrDash(info.Author))
+	ctx.Printer.Print("  Version:     %s", valueOrDash(info.Version))
+	ctx.Printer.Print("  Srcversion:  %s", valueOrDash(info.SrcVersion))
+	ctx.Printer.Print("  Vermagic:    %s", valueOrDash(info.Vermagic))
+	ctx.Printer.Print("  Machine:     %s", valueOrDash(info.Machine))
+	ctx.Printer.Print("  Depends:     %s", valueOrDash(strings.Join(info.Depends, ", ")))
+	for i, alias := range info.Aliases {
+		label := ""
+		if i == 0 {
+			label = "Aliases:"
+		}
+		ctx.Printer.Print("  %-12s %s", label, alias)
+	}
+	if len(info.Params) > 0 {
+		ctx.Printer.Print("  Parameters:")
+		for _, p := range info.Params {
+			ctx.Printer.Print("%s", strings.TrimRight(fmt.Sprintf("    %-16s %-8s %s", p.Name, valueOrDash(p.Type), p.Description), " "))
+		}
+	}
+}
+
+// buildModuleReloadCmd creates the module reload subcommand.
+func buildModuleReloadCmd(ctx *Context) *cobra.Command {
+	var watch bool
+	var via, instance string
+	cmd := &cobra.Command{
+		Use:   "reload <name>",
+		Short: "Rebuild a module and reload it in the running guest",
+		Long: `Rebuild a module, then unload and load it again inside a running QEMU
+instance and print the kernel messages it produced.
+
+The guest is reached over SSH on the instance's forwarded port when it
+accepts root logins, otherwise through the elmos agent that guesync.sh
+starts on the 9p modules share at boot.
+
+With --watch, the module is rebuilt and reloaded every time one of its
+source files changes, until interrupted.
+
+Examples:
+  elmos module reload hello            # Rebuild and reload once
+  elmos module reload hello --watch    # Reload on every save
+  elmos module reload hello --via agent -n dev`,
+		Args: cobra.ExactArgs(1),
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			mods, err := ctx.ModuleBuilder.GetModules(args[0])
+			if err != nil {
+				return err
+			}
+			mod := mods[0]
+			if !watch {
+				return reloadModule(ctx, cmd, mod, instance, via)
+			}
+			return watchModule(ctx, cmd, mod, instance, via)
+		}),
+	}
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "Reload whenever the module sources change")
+	cmd.Flags().StringVar(&via, "via", emulator.GuestViaAuto, "How to reach the guest: auto, ssh or agent")
+	cmd.Flags().StringVarP(&instance, "name", "n", "", "Instance name (default \"default\")")
+	return cmd
+}
+
+// reloadModule rebuilds a module and reloads it in the guest.
+func reloadModule(ctx *Context, cmd *cobra.Command, mod builder.ModuleInfo, instance, via string) error {
+	ctx.Printer.Step("Building %s...", mod.Name)
+	if err := ctx.ModuleBuilder.Build(cmd.Context(), mod.Name); err != nil {
+		return err
+	}
+	ctx.Printer.Step("Reloading %s in the guest...", mod.Name)
+	ko := filepath.Join(mod.Path, mod.Name+".ko")
+	if err := ctx.QEMURunner.ReloadModule(cmd.Context(), instance, via, ko); err != nil {
+		return err
+	}
+	ctx.Printer.Success("Module %s reloaded", mod.Name)
+	return nil
+}
+
+// watchModule reloads a module after each change to its sources. Build and
+// load failures are reported and the watch continues.
+func watchModule(ctx *Context, cmd *cobra.Command, mod builder.ModuleInfo, instance, via string) error {
+	sigCtx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := reloadModule(ctx, cmd, mod, instance, via); err != nil {
+		ctx.Printer.Error("%v", err)
+	}
+	ctx.Printer.Info("Watching %s for changes (Ctrl+C to stop)", mod.Path)
+
+	ko := filepath.Join(mod.Path, mod.Name+".ko")
+	return ctx.Watcher.Watch(sigCtx, builder.WatchOptions{
+		Targets: []builder.WatchTarget{{Kind: builder.WatchModule, Name: mod.Name}},
+		OnBuild: func(t builder.WatchTarget) {
+			ctx.Printer.Step("Building %s...", t.Name)
+		},
+		OnResult: func(r builder.WatchResult) {
+			if !r.Success {
+				ctx.Printer.Error("%s", r.Error)
+				return
+			}
+			ctx.Printer.Step("Reloading %s in the guest...", r.Name)
+			if err := ctx.QEMURunner.ReloadModule(sigCtx, instance, via, ko); err != nil {
+				ctx.Printer.Error("%v", err)
+				return
+			}
+			ctx.Printer.Success("Module %s reloaded", r.Name)
+		},
+	})
+}
+
+// addTargetBuildFlags adds the scheduling flags shared by module and app builds.
+func addTargetBuildFlags(cmd *cobra.Command, opts *builder.TargetBuildOptions) {
+	cmd.Flags().IntVarP(&opts.Jobs, "jobs", "j", 0, "Number of builds to run at once (default build.jobs)")
+	cmd.Flags().BoolVarP(&opts.KeepGoing, "keep-going", "k", false, "Keep building after a failure")
+	cmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "Rebuild even if up to date")
+}
+
+// runTargetBuild runs a module or app build, printing each result as it
+// finishes and a results table at the end.
+func runTargetBuild(ctx *Context, kind string, opts builder.TargetBuildOptions, build func(builder.TargetBuildOptions) (*builder.BuildReport, error)) error {
+	opts.OnStart = func(name string) {
+		ctx.Printer.Step("Building %s %s...", kind, name)
+	}
+	opts.OnDone = func(r builder.TargetResult) {
+		switch r.Status {
+		case builder.TargetBuilt:
+			ctx.Printer.Success("%s built in %s", r.Name, formatSeconds(r.Seconds))
+		case builder.TargetFailed:
+			ctx.Printer.Error("%s failed in %s", r.Name, formatSeconds(r.Seconds))
+		}
+	}
+
+	report, err := build(opts)
+	if err != nil {
+		return err
+	}
+	if ctx.Printer.Structured() {
+		if err := ctx.Printer.Emit(map[string]interface{}{"results": nonNil(report.Results)}); err != nil {
+			return err
+		}
+		return report.Err()
+	}
+	if len(report.Results) == 0 {
+		ctx.Printer.Info("No %ss found", kind)
+		return nil
+	}
+
+	printBuildReport(ctx, kind, report)
+	return report.Err()
+}
+
+// printBuildReport prints the results table of a module or app build.
+func printBuildReport(ctx *Context, kind string, report *builder.BuildReport) {
+	ctx.Printer.Print("")
+	ctx.Printer.Print("%-24s %-10s %-8s %s", strings.ToUpper(kind), "STATUS", "TIME", "NOTES")
+	for _, r := range report.Results {
+		took := "-"
+		if r.Seconds > 0 {
+			took = formatSeconds(r.Seconds)
+		}
+		notes := r.Reason
+		if r.Status == builder.TargetFailed && r.Log != "" {
+			notes = r.Log
+		}
+		ctx.Printer.Print("%s", strings.TrimRight(fmt.Sprintf("%-24s %-10s %-8s %s", r.Name, r.Status, took, notes), " "))
+		if r.Status == builder.TargetFailed {
+			printBuildIssues(ctx, "Errors", r.Errors)
+		}
+	}
+	ctx.Printer.Print("\n%d built, %d up to date, %d failed, %d skipped",
+		report.Count(builder.TargetBuilt), report.Count(builder.TargetUpToDate),
+		report.Count(builder.TargetFailed), report.Count(builder.TargetSkipped))
+}
+
+// formatSeconds formats a duration in seconds for build output.
+func formatSeconds(s float64) string {
+	return time.Duration(s * float64(time.Second)).Round(10 * time.Millisecond).String()
+}
+
 // getOptionalArg returns the first argument or empty string if none provided.
 func getOptionalArg(args []string) string {
 	if len(args) > 0 {
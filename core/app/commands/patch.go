@@ -1,7 +1,12 @@
 package commands
 
 import (
+	"fmt"
+
 	"github.com/spf13/cobra"
+
+	elcontext "github.com/NguyenTrongPhuc552003/elmos/core/context"
+	"github.com/NguyenTrongPhuc552003/elmos/core/domain/patch"
 )
 
 // BuildPatch creates the patch command tree for kernel patch management.
@@ -9,45 +14,353 @@ func BuildPatch(ctx *Context) *cobra.Command {
 	patchCmd := &cobra.Command{
 		Use:   "patch",
 		Short: "Manage kernel patches",
+		Long: `Manage kernel patches kept under patches/<version>/<arch>.
+
+The version directories that match the checked-out kernel are selected
+automatically: v6.18 applies to 6.18.x, v6.18.3 to that release only and
+v6.18+ to 6.18 and later. Their patches form a quilt-like series, oldest
+version first; within a version the generic directory comes first, then the
+directories for the current architecture, each ordered by its 'series' file
+(or by file name without one). Applied patches are recorded as a stack in
+<kernel>/.elmos, so they can be popped again before switching kernels.
+
+Examples:
+  elmos patch status            # Series with applied markers
+  elmos patch apply-all         # Push the whole series
+  elmos patch pop -a            # Remove every applied patch
+  elmos patch refresh           # Rewrite the top patch from the tree
+  elmos patch export HEAD~2     # Save the last two kernel commits`,
 	}
 
+	var applyOpts patch.ApplyOptions
 	applyCmd := &cobra.Command{
 		Use:   "apply [file]",
 		Short: "Apply patch",
-		Args:  cobra.ExactArgs(1),
+		Long: `Apply a single patch file to the kernel tree.
+
+Mailbox patches (git format-patch output, starting with a "From <sha>" line)
+are committed with 'git am', keeping their author and message. Plain diffs,
+or any patch with --no-commit, are applied to the working tree with patch(1).`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := ctx.AppContext.EnsureMounted(); err != nil {
 				return err
 			}
 			ctx.Printer.Step("Applying patch: %s", args[0])
-			if err := ctx.PatchManager.Apply(cmd.Context(), args[0]); err != nil {
+			if err := ctx.PatchManager.Apply(cmd.Context(), args[0], applyOpts); err != nil {
 				return err
 			}
 			ctx.Printer.Success("Patch applied!")
 			return nil
 		},
 	}
+	applyCmd.Flags().BoolVar(&applyOpts.NoCommit, "no-commit", false, "Apply mailbox patches without committing (patch -p1)")
 
+	var all bool
 	listCmd := &cobra.Command{
 		Use:   "list",
-		Short: "List patches",
+		Short: "List patches for the current architecture",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			patches, err := ctx.PatchManager.List()
+			list := ctx.PatchManager.ListForArch
+			if all {
+				list = ctx.PatchManager.List
+			}
+			patches, err := list()
 			if err != nil {
 				return err
 			}
+			if ctx.Printer.Structured() {
+				return ctx.Printer.Emit(map[string]interface{}{"patches": nonNil(patches)})
+			}
 			if len(patches) == 0 {
 				ctx.Printer.Info("No patches")
 				return nil
 			}
 			ctx.Printer.Print("Patches:")
 			for _, p := range patches {
-				ctx.Printer.Print("  %s/%s/%s", p.Version, p.Arch, p.Name)
+				ctx.Printer.Print("  %s", p.ID())
+				if p.Subject != "" {
+					ctx.Printer.Print("      %s (%s)", p.Subject, valueOrDash(p.Author))
+				}
 			}
 			return nil
 		},
 	}
+	listCmd.Flags().BoolVarP(&all, "all", "a", false, "List patches for every architecture")
 
-	patchCmd.AddCommand(applyCmd, listCmd)
+	patchCmd.AddCommand(
+		applyCmd,
+		listCmd,
+		buildPatchApplyAllCmd(ctx),
+		buildPatchPushCmd(ctx),
+		buildPatchPopCmd(ctx),
+		buildPatchStatusCmd(ctx),
+		buildPatchRefreshCmd(ctx),
+		buildPatchExportCmd(ctx),
+	)
 	return patchCmd
 }
+
+// buildPatchApplyAllCmd creates the patch apply-all subcommand.
+func buildPatchApplyAllCmd(ctx *Context) *cobra.Command {
+	var kernelVersion string
+	cmd := &cobra.Command{
+		Use:   "apply-all",
+		Short: "Push every unapplied patch of the series",
+		Args:  cobra.NoArgs,
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			kv, series, err := resolveSeries(ctx, cmd, kernelVersion)
+			if err != nil {
+				return err
+			}
+			return applySeries(ctx, cmd, kv, series)
+		}),
+	}
+	cmd.Flags().StringVarP(&kernelVersion, "kernel-version", "k", "", "Kernel version to select patches for (default: detected)")
+	return cmd
+}
+
+// buildPatchPushCmd creates the patch push subcommand.
+func buildPatchPushCmd(ctx *Context) *cobra.Command {
+	var kernelVersion string
+	var all bool
+	cmd := &cobra.Command{
+		Use:   "push [patch]",
+		Short: "Apply the next patch, or all patches up to the named one",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			_, series, err := resolveSeries(ctx, cmd, kernelVersion)
+			if err != nil {
+				return err
+			}
+			var pushed []patch.PatchInfo
+			switch {
+			case all:
+				pushed, err = ctx.PatchManager.PushAll(cmd.Context(), series)
+			case len(args) == 1:
+				pushed, err = ctx.PatchManager.Push(cmd.Context(), series, args[0])
+			default:
+				pushed, err = ctx.PatchManager.Push(cmd.Context(), series, "")
+			}
+			printPatches(ctx, "Applied", pushed)
+			if err != nil {
+				return err
+			}
+			if len(pushed) > 0 {
+				ctx.Printer.Success("Now at %s", pushed[len(pushed)-1].Name)
+			}
+			return nil
+		}),
+	}
+	cmd.Flags().BoolVarP(&all, "all", "a", false, "Apply the whole series")
+	cmd.Flags().StringVarP(&kernelVersion, "kernel-version", "k", "", "Kernel version to select patches for (default: detected)")
+	return cmd
+}
+
+// buildPatchPopCmd creates the patch pop subcommand.
+func buildPatchPopCmd(ctx *Context) *cobra.Command {
+	var all, force bool
+	cmd := &cobra.Command{
+		Use:   "pop [patch]",
+		Short: "Remove the top patch, or all patches above the named one",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			var popped []patch.PatchInfo
+			var err error
+			switch {
+			case all:
+				popped, err = ctx.PatchManager.PopAll(cmd.Context(), force)
+			case len(args) == 1:
+				popped, err = ctx.PatchManager.Pop(cmd.Context(), args[0], force)
+			default:
+				popped, err = ctx.PatchManager.Pop(cmd.Context(), "", force)
+			}
+			printPatches(ctx, "Removed", popped)
+			if err != nil {
+				return err
+			}
+			applied, err := ctx.PatchManager.Applied()
+			if err != nil {
+				return err
+			}
+			if len(applied) == 0 {
+				ctx.Printer.Success("No patches applied")
+			} else {
+				ctx.Printer.Success("Now at %s", applied[len(applied)-1].Name)
+			}
+			return nil
+		}),
+	}
+	cmd.Flags().BoolVarP(&all, "all", "a", false, "Remove every applied patch")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Restore files from backups, discarding unrefreshed changes")
+	return cmd
+}
+
+// buildPatchStatusCmd creates the patch status subcommand.
+func buildPatchStatusCmd(ctx *Context) *cobra.Command {
+	var kernelVersion string
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the patch series and which patches are applied",
+		Args:  cobra.NoArgs,
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			kv, series, err := resolveSeries(ctx, cmd, kernelVersion)
+			if err != nil {
+				return err
+			}
+			entries, err := ctx.PatchManager.Status(series)
+			if err != nil {
+				return err
+			}
+			if ctx.Printer.Structured() {
+				return ctx.Printer.Emit(map[string]interface{}{"kernel_version": kv.String(), "series": nonNil(entries)})
+			}
+
+			applied := 0
+			ctx.Printer.Step("Series for %s (%s):", kv, ctx.Config.Build.Arch)
+			for i, e := range entries {
+				marker := " "
+				if e.Top {
+					marker = "="
+				} else if e.Applied {
+					marker = "+"
+				}
+				note := ""
+				if i >= len(series) {
+					note = " (not in this series)"
+				} else if e.Applied {
+					applied++
+				}
+				ctx.Printer.Print("  %s %s%s", marker, e.ID(), note)
+			}
+			ctx.Printer.Print("")
+			ctx.Printer.Print("  %d of %d applied (+ applied, = top)", applied, len(series))
+			return nil
+		}),
+	}
+	cmd.Flags().StringVarP(&kernelVersion, "kernel-version", "k", "", "Kernel version to select patches for (default: detected)")
+	return cmd
+}
+
+// buildPatchRefreshCmd creates the patch refresh subcommand.
+func buildPatchRefreshCmd(ctx *Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "refresh",
+		Short: "Rewrite the top patch from the current kernel tree",
+		Long: `Regenerate the top patch from the current contents of the files it
+touches, keeping its description. Edit the kernel sources after 'push',
+then 'refresh' to save the changes back into patches/.`,
+		Args: cobra.NoArgs,
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			top, err := ctx.PatchManager.Refresh(cmd.Context())
+			if err != nil {
+				return err
+			}
+			ctx.Printer.Success("Refreshed %s", top.Path)
+			return nil
+		}),
+	}
+}
+
+// buildPatchExportCmd creates the patch export subcommand.
+func buildPatchExportCmd(ctx *Context) *cobra.Command {
+	var versionDir, arch string
+	cmd := &cobra.Command{
+		Use:   "export <revision-range>...",
+		Short: "Write kernel tree commits into patches/ with git format-patch",
+		Long: `Export commits from the kernel tree into patches/<version>/<arch> using
+'git format-patch', numbered after the patches already there. The arguments
+are passed to format-patch, so any revision range works. If the directory
+has a series file, the new patches are appended to it.
+
+The version directory defaults to v<major>.<minor> of the checked-out kernel
+and the arch directory to the current architecture.
+
+Examples:
+  elmos patch export HEAD~2                   # Last two commits
+  elmos patch export v6.18..my-fixes          # A branch
+  elmos patch export --arch generic -- -1 abc123`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			if !ctx.AppContext.KernelExists() {
+				return fmt.Errorf("kernel source not found (run 'elmos kernel clone')")
+			}
+			if versionDir == "" {
+				kv, err := ctx.AppContext.KernelVersion(cmd.Context())
+				if err != nil {
+					return fmt.Errorf("%w (pass the directory with --version-dir)", err)
+				}
+				versionDir = fmt.Sprintf("v%d.%d", kv.Major, kv.Minor)
+			}
+			if arch == "" {
+				arch = ctx.Config.Build.Arch
+			}
+
+			exported, err := ctx.PatchManager.Export(cmd.Context(), args, versionDir, arch)
+			for _, p := range exported {
+				ctx.Printer.Print("  %s", p.ID())
+				if p.Subject != "" {
+					ctx.Printer.Print("      %s", p.Subject)
+				}
+			}
+			if err != nil {
+				return err
+			}
+			ctx.Printer.Success("Exported %d patch(es) to %s/%s", len(exported), versionDir, arch)
+			return nil
+		}),
+	}
+	cmd.Flags().StringVarP(&versionDir, "version-dir", "d", "", "Patch version directory, e.g. v6.18 or v6.18+ (default: checked-out kernel)")
+	cmd.Flags().StringVar(&arch, "arch", "", "Patch arch directory, or 'generic' (default: current arch)")
+	return cmd
+}
+
+// resolveSeries returns the kernel version to select patches for (the
+// checked-out one unless overridden) and its patch series.
+func resolveSeries(ctx *Context, cmd *cobra.Command, override string) (*elcontext.KernelVersion, []patch.PatchInfo, error) {
+	var kv *elcontext.KernelVersion
+	var err error
+	if override != "" {
+		kv, err = elcontext.ParseKernelVersion(override)
+	} else {
+		kv, err = ctx.AppContext.KernelVersion(cmd.Context())
+		if err != nil {
+			err = fmt.Errorf("%w (pass the version with --kernel-version)", err)
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	series, err := ctx.PatchManager.KernelSeries(kv)
+	if err != nil {
+		return nil, nil, err
+	}
+	return kv, series, nil
+}
+
+// applySeries pushes the unapplied part of a series. It is idempotent:
+// patches already on the stack are skipped.
+func applySeries(ctx *Context, cmd *cobra.Command, kv *elcontext.KernelVersion, series []patch.PatchInfo) error {
+	if len(series) == 0 {
+		ctx.Printer.Info("No patches for %s (%s)", kv, ctx.Config.Build.Arch)
+		return nil
+	}
+	pushed, err := ctx.PatchManager.PushAll(cmd.Context(), series)
+	printPatches(ctx, "Applied", pushed)
+	if err != nil {
+		return err
+	}
+	if len(pushed) == 0 {
+		ctx.Printer.Success("All %d patch(es) for %s already applied", len(series), kv)
+		return nil
+	}
+	ctx.Printer.Success("Applied %d patch(es) for %s", len(pushed), kv)
+	return nil
+}
+
+// printPatches lists patches pushed or popped by a stack operation.
+func printPatches(ctx *Context, verb string, patches []patch.PatchInfo) {
+	for _, p := range patches {
+		ctx.Printer.Print("  %s %s", verb, p.ID())
+	}
+}
@@ -0,0 +1,181 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/config"
+)
+
+// BuildProfile creates the profile command tree for named configuration profiles.
+func BuildProfile(ctx *Context) *cobra.Command {
+	profileCmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Manage named configuration profiles",
+		Long: `Manage named configuration profiles stored in elmos.yaml.
+
+A profile captures arch, jobs, cross_compile, QEMU memory/SMP,
+GDB/SSH ports and kernel config fragments.
+
+Examples:
+  elmos profile list                          # List profiles
+  elmos profile save riscv-debug              # Save current settings
+  elmos profile use arm64-release             # Apply and persist a profile
+  elmos profile diff riscv-debug arm64-release
+  elmos --profile riscv-debug kernel build    # Apply for one command only`,
+	}
+
+	profileCmd.AddCommand(
+		buildProfileListCmd(ctx),
+		buildProfileUseCmd(ctx),
+		buildProfileSaveCmd(ctx),
+		buildProfileDiffCmd(ctx),
+	)
+	return profileCmd
+}
+
+// buildProfileListCmd creates the profile list subcommand.
+func buildProfileListCmd(ctx *Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List profiles",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names := ctx.Config.ProfileNames()
+			if len(names) == 0 {
+				ctx.Printer.Info("No profiles defined")
+				ctx.Printer.Print("  Run 'elmos profile save <name>' to create one")
+				return nil
+			}
+			ctx.Printer.Print("Profiles:")
+			for _, name := range names {
+				marker := " "
+				if name == ctx.Config.ActiveProfile {
+					marker = "*"
+				}
+				ctx.Printer.Print("  %s %-20s %s", marker, name, summarizeProfile(ctx.Config.Profiles[name]))
+			}
+			return nil
+		},
+	}
+}
+
+// buildProfileUseCmd creates the profile use subcommand.
+func buildProfileUseCmd(ctx *Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "use <name>",
+		Short: "Apply a profile and save it as the current configuration",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if err := ctx.Config.ApplyProfile(name); err != nil {
+				return err
+			}
+			if err := ctx.Config.Save(resolveConfigPath(ctx)); err != nil {
+				return err
+			}
+			ctx.Printer.Success("Now using profile: %s", name)
+			ctx.Printer.Print("  %s", summarizeProfile(ctx.Config.Profiles[name]))
+			return nil
+		},
+	}
+}
+
+// buildProfileSaveCmd creates the profile save subcommand.
+func buildProfileSaveCmd(ctx *Context) *cobra.Command {
+	var force bool
+	cmd := &cobra.Command{
+		Use:   "save <name>",
+		Short: "Save the current settings as a profile",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if _, exists := ctx.Config.Profiles[name]; exists && !force {
+				return fmt.Errorf("profile already exists: %s (use --force to overwrite)", name)
+			}
+			ctx.Config.SaveProfile(name)
+			if err := ctx.Config.Save(resolveConfigPath(ctx)); err != nil {
+				return err
+			}
+			ctx.Printer.Success("Profile saved: %s", name)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite an existing profile")
+	return cmd
+}
+
+// buildProfileDiffCmd creates the profile diff subcommand.
+func buildProfileDiffCmd(ctx *Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff <a> [b]",
+		Short: "Compare two profiles (or a profile with the current settings)",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, ok := ctx.Config.Profiles[args[0]]
+			if !ok {
+				return fmt.Errorf("profile not found: %s", args[0])
+			}
+
+			to := ctx.Config.SnapshotProfile()
+			toName := "current"
+			if len(args) > 1 {
+				if to, ok = ctx.Config.Profiles[args[1]]; !ok {
+					return fmt.Errorf("profile not found: %s", args[1])
+				}
+				toName = args[1]
+			}
+
+			changes := config.DiffProfiles(from, to)
+			if len(changes) == 0 {
+				ctx.Printer.Info("No differences between %s and %s", args[0], toName)
+				return nil
+			}
+			ctx.Printer.Print("%-15s %-25s %s", "Setting", args[0], toName)
+			for _, c := range changes {
+				ctx.Printer.Print("%-15s %-25s %s", c.Key, c.From, c.To)
+			}
+			return nil
+		},
+	}
+}
+
+// summarizeProfile returns a one-line summary of a profile's settings.
+func summarizeProfile(p config.ProfileConfig) string {
+	var parts []string
+	if p.Arch != "" {
+		parts = append(parts, "arch="+p.Arch)
+	}
+	if p.Jobs > 0 {
+		parts = append(parts, fmt.Sprintf("jobs=%d", p.Jobs))
+	}
+	if p.Memory != "" {
+		parts = append(parts, "memory="+p.Memory)
+	}
+	if p.SMP > 0 {
+		parts = append(parts, fmt.Sprintf("smp=%d", p.SMP))
+	}
+	if p.CrossCompile != "" {
+		parts = append(parts, "cross_compile="+p.CrossCompile)
+	}
+	if p.GDBPort > 0 {
+		parts = append(parts, fmt.Sprintf("gdb=%d", p.GDBPort))
+	}
+	if p.SSHPort > 0 {
+		parts = append(parts, fmt.Sprintf("ssh=%d", p.SSHPort))
+	}
+	if len(p.Fragments) > 0 {
+		parts = append(parts, "fragments="+strings.Join(p.Fragments, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+// resolveConfigPath returns the config file to write, defaulting to elmos.yaml in the project root.
+func resolveConfigPath(ctx *Context) string {
+	if ctx.Config.ConfigFile != "" {
+		return ctx.Config.ConfigFile
+	}
+	return filepath.Join(ctx.Config.Paths.ProjectRoot, "elmos.yaml")
+}
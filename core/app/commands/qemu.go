@@ -1,8 +1,16 @@
 package commands
 
 import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
 	"github.com/spf13/cobra"
 
+	"github.com/NguyenTrongPhuc552003/elmos/core/domain/builder"
 	"github.com/NguyenTrongPhuc552003/elmos/core/domain/emulator"
 )
 
@@ -11,8 +19,23 @@ func BuildQEMU(ctx *Context) *cobra.Command {
 	qemuCmd := &cobra.Command{
 		Use:   "qemu",
 		Short: "Run and debug kernel in QEMU",
+		Long: `Run and debug the kernel in QEMU.
+
+Several guests can run at once as named instances. Each instance gets free
+SSH/GDB ports, a QMP socket and a copy-on-write overlay of the rootfs disk
+under <workspace>/run/<name>. Commands act on the "default" instance unless
+--name is given.
+
+Examples:
+  elmos qemu run                  # Start the default instance
+  elmos qemu run --name net-test  # Start a second guest
+  elmos qemu ps                   # List instances
+  elmos qemu kill net-test        # Stop one`,
 	}
-	var graphical bool
+	var graphical, snapshot bool
+	var name string
+	var preflight preflightFlags
+	qemuCmd.PersistentFlags().StringVarP(&name, "name", "n", "", "Instance name (default \"default\")")
 
 	runCmd := &cobra.Command{
 		Use:   "run",
@@ -21,11 +44,22 @@ func BuildQEMU(ctx *Context) *cobra.Command {
 			if err := ctx.AppContext.EnsureMounted(); err != nil {
 				return err
 			}
+			opts := emulator.RunOptions{
+				Name:      name,
+				Graphical: graphical,
+				Snapshot:  snapshot,
+				OnStart:   func(inst *emulator.Instance) { printInstanceInfo(ctx, inst) },
+			}
+			if err := runBootPreflight(cmd.Context(), ctx, opts, preflight); err != nil {
+				return err
+			}
 			ctx.Printer.Step("Starting QEMU...")
-			return ctx.QEMURunner.Run(cmd.Context(), emulator.RunOptions{Graphical: graphical})
+			return ctx.QEMURunner.Run(cmd.Context(), opts)
 		},
 	}
 	runCmd.Flags().BoolVarP(&graphical, "graphical", "g", false, "Graphical mode")
+	runCmd.Flags().BoolVar(&snapshot, "snapshot", false, "Discard all disk changes when QEMU exits")
+	preflight.register(runCmd)
 
 	debugCmd := &cobra.Command{
 		Use:   "debug",
@@ -34,22 +68,401 @@ func BuildQEMU(ctx *Context) *cobra.Command {
 			if err := ctx.AppContext.EnsureMounted(); err != nil {
 				return err
 			}
+			opts := emulator.RunOptions{
+				Name:      name,
+				Debug:     true,
+				Graphical: graphical,
+				OnStart:   func(inst *emulator.Instance) { printInstanceInfo(ctx, inst) },
+			}
+			if err := runBootPreflight(cmd.Context(), ctx, opts, preflight); err != nil {
+				return err
+			}
+			if err := ctx.QEMURunner.CheckDebugConfig(); err != nil {
+				ctx.Printer.Warn("%v", err)
+				ctx.Printer.Print("  Run 'elmos kernel config -F debug' and rebuild for source-level debugging")
+			}
 			ctx.Printer.Step("Starting QEMU in debug mode...")
-			return ctx.QEMURunner.Debug(cmd.Context(), graphical)
+			return ctx.QEMURunner.Debug(cmd.Context(), opts)
 		},
 	}
+	preflight.register(debugCmd)
 
-	qemuCmd.AddCommand(runCmd, debugCmd)
+	qemuCmd.AddCommand(runCmd, debugCmd, buildQEMUTestCmd(ctx, &name, &preflight))
+	qemuCmd.AddCommand(
+		buildQEMUPsCmd(ctx),
+		buildQEMUKillCmd(ctx),
+		buildQEMUStatusCmd(ctx, &name),
+		buildQEMUStopCmd(ctx, &name),
+		buildQEMUControlCmd(ctx, &name, "reset", "Reset the running guest", "Guest reset", ctx.QEMURunner.Reset),
+		buildQEMUControlCmd(ctx, &name, "pause", "Pause the running guest", "Guest paused", ctx.QEMURunner.Pause),
+		buildQEMUControlCmd(ctx, &name, "resume", "Resume the paused guest", "Guest resumed", ctx.QEMURunner.Resume),
+		buildQEMUSnapshotCmd(ctx, &name),
+	)
 	return qemuCmd
 }
 
+// printInstanceInfo shows how to reach a freshly started instance.
+func printInstanceInfo(ctx *Context, inst *emulator.Instance) {
+	info := fmt.Sprintf("Instance %s: ssh -p %d root@localhost", inst.Name, inst.SSHPort)
+	if inst.GDBPort > 0 {
+		info += fmt.Sprintf(", gdb localhost:%d", inst.GDBPort)
+	}
+	ctx.Printer.Info("%s", info)
+}
+
+// buildQEMUPsCmd creates the qemu ps subcommand.
+func buildQEMUPsCmd(ctx *Context) *cobra.Command {
+	var all bool
+	cmd := &cobra.Command{
+		Use:   "ps",
+		Short: "List QEMU instances",
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			instances, err := ctx.QEMURunner.ListInstances()
+			if err != nil {
+				return err
+			}
+			var shown []*emulator.Instance
+			for _, inst := range instances {
+				if all || inst.Running {
+					shown = append(shown, inst)
+				}
+			}
+			if len(shown) == 0 {
+				ctx.Printer.Info("No running instances")
+				return nil
+			}
+			ctx.Printer.Print("%-16s %-8s %-8s %-8s %-6s %-6s %s", "NAME", "STATE", "PID", "ARCH", "SSH", "GDB", "STARTED")
+			for _, inst := range shown {
+				state, pid, gdb := "stopped", "-", "-"
+				if inst.Running {
+					state, pid = "running", fmt.Sprintf("%d", inst.PID)
+				}
+				if inst.GDBPort > 0 {
+					gdb = fmt.Sprintf("%d", inst.GDBPort)
+				}
+				ctx.Printer.Print("%-16s %-8s %-8s %-8s %-6d %-6s %s", inst.Name, state, pid, inst.Arch,
+					inst.SSHPort, gdb, inst.Started.Format("2006-01-02 15:04:05"))
+			}
+			return nil
+		}),
+	}
+	cmd.Flags().BoolVarP(&all, "all", "a", false, "Include stopped instances")
+	return cmd
+}
+
+// buildQEMUKillCmd creates the qemu kill subcommand.
+func buildQEMUKillCmd(ctx *Context) *cobra.Command {
+	var remove bool
+	cmd := &cobra.Command{
+		Use:   "kill <name>",
+		Short: "Stop a QEMU instance",
+		Args:  cobra.ExactArgs(1),
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			if err := ctx.QEMURunner.Kill(cmd.Context(), args[0], remove); err != nil {
+				return err
+			}
+			if remove {
+				ctx.Printer.Success("Instance %s stopped and removed", args[0])
+			} else {
+				ctx.Printer.Success("Instance %s stopped", args[0])
+			}
+			return nil
+		}),
+	}
+	cmd.Flags().BoolVar(&remove, "rm", false, "Also delete the instance state and disk overlay")
+	return cmd
+}
+
+// buildQEMUStatusCmd creates the qemu status subcommand.
+func buildQEMUStatusCmd(ctx *Context, name *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show the state of the running QEMU instance",
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			status, err := ctx.QEMURunner.Status(cmd.Context(), *name)
+			if err != nil {
+				return err
+			}
+			ctx.Printer.Print("QEMU Instance:")
+			ctx.Printer.Print("  State:   %s", status.Status)
+			ctx.Printer.Print("  Version: %s", status.Version)
+			ctx.Printer.Print("  QMP:     %s", status.Socket)
+			return nil
+		}),
+	}
+}
+
+// buildQEMUStopCmd creates the qemu stop subcommand.
+func buildQEMUStopCmd(ctx *Context, name *string) *cobra.Command {
+	var graceful bool
+	cmd := &cobra.Command{
+		Use:   "stop",
+		Short: "Stop the running QEMU instance",
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			if err := ctx.QEMURunner.Stop(cmd.Context(), *name, graceful); err != nil {
+				return err
+			}
+			if graceful {
+				ctx.Printer.Success("Power-off requested")
+			} else {
+				ctx.Printer.Success("QEMU stopped")
+			}
+			return nil
+		}),
+	}
+	cmd.Flags().BoolVar(&graceful, "graceful", false, "Ask the guest to power off instead of quitting QEMU")
+	return cmd
+}
+
+// buildQEMUControlCmd creates a QMP control subcommand that takes no arguments.
+func buildQEMUControlCmd(ctx *Context, name *string, use, short, done string, action func(context.Context, string) error) *cobra.Command {
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			if err := action(cmd.Context(), *name); err != nil {
+				return err
+			}
+			ctx.Printer.Success("%s", done)
+			return nil
+		}),
+	}
+}
+
+// buildQEMUSnapshotCmd creates the qemu snapshot command tree for VM state snapshots.
+func buildQEMUSnapshotCmd(ctx *Context, name *string) *cobra.Command {
+	snapshotCmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Save and restore VM state of the running instance",
+		Long: `Save and restore the full VM state (RAM, devices, disk) of the running instance.
+
+VM snapshots are stored in the instance's qcow2 disk overlay.
+
+Examples:
+  elmos qemu snapshot save booted
+  elmos qemu snapshot load booted
+  elmos qemu snapshot list
+  elmos qemu snapshot delete booted`,
+	}
+
+	saveCmd := &cobra.Command{
+		Use:   "save <name>",
+		Short: "Save the VM state",
+		Args:  cobra.ExactArgs(1),
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			ctx.Printer.Step("Saving snapshot %s...", args[0])
+			if err := ctx.QEMURunner.SaveSnapshot(cmd.Context(), *name, args[0]); err != nil {
+				return fmt.Errorf("failed to save snapshot: %w", err)
+			}
+			ctx.Printer.Success("Snapshot saved: %s", args[0])
+			return nil
+		}),
+	}
+
+	loadCmd := &cobra.Command{
+		Use:   "load <name>",
+		Short: "Restore a saved VM state",
+		Args:  cobra.ExactArgs(1),
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			ctx.Printer.Step("Loading snapshot %s...", args[0])
+			if err := ctx.QEMURunner.LoadSnapshot(cmd.Context(), *name, args[0]); err != nil {
+				return fmt.Errorf("failed to load snapshot: %w", err)
+			}
+			ctx.Printer.Success("Snapshot loaded: %s", args[0])
+			return nil
+		}),
+	}
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a saved VM state",
+		Args:  cobra.ExactArgs(1),
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			if err := ctx.QEMURunner.DeleteSnapshot(cmd.Context(), *name, args[0]); err != nil {
+				return fmt.Errorf("failed to delete snapshot: %w", err)
+			}
+			ctx.Printer.Success("Snapshot deleted: %s", args[0])
+			return nil
+		}),
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List saved VM states",
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			out, err := ctx.QEMURunner.ListSnapshots(cmd.Context(), *name)
+			if err != nil {
+				return err
+			}
+			if out == "" {
+				ctx.Printer.Info("No snapshots")
+				return nil
+			}
+			ctx.Printer.Print("%s", out)
+			return nil
+		}),
+	}
+
+	snapshotCmd.AddCommand(saveCmd, loadCmd, listCmd, deleteCmd)
+	return snapshotCmd
+}
+
+// buildQEMUTestCmd creates the qemu test subcommand for headless boot tests.
+func buildQEMUTestCmd(ctx *Context, name *string, preflight *preflightFlags) *cobra.Command {
+	var opts emulator.TestOptions
+	cmd := &cobra.Command{
+		Use:   "test",
+		Short: "Boot the kernel headless and check the console (for CI)",
+		Long: `Boot the built kernel without a display and watch the serial console.
+
+The test passes when a success pattern appears and fails on a failure
+pattern, a timeout, or QEMU exiting early. The console is captured to a
+log under <workspace>/logs. Exits non-zero on failure.
+
+Default success pattern: "System ready\." (printed by the elmos init script)
+Default failure patterns: "Kernel panic", "Unable to mount root fs",
+"Attempted to kill init", "Oops:", "BUG:"
+
+Examples:
+  elmos qemu test
+  elmos qemu test --timeout 5m --success 'login:' --fail 'Call Trace'
+  elmos qemu test --quiet --log boot.log`,
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			opts.Name = *name
+			// The test always boots headless, so only the serial console matters
+			if err := runBootPreflight(cmd.Context(), ctx, emulator.RunOptions{Name: opts.Name, ConsoleLog: "-"}, *preflight); err != nil {
+				return err
+			}
+			ctx.Printer.Step("Running boot test (timeout %s)...", opts.Timeout)
+			result, err := ctx.QEMURunner.Test(cmd.Context(), opts)
+			if err != nil {
+				return err
+			}
+
+			ctx.Printer.Print("")
+			if result.Matched != "" {
+				ctx.Printer.Print("  Matched: %s", result.Matched)
+			}
+			ctx.Printer.Print("  Console log: %s", result.LogPath)
+			if !result.Passed {
+				ctx.Printer.Error("Boot test failed after %s: %s", result.Duration.Round(time.Millisecond), result.Reason)
+				return fmt.Errorf("boot test failed: %s", result.Reason)
+			}
+			ctx.Printer.Success("Boot test passed in %s", result.Duration.Round(time.Millisecond))
+			return nil
+		}),
+	}
+	cmd.Flags().StringArrayVar(&opts.SuccessPatterns, "success", nil, "Regex marking a successful boot (repeatable)")
+	cmd.Flags().StringArrayVar(&opts.FailurePatterns, "fail", nil, "Regex marking a failed boot (repeatable)")
+	cmd.Flags().DurationVarP(&opts.Timeout, "timeout", "t", emulator.DefaultTestTimeout, "Maximum time to wait for a verdict")
+	cmd.Flags().StringVar(&opts.LogPath, "log", "", "Console log path (default: <workspace>/logs)")
+	cmd.Flags().BoolVarP(&opts.Quiet, "quiet", "q", false, "Do not echo the console")
+	preflight.register(cmd)
+	return cmd
+}
+
+// preflightFlags controls the kernel config check run before booting.
+type preflightFlags struct {
+	fix  bool
+	skip bool
+}
+
+// register adds the preflight flags to a command.
+func (p *preflightFlags) register(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&p.fix, "fix", false, "Enable missing kernel config symbols and rebuild without asking")
+	cmd.Flags().BoolVar(&p.skip, "skip-preflight", false, "Boot without checking the kernel config")
+}
+
+// runBootPreflight checks the kernel .config against what the QEMU command line
+// needs. Missing optional symbols only warn; missing required ones stop the boot
+// unless the user agrees to fix them with scripts/config and rebuild.
+func runBootPreflight(cmdCtx context.Context, ctx *Context, opts emulator.RunOptions, flags preflightFlags) error {
+	if flags.skip {
+		return nil
+	}
+	failures, err := ctx.QEMURunner.CheckBootConfig(opts)
+	if err != nil {
+		ctx.Printer.Warn("Skipping kernel config check: %v", err)
+		return nil
+	}
+
+	var required []emulator.BootFailure
+	for _, f := range failures {
+		if f.Optional {
+			ctx.Printer.Warn("%s is %s: %s will not work", f.Symbol, f.Actual, f.Reason)
+		} else {
+			required = append(required, f)
+		}
+	}
+	if len(required) == 0 {
+		return nil
+	}
+
+	ctx.Printer.Error("Kernel config is missing %d symbol(s) needed to boot %s:", len(required), ctx.Config.Build.Arch)
+	for _, f := range required {
+		ctx.Printer.Print("  %-36s want %-3s have %-3s (%s)", f.Symbol, wantLabel(f.Want), f.Actual, f.Reason)
+	}
+
+	if !flags.fix && !(isInteractive() && confirm(ctx, "Fix with scripts/config and rebuild the kernel?")) {
+		return fmt.Errorf("kernel config does not meet boot requirements (use --fix, or --skip-preflight to boot anyway)")
+	}
+
+	// Only required symbols are forced; recommended ones stay a warning
+	ctx.Printer.Step("Updating .config with scripts/config...")
+	if err := ctx.QEMURunner.FixBootConfig(cmdCtx, required); err != nil {
+		return err
+	}
+	if err := ctx.KernelBuilder.Configure(cmdCtx, "olddefconfig"); err != nil {
+		return err
+	}
+	if remaining, err := ctx.QEMURunner.CheckBootConfig(opts); err == nil {
+		for _, f := range remaining {
+			if !f.Optional {
+				return fmt.Errorf("%s could not be set to %s (unmet Kconfig dependencies?)", f.Symbol, wantLabel(f.Want))
+			}
+		}
+	}
+
+	ctx.Printer.Step("Rebuilding kernel for %s...", ctx.Config.Build.Arch)
+	if err := ctx.KernelBuilder.Build(cmdCtx, builder.BuildOptions{Targets: ctx.KernelBuilder.GetDefaultTargets()}); err != nil {
+		return err
+	}
+	ctx.Printer.Success("Kernel rebuilt with the boot requirements")
+	return nil
+}
+
+// wantLabel formats a requirement's wanted value for display.
+func wantLabel(want string) string {
+	if want == "" {
+		return "y/m"
+	}
+	return want
+}
+
+// isInteractive reports whether stdin is a terminal.
+func isInteractive() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// confirm asks a yes/no question on stdin, defaulting to no.
+func confirm(ctx *Context, question string) bool {
+	fmt.Fprintf(ctx.Printer.Writer(), "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
 // BuildGDB creates the gdb command for connecting to QEMU debug session.
 func BuildGDB(ctx *Context) *cobra.Command {
-	return &cobra.Command{
+	var name string
+	cmd := &cobra.Command{
 		Use:   "gdb",
 		Short: "Connect GDB to running QEMU debug session",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return ctx.QEMURunner.ConnectGDB()
+			return ctx.QEMURunner.ConnectGDB(name)
 		},
 	}
+	cmd.Flags().StringVarP(&name, "name", "n", "", "Instance name (default \"default\")")
+	return cmd
 }
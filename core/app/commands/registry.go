@@ -8,6 +8,7 @@ import (
 	"github.com/NguyenTrongPhuc552003/elmos/core/config"
 	elcontext "github.com/NguyenTrongPhuc552003/elmos/core/context"
 	"github.com/NguyenTrongPhuc552003/elmos/core/domain/builder"
+	"github.com/NguyenTrongPhuc552003/elmos/core/domain/cache"
 	"github.com/NguyenTrongPhuc552003/elmos/core/domain/doctor"
 	"github.com/NguyenTrongPhuc552003/elmos/core/domain/emulator"
 	"github.com/NguyenTrongPhuc552003/elmos/core/domain/patch"
@@ -28,17 +29,20 @@ type Context struct {
 	KernelBuilder    *builder.KernelBuilder
 	ModuleBuilder    *builder.ModuleBuilder
 	AppBuilder       *builder.AppBuilder
+	Watcher          *builder.Watcher
 	QEMURunner       *emulator.QEMURunner
 	HealthChecker    *doctor.HealthChecker
 	AutoFixer        *doctor.AutoFixer
 	RootfsCreator    *rootfs.Creator
 	PatchManager     *patch.Manager
 	ToolchainManager *toolchain.Manager
+	CacheManager     *cache.Manager
 	Printer          *ui.Printer
 
 	// Flags that can be modified
 	Verbose    *bool
 	ConfigFile *string
+	Profile    *string
 }
 
 // Register adds all subcommands to the root command.
@@ -52,10 +56,23 @@ func Register(ctx *Context, rootCmd *cobra.Command) {
 	rootCmd.AddCommand(BuildKernel(ctx))
 	rootCmd.AddCommand(BuildModule(ctx))
 	rootCmd.AddCommand(BuildApps(ctx))
+	rootCmd.AddCommand(BuildWatch(ctx))
 	rootCmd.AddCommand(BuildQEMU(ctx))
 	rootCmd.AddCommand(BuildGDB(ctx))
 	rootCmd.AddCommand(BuildStatus(ctx))
 	rootCmd.AddCommand(BuildRootfs(ctx))
 	rootCmd.AddCommand(BuildPatch(ctx))
 	rootCmd.AddCommand(BuildToolchains(ctx))
+	rootCmd.AddCommand(BuildProfile(ctx))
+	rootCmd.AddCommand(BuildConfig(ctx))
+	rootCmd.AddCommand(BuildCache(ctx))
+}
+
+// nonNil returns s, or an empty slice when s is nil, so structured output
+// renders an empty list as [] rather than null.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
 }
@@ -8,6 +8,12 @@ import (
 	"github.com/NguyenTrongPhuc552003/elmos/core/domain/rootfs"
 )
 
+// rootfsStatus is the structured form of 'elmos rootfs status'.
+type rootfsStatus struct {
+	rootfs.RootfsInfo `yaml:",inline"`
+	Snapshots         []rootfs.SnapshotInfo `json:"snapshots" yaml:"snapshots"`
+}
+
 // BuildRootfs creates the rootfs command tree for root filesystem management.
 func BuildRootfs(ctx *Context) *cobra.Command {
 	rootfsCmd := &cobra.Command{
@@ -44,6 +50,10 @@ func BuildRootfs(ctx *Context) *cobra.Command {
 			if err != nil {
 				return err
 			}
+			snapshots, _ := ctx.RootfsCreator.ListSnapshots()
+			if ctx.Printer.Structured() {
+				return ctx.Printer.Emit(rootfsStatus{RootfsInfo: *info, Snapshots: nonNil(snapshots)})
+			}
 
 			ctx.Printer.Info("Rootfs Status")
 			ctx.Printer.Print("")
@@ -65,6 +75,10 @@ func BuildRootfs(ctx *Context) *cobra.Command {
 				ctx.Printer.Print("  Rootfs Dir:   ✗ not created")
 			}
 
+			if len(snapshots) > 0 {
+				ctx.Printer.Print("  Snapshots:    %d (elmos rootfs snapshot list)", len(snapshots))
+			}
+
 			return nil
 		},
 	}
@@ -83,10 +97,118 @@ func BuildRootfs(ctx *Context) *cobra.Command {
 		},
 	}
 
-	rootfsCmd.AddCommand(createCmd, statusCmd, cleanCmd)
+	rootfsCmd.AddCommand(createCmd, statusCmd, cleanCmd, buildRootfsSnapshotCmd(ctx))
 	return rootfsCmd
 }
 
+// buildRootfsSnapshotCmd creates the rootfs snapshot command tree.
+func buildRootfsSnapshotCmd(ctx *Context) *cobra.Command {
+	snapshotCmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Save and restore rootfs disk snapshots",
+		Long: `Save and restore copies of the rootfs disk image.
+
+QEMU instances boot from qcow2 overlays, so the base disk image stays
+pristine. Snapshots are compressed qcow2 copies stored next to it.
+
+Examples:
+  elmos rootfs snapshot create clean              # Save the base image
+  elmos rootfs snapshot create tuned --from dev   # Save the state of instance "dev"
+  elmos rootfs snapshot list
+  elmos rootfs snapshot restore clean             # Replace the base image
+  elmos rootfs snapshot delete tuned`,
+	}
+
+	var from string
+	var force bool
+	createCmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Save the base disk (or an instance's disk) as a snapshot",
+		Args:  cobra.ExactArgs(1),
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			source := ""
+			if from != "" {
+				inst, err := ctx.QEMURunner.GetInstance(from)
+				if err != nil {
+					return err
+				}
+				if inst.Running {
+					return fmt.Errorf("instance %s is running, stop it first with 'elmos qemu kill %s'", inst.Name, inst.Name)
+				}
+				source = inst.Overlay
+			}
+			ctx.Printer.Step("Creating snapshot %s...", args[0])
+			if err := ctx.RootfsCreator.CreateSnapshot(cmd.Context(), args[0], source, force); err != nil {
+				return err
+			}
+			ctx.Printer.Success("Snapshot created: %s", args[0])
+			return nil
+		}),
+	}
+	createCmd.Flags().StringVar(&from, "from", "", "Capture the disk of a stopped QEMU instance")
+	createCmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite an existing snapshot")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List rootfs snapshots",
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			snapshots, err := ctx.RootfsCreator.ListSnapshots()
+			if err != nil {
+				return err
+			}
+			if len(snapshots) == 0 {
+				ctx.Printer.Info("No snapshots")
+				return nil
+			}
+			ctx.Printer.Print("%-24s %-10s %s", "NAME", "SIZE", "CREATED")
+			for _, s := range snapshots {
+				ctx.Printer.Print("%-24s %-10s %s", s.Name, formatBytes(s.Size), s.Created.Format("2006-01-02 15:04:05"))
+			}
+			return nil
+		}),
+	}
+
+	restoreCmd := &cobra.Command{
+		Use:   "restore <name>",
+		Short: "Replace the base disk image with a snapshot",
+		Args:  cobra.ExactArgs(1),
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			instances, err := ctx.QEMURunner.ListInstances()
+			if err != nil {
+				return err
+			}
+			for _, inst := range instances {
+				if inst.Running {
+					return fmt.Errorf("instance %s is using the disk image, stop it first with 'elmos qemu kill %s'", inst.Name, inst.Name)
+				}
+			}
+			ctx.Printer.Step("Restoring snapshot %s...", args[0])
+			if err := ctx.RootfsCreator.RestoreSnapshot(cmd.Context(), args[0]); err != nil {
+				return err
+			}
+			ctx.Printer.Success("Snapshot restored: %s", args[0])
+			ctx.Printer.Print("  Instance overlays will be recreated on their next run")
+			return nil
+		}),
+	}
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a snapshot",
+		Args:  cobra.ExactArgs(1),
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			if err := ctx.RootfsCreator.DeleteSnapshot(args[0]); err != nil {
+				return err
+			}
+			ctx.Printer.Success("Snapshot deleted: %s", args[0])
+			return nil
+		}),
+	}
+
+	snapshotCmd.AddCommand(createCmd, listCmd, restoreCmd, deleteCmd)
+	return snapshotCmd
+}
+
 // formatBytes formats bytes into human readable string.
 func formatBytes(b int64) string {
 	const unit = 1024
@@ -1,54 +1,81 @@
 package commands
 
 import (
-	"fmt"
-	"strings"
-
 	"github.com/spf13/cobra"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/domain/cache"
 )
 
+// statusReport is the structured form of 'elmos status'.
+type statusReport struct {
+	Mounted    bool         `json:"mounted" yaml:"mounted"`
+	MountPoint string       `json:"mount_point,omitempty" yaml:"mount_point,omitempty"`
+	Backend    string       `json:"backend" yaml:"backend"`
+	Volume     []string     `json:"volume_info,omitempty" yaml:"volume_info,omitempty"`
+	Cache      *cache.Stats `json:"cache" yaml:"cache"` // nil when no compiler cache is in use
+}
+
 // BuildStatus creates the status command for workspace status display.
 func BuildStatus(ctx *Context) *cobra.Command {
 	return &cobra.Command{
 		Use:   "status",
-		Short: "Show workspace status (volume mount info)",
+		Short: "Show workspace status (volume and compiler cache)",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			vol, err := ctx.AppContext.Volume()
+			if err != nil {
+				return err
+			}
+			report := statusReport{Backend: vol.Backend()}
+
 			// Check if mounted
-			if !ctx.AppContext.IsMounted() {
+			if !vol.IsMounted() {
+				if ctx.Printer.Structured() {
+					return ctx.Printer.Emit(report)
+				}
 				ctx.Printer.Info("Workspace not mounted")
 				return nil
 			}
+			report.Mounted = true
 
 			// Get actual mount point
-			mountPoint, err := ctx.AppContext.GetActualMountPoint()
+			report.MountPoint, err = vol.MountPoint()
 			if err != nil {
-				mountPoint = ctx.Config.Image.MountPoint
+				report.MountPoint = ctx.Config.Image.MountPoint
 			}
-
-			ctx.Printer.Success("Workspace mounted at %s", mountPoint)
-			ctx.Printer.Print("")
-			ctx.Printer.Step("Volume info:")
-
-			// Run hdiutil info and display relevant parts
-			out, err := ctx.Exec.Output(cmd.Context(), "hdiutil", "info")
+			report.Volume, err = vol.Info(cmd.Context())
 			if err != nil {
-				return fmt.Errorf("failed to get hdiutil info: %w", err)
+				return err
 			}
 
-			// Print the output (filtered to our image)
-			lines := strings.Split(string(out), "\n")
-			inOurImage := false
-			for _, line := range lines {
-				if strings.Contains(line, ctx.Config.Image.Path) {
-					inOurImage = true
-				}
-				if inOurImage {
-					ctx.Printer.Print("  %s", line)
-					if strings.HasPrefix(line, "/dev/disk") && strings.Contains(line, "/Volumes/") {
-						break
-					}
+			var cacheErr error
+			if ctx.CacheManager.Tool() != "" {
+				report.Cache, cacheErr = ctx.CacheManager.Stats(cmd.Context())
+			}
+			if ctx.Printer.Structured() {
+				if cacheErr != nil {
+					ctx.Printer.Warn("%v", cacheErr)
 				}
+				return ctx.Printer.Emit(report)
+			}
+
+			ctx.Printer.Success("Workspace mounted at %s", report.MountPoint)
+			ctx.Printer.Print("")
+			ctx.Printer.Step("Volume info (%s):", report.Backend)
+			for _, line := range report.Volume {
+				ctx.Printer.Print("  %s", line)
+			}
+
+			ctx.Printer.Print("")
+			if ctx.CacheManager.Tool() == "" {
+				ctx.Printer.Step("Compiler cache: disabled")
+				return nil
+			}
+			ctx.Printer.Step("Compiler cache:")
+			if cacheErr != nil {
+				ctx.Printer.Warn("%v", cacheErr)
+				return nil
 			}
+			printCacheStats(ctx, report.Cache)
 
 			return nil
 		},
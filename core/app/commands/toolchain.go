@@ -5,6 +5,8 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/domain/toolchain"
 )
 
 // BuildToolchains creates the toolchains command tree for crosstool-ng management.
@@ -87,8 +89,31 @@ func buildToolchainStatusCmd(ctx *Context) *cobra.Command {
 	}
 }
 
+// toolchainStatus is the structured form of 'elmos toolchains status'.
+type toolchainStatus struct {
+	CrosstoolNG     bool                      `json:"crosstool_ng_installed" yaml:"crosstool_ng_installed"`
+	CrosstoolNGPath string                    `json:"crosstool_ng_path" yaml:"crosstool_ng_path"`
+	Toolchains      []toolchain.ToolchainInfo `json:"toolchains" yaml:"toolchains"`
+}
+
 // showToolchainStatus displays the toolchain installation status.
 func showToolchainStatus(ctx *Context) error {
+	if ctx.Printer.Structured() {
+		status := toolchainStatus{
+			CrosstoolNG:     ctx.ToolchainManager.IsInstalled(),
+			CrosstoolNGPath: ctx.ToolchainManager.Paths().CrosstoolNG,
+		}
+		if status.CrosstoolNG {
+			toolchains, err := ctx.ToolchainManager.GetInstalledToolchains()
+			if err != nil {
+				return err
+			}
+			status.Toolchains = toolchains
+		}
+		status.Toolchains = nonNil(status.Toolchains)
+		return ctx.Printer.Emit(status)
+	}
+
 	if !ctx.ToolchainManager.IsInstalled() {
 		ctx.Printer.Warn("crosstool-ng not installed")
 		ctx.Printer.Print("  Run: elmos toolchains install")
@@ -1,6 +1,9 @@
 package commands
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/spf13/cobra"
 
 	"github.com/NguyenTrongPhuc552003/elmos/core/ui/tui"
@@ -12,7 +15,30 @@ func BuildTUI(ctx *Context) *cobra.Command {
 		Use:   "tui",
 		Short: "Launch interactive Text User Interface",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return tui.Run()
+			return tui.Run(func() string { return watchStatusLine(ctx) })
 		},
 	}
 }
+
+// watchStatusLine summarizes the running watcher for the TUI footer.
+func watchStatusLine(ctx *Context) string {
+	status, err := ctx.Watcher.Status()
+	if err != nil || status == nil {
+		return ""
+	}
+	if status.Building != "" {
+		return fmt.Sprintf("watch: building %s", status.Building)
+	}
+	if len(status.Results) == 0 {
+		return "watch: waiting for changes"
+	}
+	parts := make([]string, 0, len(status.Results))
+	for _, r := range status.Results {
+		mark := "✓"
+		if !r.Success {
+			mark = "✗"
+		}
+		parts = append(parts, mark+" "+r.String())
+	}
+	return "watch: " + strings.Join(parts, " ")
+}
@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/domain/builder"
+	"github.com/NguyenTrongPhuc552003/elmos/core/domain/emulator"
+)
+
+// BuildWatch creates the watch command that rebuilds modules and apps on change.
+func BuildWatch(ctx *Context) *cobra.Command {
+	var reload bool
+	var hook, via, instance string
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Rebuild modules and apps when their sources change",
+		Long: `Watch the modules and apps directories and rebuild each module or app
+as soon as one of its sources changes. Only the affected module or app is
+rebuilt, and each build is reported as passed or failed. Press Ctrl+C to stop.
+
+With --reload, every module that builds is reloaded in the running QEMU
+instance (see 'elmos module reload'). With --hook, a shell command runs
+after every build with these variables set:
+
+  ELMOS_WATCH_KIND    module or app
+  ELMOS_WATCH_NAME    module or app name
+  ELMOS_WATCH_PATH    its directory
+  ELMOS_WATCH_STATUS  pass or fail
+
+The state of the watcher is shown by 'elmos watch status' and in the TUI.
+
+Examples:
+  elmos watch                       # Rebuild on change
+  elmos watch --reload              # Also reload modules in the guest
+  elmos watch --hook 'notify-send "$ELMOS_WATCH_NAME: $ELMOS_WATCH_STATUS"'`,
+		Args: cobra.NoArgs,
+		RunE: RunEWithContext(ctx, func(cmd *cobra.Command, args []string) error {
+			sigCtx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			opts := builder.WatchOptions{
+				OnBuild: func(t builder.WatchTarget) {
+					ctx.Printer.Step("Building %s %s...", t.Kind, t.Name)
+				},
+				OnResult: func(r builder.WatchResult) {
+					printWatchResult(ctx, r)
+					if reload && r.Success && r.Kind == builder.WatchModule {
+						ko := filepath.Join(ctx.Config.Paths.ModulesDir, r.Name, r.Name+".ko")
+						if err := ctx.QEMURunner.ReloadModule(sigCtx, instance, via, ko); err != nil {
+							ctx.Printer.Error("Reload %s: %v", r.Name, err)
+						}
+					}
+					if hook != "" {
+						runWatchHook(ctx, cmd, hook, r)
+					}
+				},
+			}
+
+			ctx.Printer.Info("Watching %s and %s (Ctrl+C to stop)", ctx.Config.Paths.ModulesDir, ctx.Config.Paths.AppsDir)
+			return ctx.Watcher.Watch(sigCtx, opts)
+		}),
+	}
+	cmd.Flags().BoolVar(&reload, "reload", false, "Reload rebuilt modules in the running QEMU instance")
+	cmd.Flags().StringVar(&hook, "hook", "", "Shell command to run after every build")
+	cmd.Flags().StringVar(&via, "via", emulator.GuestViaAuto, "How --reload reaches the guest: auto, ssh or agent")
+	cmd.Flags().StringVarP(&instance, "name", "n", "", "Instance for --reload (default \"default\")")
+
+	cmd.AddCommand(buildWatchStatusCmd(ctx))
+	return cmd
+}
+
+// buildWatchStatusCmd creates the watch status subcommand.
+func buildWatchStatusCmd(ctx *Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show the results of the running watcher",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			status, err := ctx.Watcher.Status()
+			if err != nil {
+				return err
+			}
+			if ctx.Printer.Structured() {
+				return ctx.Printer.Emit(map[string]interface{}{"running": status != nil, "watch": status})
+			}
+			if status == nil {
+				ctx.Printer.Info("No watcher running (start one with 'elmos watch')")
+				return nil
+			}
+			ctx.Printer.Print("Watcher:")
+			ctx.Printer.Print("  PID:      %d", status.PID)
+			ctx.Printer.Print("  Started:  %s", status.Started.Format("2006-01-02 15:04:05"))
+			ctx.Printer.Print("  Building: %s", valueOrDash(status.Building))
+			if len(status.Results) == 0 {
+				ctx.Printer.Print("\nNo builds yet")
+				return nil
+			}
+			ctx.Printer.Print("\nLast builds:")
+			for _, r := range status.Results {
+				printWatchResult(ctx, r)
+			}
+			return nil
+		},
+	}
+}
+
+// printWatchResult prints a pass/fail line for one watcher build.
+func printWatchResult(ctx *Context, r builder.WatchResult) {
+	at := r.Finished.Format("15:04:05")
+	took := time.Duration(r.Seconds * float64(time.Second)).Round(10 * time.Millisecond)
+	if r.Success {
+		ctx.Printer.Success("%s %s passed in %s (%s)", r.Kind, r.Name, took, at)
+		return
+	}
+	ctx.Printer.Error("%s %s failed in %s (%s): %s", r.Kind, r.Name, took, at, r.Error)
+}
+
+// runWatchHook runs the user's --hook command for a build result.
+func runWatchHook(ctx *Context, cmd *cobra.Command, hook string, r builder.WatchResult) {
+	dir := ctx.Config.Paths.ModulesDir
+	if r.Kind == builder.WatchApp {
+		dir = ctx.Config.Paths.AppsDir
+	}
+	status := "pass"
+	if !r.Success {
+		status = "fail"
+	}
+	env := []string{
+		"ELMOS_WATCH_KIND=" + r.Kind,
+		"ELMOS_WATCH_NAME=" + r.Name,
+		"ELMOS_WATCH_PATH=" + filepath.Join(dir, r.Name),
+		"ELMOS_WATCH_STATUS=" + status,
+	}
+	if err := ctx.Exec.RunWithEnv(cmd.Context(), env, "sh", "-c", hook); err != nil {
+		ctx.Printer.Warn("Hook failed: %v", err)
+	}
+}
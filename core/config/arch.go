@@ -1,34 +1,117 @@
 // Package config provides configuration management for elmos.
+// This file contains the architecture registry and its YAML loading.
 package config
 
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/NguyenTrongPhuc552003/elmos/assets"
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/kconfig"
+)
+
+// Architecture definition sources.
+const (
+	// ArchSourceBuiltin marks architectures compiled into elmos.
+	ArchSourceBuiltin = "builtin"
+	// ArchSourceConfig marks architectures declared under "arches:" in elmos.yaml.
+	ArchSourceConfig = "config"
+)
+
 // ArchConfig holds architecture-specific settings for building and emulation.
+// Definitions in YAML use the mapstructure/yaml tag names.
 type ArchConfig struct {
 	// Name is the architecture name (e.g., "arm64", "arm", "riscv").
-	Name string
+	Name string `mapstructure:"name" yaml:"name,omitempty"`
+	// Base is the architecture this definition inherits unset fields from.
+	Base string `mapstructure:"base" yaml:"base,omitempty"`
+	// Description is a short human-readable summary.
+	Description string `mapstructure:"description" yaml:"description,omitempty"`
 	// KernelArch is the kernel ARCH= value.
-	KernelArch string
+	KernelArch string `mapstructure:"kernel_arch" yaml:"kernel_arch,omitempty"`
 	// KernelImage is the output image name (e.g., "Image", "zImage").
-	KernelImage string
+	KernelImage string `mapstructure:"kernel_image" yaml:"kernel_image,omitempty"`
 	// DefaultTargets are the default build targets for this architecture.
-	DefaultTargets []string
+	DefaultTargets []string `mapstructure:"default_targets" yaml:"default_targets,omitempty"`
+	// Defconfig is the base config target (e.g., "rv32_defconfig"); empty means "defconfig".
+	Defconfig string `mapstructure:"defconfig" yaml:"defconfig,omitempty"`
 
 	// QEMU settings
-	QEMUBinary  string // e.g., "qemu-system-aarch64"
-	QEMUMachine string // e.g., "virt"
-	QEMUCPU     string // e.g., "cortex-a72"
-	QEMUBios    string // e.g., "-bios default" for RISC-V
-	Console     string // e.g., "ttyAMA0"
+	QEMUBinary    string `mapstructure:"qemu_binary" yaml:"qemu_binary,omitempty"`         // e.g., "qemu-system-aarch64"
+	QEMUMachine   string `mapstructure:"qemu_machine" yaml:"qemu_machine,omitempty"`       // e.g., "virt"
+	QEMUCPU       string `mapstructure:"qemu_cpu" yaml:"qemu_cpu,omitempty"`               // e.g., "cortex-a72"
+	QEMUBios      string `mapstructure:"qemu_bios" yaml:"qemu_bios,omitempty"`             // e.g., "-bios default" for RISC-V
+	QEMUNetDevice string `mapstructure:"qemu_net_device" yaml:"qemu_net_device,omitempty"` // e.g., "virtio-net-pci"
+	Console       string `mapstructure:"console" yaml:"console,omitempty"`                 // e.g., "ttyAMA0"
 
 	// Cross-compilation settings
-	GCCBinary    string // e.g., "aarch64-unknown-linux-gnu-gcc"
-	GDBBinary    string // e.g., "aarch64-unknown-linux-gnu-gdb"
-	ToolchainPkg string // Homebrew package for the toolchain
+	GCCBinary    string `mapstructure:"gcc_binary" yaml:"gcc_binary,omitempty"`       // e.g., "aarch64-unknown-linux-gnu-gcc"
+	GDBBinary    string `mapstructure:"gdb_binary" yaml:"gdb_binary,omitempty"`       // e.g., "aarch64-unknown-linux-gnu-gdb"
+	ToolchainPkg string `mapstructure:"toolchain_pkg" yaml:"toolchain_pkg,omitempty"` // Homebrew package for the toolchain
+
+	// Rootfs settings
+	DebianArch string `mapstructure:"debian_arch" yaml:"debian_arch,omitempty"` // e.g., "armhf"
+
+	// RequiredConfig lists kernel symbols the QEMU machine needs beyond the
+	// generic virtio/console set, as SYMBOL or SYMBOL=value.
+	RequiredConfig []string `mapstructure:"required_config" yaml:"required_config,omitempty"`
+
+	// Source is where the definition came from: "builtin", "config" or a file path.
+	Source string `mapstructure:"-" yaml:"-"`
+}
+
+// ArchDefinitionError reports an architecture definition that was skipped.
+type ArchDefinitionError struct {
+	Source string // File path, "builtin" or "config"
+	Name   string // Empty when the whole file could not be parsed
+	Err    error
+}
+
+// Error implements the error interface.
+func (e *ArchDefinitionError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("%s: %v", e.Source, e.Err)
+	}
+	return fmt.Sprintf("%s: architecture %q: %v", e.Source, e.Name, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ArchDefinitionError) Unwrap() error {
+	return e.Err
 }
 
-// Architectures contains all supported architecture configurations.
-var Architectures = map[string]*ArchConfig{
+// DefaultQEMUNetDevice is used when an architecture does not set qemu_net_device.
+const DefaultQEMUNetDevice = "virtio-net-device"
+
+// DefconfigTarget returns the make target that generates this architecture's default config.
+func (a *ArchConfig) DefconfigTarget() string {
+	if a.Defconfig != "" {
+		return a.Defconfig
+	}
+	return "defconfig"
+}
+
+// NetDevice returns the QEMU network device model for this architecture.
+func (a *ArchConfig) NetDevice() string {
+	if a.QEMUNetDevice != "" {
+		return a.QEMUNetDevice
+	}
+	return DefaultQEMUNetDevice
+}
+
+// builtinArchitectures are the architectures compiled into elmos.
+// Further definitions are embedded from assets/arches and merged on top.
+var builtinArchitectures = map[string]*ArchConfig{
 	"arm64": {
 		Name:           "arm64",
+		Description:    "ARM 64-bit (AArch64) on the QEMU virt board",
 		KernelArch:     "arm64",
 		KernelImage:    "Image",
 		DefaultTargets: []string{"Image", "dtbs", "modules"},
@@ -40,9 +123,12 @@ var Architectures = map[string]*ArchConfig{
 		GCCBinary:      "aarch64-unknown-linux-gnu-gcc",
 		GDBBinary:      "aarch64-unknown-linux-gnu-gdb",
 		ToolchainPkg:   "",
+		DebianArch:     "arm64",
+		RequiredConfig: []string{"PCI_HOST_GENERIC=y"},
 	},
 	"arm": {
 		Name:           "arm",
+		Description:    "ARM 32-bit (ARMv7-A) on the QEMU virt board",
 		KernelArch:     "arm",
 		KernelImage:    "zImage",
 		DefaultTargets: []string{"zImage", "dtbs", "modules"},
@@ -54,9 +140,12 @@ var Architectures = map[string]*ArchConfig{
 		GCCBinary:      "arm-cortex_a15-linux-gnueabihf-gcc",
 		GDBBinary:      "arm-cortex_a15-linux-gnueabihf-gdb",
 		ToolchainPkg:   "",
+		DebianArch:     "armhf",
+		RequiredConfig: []string{"PCI_HOST_GENERIC=y"},
 	},
 	"riscv": {
 		Name:           "riscv",
+		Description:    "RISC-V 64-bit on the QEMU virt board",
 		KernelArch:     "riscv",
 		KernelImage:    "Image",
 		DefaultTargets: []string{"Image", "dtbs", "modules"},
@@ -68,21 +157,69 @@ var Architectures = map[string]*ArchConfig{
 		GCCBinary:      "riscv64-unknown-linux-gnu-gcc",
 		GDBBinary:      "riscv64-unknown-linux-gnu-gdb",
 		ToolchainPkg:   "", // Optional, uses LLVM
+		DebianArch:     "riscv64",
+		RequiredConfig: []string{"PCI_HOST_GENERIC=y", "SERIAL_OF_PLATFORM=y"},
+	},
+	"x86_64": {
+		Name:        "x86_64",
+		Description: "x86 64-bit on the QEMU q35 machine",
+		// ARCH=x86_64 selects x86_64_defconfig regardless of the host;
+		// the build links arch/x86_64/boot/bzImage to arch/x86/boot/bzImage.
+		KernelArch:     "x86_64",
+		KernelImage:    "bzImage",
+		DefaultTargets: []string{"bzImage", "modules"},
+		QEMUBinary:     "qemu-system-x86_64",
+		QEMUMachine:    "q35",
+		QEMUCPU:        "max",
+		QEMUBios:       "",
+		QEMUNetDevice:  "virtio-net-pci",
+		Console:        "ttyS0",
+		GCCBinary:      "x86_64-unknown-linux-gnu-gcc",
+		GDBBinary:      "x86_64-unknown-linux-gnu-gdb",
+		ToolchainPkg:   "",
+		DebianArch:     "amd64",
 	},
 }
 
+// Architectures contains all available architecture configurations.
+// It holds the built-ins until LoadArchitectures merges user definitions.
+var Architectures = copyArchitectures(builtinArchitectures)
+
+// archDefinitionErrors collects invalid definitions found by the last LoadArchitectures.
+var archDefinitionErrors []*ArchDefinitionError
+
+// archNamePattern restricts architecture names to simple identifiers.
+var archNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+// KernelArches lists the kernel ARCH= values accepted in architecture definitions.
+var KernelArches = []string{
+	"arm", "arm64", "i386", "loongarch", "mips", "powerpc", "riscv", "s390", "sparc", "um", "x86", "x86_64",
+}
+
+// SrcArch returns the arch/ directory in the kernel tree for this architecture.
+// ARCH=x86_64 and ARCH=i386 are aliases that build from arch/x86.
+func (a *ArchConfig) SrcArch() string {
+	switch a.KernelArch {
+	case "x86_64", "i386":
+		return "x86"
+	default:
+		return a.KernelArch
+	}
+}
+
 // GetArchConfig returns the configuration for the specified architecture.
 // Returns nil if the architecture is not supported.
 func GetArchConfig(arch string) *ArchConfig {
 	return Architectures[arch]
 }
 
-// SupportedArchitectures returns a list of supported architecture names.
+// SupportedArchitectures returns the supported architecture names in sorted order.
 func SupportedArchitectures() []string {
 	archs := make([]string, 0, len(Architectures))
 	for name := range Architectures {
 		archs = append(archs, name)
 	}
+	sort.Strings(archs)
 	return archs
 }
 
@@ -91,3 +228,284 @@ func IsValidArch(arch string) bool {
 	_, ok := Architectures[arch]
 	return ok
 }
+
+// ArchDefinitionErrors returns the invalid architecture definitions skipped by the last load.
+func ArchDefinitionErrors() []*ArchDefinitionError {
+	return archDefinitionErrors
+}
+
+// ArchDirs returns the arches.d directories searched for architecture definitions,
+// in increasing order of precedence.
+func (cfg *Config) ArchDirs() []string {
+	var dirs []string
+	add := func(dir string) {
+		for _, d := range dirs {
+			if d == dir {
+				return
+			}
+		}
+		dirs = append(dirs, dir)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		add(filepath.Join(home, ".config", "elmos", "arches.d"))
+	}
+	if cfg.ConfigFile != "" {
+		if abs, err := filepath.Abs(filepath.Dir(cfg.ConfigFile)); err == nil {
+			add(filepath.Join(abs, "arches.d"))
+		}
+	}
+	if cfg.Paths.ProjectRoot != "" {
+		add(filepath.Join(cfg.Paths.ProjectRoot, "arches.d"))
+	}
+	return dirs
+}
+
+// LoadArchitectures rebuilds the architecture registry.
+// Built-ins come first, then embedded definitions, then *.yaml files from each
+// arches.d directory and finally the "arches:" section of elmos.yaml.
+// Invalid definitions are skipped and reported by ArchDefinitionErrors.
+func LoadArchitectures(cfg *Config) {
+	registry := copyArchitectures(builtinArchitectures)
+	archDefinitionErrors = nil
+
+	if files, err := assets.GetArchDefinitions(); err == nil {
+		for _, name := range sortedKeys(files) {
+			mergeArchFile(registry, files[name], ArchSourceBuiltin)
+		}
+	}
+
+	for _, dir := range cfg.ArchDirs() {
+		matches, _ := filepath.Glob(filepath.Join(dir, "*.yaml"))
+		more, _ := filepath.Glob(filepath.Join(dir, "*.yml"))
+		matches = append(matches, more...)
+		sort.Strings(matches)
+		for _, path := range matches {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				archDefinitionErrors = append(archDefinitionErrors, &ArchDefinitionError{Source: path, Err: err})
+				continue
+			}
+			mergeArchFile(registry, data, path)
+		}
+	}
+
+	for _, name := range sortedKeys(cfg.Arches) {
+		if err := mergeArch(registry, name, cfg.Arches[name], ArchSourceConfig); err != nil {
+			archDefinitionErrors = append(archDefinitionErrors, err)
+		}
+	}
+
+	Architectures = registry
+}
+
+// mergeArchFile merges a YAML file mapping architecture names to definitions.
+func mergeArchFile(registry map[string]*ArchConfig, data []byte, source string) {
+	var defs map[string]ArchConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&defs); err != nil {
+		archDefinitionErrors = append(archDefinitionErrors, &ArchDefinitionError{Source: source, Err: err})
+		return
+	}
+	for _, name := range sortedKeys(defs) {
+		if err := mergeArch(registry, name, defs[name], source); err != nil {
+			archDefinitionErrors = append(archDefinitionErrors, err)
+		}
+	}
+}
+
+// ValidateArchDefinitions merges definitions over a copy of the current registry
+// and returns one error per invalid definition.
+func ValidateArchDefinitions(defs map[string]ArchConfig) map[string]*ArchDefinitionError {
+	registry := copyArchitectures(Architectures)
+	errs := make(map[string]*ArchDefinitionError)
+	for _, name := range sortedKeys(defs) {
+		if err := mergeArch(registry, name, defs[name], ArchSourceConfig); err != nil {
+			errs[name] = err
+		}
+	}
+	return errs
+}
+
+// mergeArch overlays a definition on its base (or an existing entry of the same name),
+// validates the result and adds it to the registry.
+func mergeArch(registry map[string]*ArchConfig, name string, def ArchConfig, source string) *ArchDefinitionError {
+	fail := func(format string, args ...interface{}) *ArchDefinitionError {
+		return &ArchDefinitionError{Source: source, Name: name, Err: fmt.Errorf(format, args...)}
+	}
+
+	if def.Name != "" && def.Name != name {
+		return fail("name field %q does not match its key", def.Name)
+	}
+	if !archNamePattern.MatchString(name) {
+		return fail("invalid name (use lowercase letters, digits, '.', '_' and '-')")
+	}
+
+	merged := &ArchConfig{}
+	switch {
+	case def.Base != "":
+		base, ok := registry[def.Base]
+		if !ok {
+			return fail("unknown base architecture %q", def.Base)
+		}
+		*merged = *base
+		merged.Description = ""
+	case registry[name] != nil:
+		*merged = *registry[name]
+	}
+	overlayArch(merged, def)
+	merged.Name = name
+	merged.Source = source
+
+	if err := validateArch(merged); err != nil {
+		return &ArchDefinitionError{Source: source, Name: name, Err: err}
+	}
+	registry[name] = merged
+	return nil
+}
+
+// overlayArch copies the non-empty fields of def onto dst.
+func overlayArch(dst *ArchConfig, def ArchConfig) {
+	fields := []struct {
+		dst *string
+		src string
+	}{
+		{&dst.Base, def.Base},
+		{&dst.Description, def.Description},
+		{&dst.KernelArch, def.KernelArch},
+		{&dst.KernelImage, def.KernelImage},
+		{&dst.Defconfig, def.Defconfig},
+		{&dst.QEMUBinary, def.QEMUBinary},
+		{&dst.QEMUMachine, def.QEMUMachine},
+		{&dst.QEMUCPU, def.QEMUCPU},
+		{&dst.QEMUBios, def.QEMUBios},
+		{&dst.QEMUNetDevice, def.QEMUNetDevice},
+		{&dst.Console, def.Console},
+		{&dst.GCCBinary, def.GCCBinary},
+		{&dst.GDBBinary, def.GDBBinary},
+		{&dst.ToolchainPkg, def.ToolchainPkg},
+		{&dst.DebianArch, def.DebianArch},
+	}
+	for _, f := range fields {
+		if f.src != "" {
+			*f.dst = f.src
+		}
+	}
+	if len(def.DefaultTargets) > 0 {
+		dst.DefaultTargets = append([]string(nil), def.DefaultTargets...)
+	}
+	if len(def.RequiredConfig) > 0 {
+		dst.RequiredConfig = append([]string(nil), def.RequiredConfig...)
+	}
+}
+
+// validateArch checks that a merged definition has everything needed to build and boot.
+func validateArch(a *ArchConfig) error {
+	var missing []string
+	for _, f := range []struct{ key, value string }{
+		{"kernel_arch", a.KernelArch},
+		{"kernel_image", a.KernelImage},
+		{"qemu_binary", a.QEMUBinary},
+		{"qemu_machine", a.QEMUMachine},
+		{"console", a.Console},
+	} {
+		if f.value == "" {
+			missing = append(missing, f.key)
+		}
+	}
+	if len(a.DefaultTargets) == 0 {
+		missing = append(missing, "default_targets")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required field(s): %s", strings.Join(missing, ", "))
+	}
+
+	known := false
+	for _, k := range KernelArches {
+		if a.KernelArch == k {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return fmt.Errorf("unknown kernel_arch %q (valid: %s)", a.KernelArch, strings.Join(KernelArches, ", "))
+	}
+	if strings.ContainsAny(a.QEMUMachine+a.QEMUCPU+a.Console, " \t") {
+		return fmt.Errorf("qemu_machine, qemu_cpu and console must not contain spaces")
+	}
+	for _, r := range a.RequiredConfig {
+		if _, err := kconfig.ParseRequirement(r); err != nil {
+			return fmt.Errorf("required_config: %w", err)
+		}
+	}
+	return nil
+}
+
+// IsValidBuildTarget checks a kernel make target against the generic list
+// and the targets declared by the current architecture.
+func (cfg *Config) IsValidBuildTarget(target string) bool {
+	if ValidBuildTargets[target] {
+		return true
+	}
+	archCfg := cfg.GetArchConfig()
+	if archCfg == nil {
+		return false
+	}
+	if target == archCfg.KernelImage {
+		return true
+	}
+	for _, t := range archCfg.DefaultTargets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// KernelArch returns the kernel ARCH= value for the current build architecture.
+func (cfg *Config) KernelArch() string {
+	if archCfg := cfg.GetArchConfig(); archCfg != nil {
+		return archCfg.KernelArch
+	}
+	return cfg.Build.Arch
+}
+
+// KernelOutDir returns the directory holding .config and the build output.
+// With build.out_of_tree it is <build_dir>/<arch> (<arch>-<profile> while a
+// profile is active), so each architecture keeps its own warm build tree;
+// otherwise the kernel builds in the source tree.
+func (cfg *Config) KernelOutDir() string {
+	if !cfg.Build.OutOfTree {
+		return cfg.Paths.KernelDir
+	}
+	name := cfg.Build.Arch
+	if cfg.ActiveProfile != "" {
+		name += "-" + cfg.ActiveProfile
+	}
+	return filepath.Join(cfg.Paths.BuildDir, name)
+}
+
+// copyArchitectures returns a deep copy of an architecture map.
+func copyArchitectures(src map[string]*ArchConfig) map[string]*ArchConfig {
+	dst := make(map[string]*ArchConfig, len(src))
+	for name, a := range src {
+		c := *a
+		c.DefaultTargets = append([]string(nil), a.DefaultTargets...)
+		c.RequiredConfig = append([]string(nil), a.RequiredConfig...)
+		if c.Source == "" {
+			c.Source = ArchSourceBuiltin
+		}
+		dst[name] = &c
+	}
+	return dst
+}
+
+// sortedKeys returns the keys of a string-keyed map in sorted order.
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,6 +1,13 @@
 // Package config provides configuration management for elmos.
 package config
 
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
 // Default values for configuration.
 const (
 	// DefaultImageSize is the default sparse image size.
@@ -25,6 +32,80 @@ const (
 	DefaultGlibcVersion = "2.42"
 )
 
+// Compiler cache tools.
+const (
+	// CacheToolAuto uses ccache, or sccache when only it is installed.
+	CacheToolAuto = "auto"
+	// CacheToolCcache wraps compilers with ccache.
+	CacheToolCcache = "ccache"
+	// CacheToolSccache wraps compilers with sccache.
+	CacheToolSccache = "sccache"
+	// CacheToolNone disables compiler caching.
+	CacheToolNone = "none"
+)
+
+// CacheTools lists all accepted cache.tool values.
+var CacheTools = []string{CacheToolAuto, CacheToolCcache, CacheToolSccache, CacheToolNone}
+
+// IsValidCacheTool checks if the given cache tool is supported.
+func IsValidCacheTool(tool string) bool {
+	for _, t := range CacheTools {
+		if t == tool {
+			return true
+		}
+	}
+	return false
+}
+
+// Workspace volume backends.
+const (
+	// VolumeBackendHdiutil is a case-sensitive APFS sparse image managed by hdiutil (macOS).
+	VolumeBackendHdiutil = "hdiutil"
+	// VolumeBackendLoop is an ext4 image attached through a loop device (Linux).
+	VolumeBackendLoop = "loop"
+	// VolumeBackendDir is a plain directory on the host filesystem (Linux).
+	VolumeBackendDir = "dir"
+)
+
+// VolumeBackends lists all supported workspace volume backends.
+var VolumeBackends = []string{VolumeBackendHdiutil, VolumeBackendLoop, VolumeBackendDir}
+
+// DefaultVolumeBackend returns the workspace volume backend for the host OS.
+// macOS uses hdiutil; Linux filesystems are already case-sensitive, so a plain directory suffices.
+func DefaultVolumeBackend() string {
+	if runtime.GOOS == "darwin" {
+		return VolumeBackendHdiutil
+	}
+	return VolumeBackendDir
+}
+
+// IsValidVolumeBackend checks if the given workspace volume backend is supported.
+func IsValidVolumeBackend(backend string) bool {
+	for _, b := range VolumeBackends {
+		if b == backend {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultImagePath returns the default backing image path for a workspace volume.
+func DefaultImagePath(projectRoot, volumeName, backend string) string {
+	ext := "sparseimage"
+	if backend != VolumeBackendHdiutil {
+		ext = "img"
+	}
+	return filepath.Join(projectRoot, "data", fmt.Sprintf("%s.%s", volumeName, ext))
+}
+
+// DefaultMountPoint returns the default mount point for a workspace volume.
+func DefaultMountPoint(projectRoot, volumeName, backend string) string {
+	if backend == VolumeBackendHdiutil {
+		return filepath.Join("/Volumes", volumeName)
+	}
+	return filepath.Join(projectRoot, "data", "volumes", volumeName)
+}
+
 // RequiredPackage represents a Homebrew package dependency.
 type RequiredPackage struct {
 	Name        string
@@ -46,6 +127,7 @@ var RequiredPackages = []RequiredPackage{
 	{"fakeroot", "Fake root for packaging", "Build Tools", true},
 	{"e2fsprogs", "ext4 filesystem tools", "Build Tools", true},
 	{"wget", "File downloader", "Build Tools", false},
+	{"ccache", "Compiler cache for faster rebuilds", "Build Tools", false},
 	{"coreutils", "GNU core utilities", "Build Tools", true},
 	{"go", "Go programming language", "Build Tools", true},
 	{"go-task", "Go task runner", "Build Tools", true},
@@ -76,6 +158,7 @@ var RequiredHeaders = []string{
 var ValidBuildTargets = map[string]bool{
 	"Image":           true,
 	"zImage":          true, // ARM32
+	"bzImage":         true, // x86
 	"dtbs":            true,
 	"modules":         true,
 	"modules_prepare": true,
@@ -84,6 +167,7 @@ var ValidBuildTargets = map[string]bool{
 }
 
 // KernelConfigTypes lists valid kernel configuration types.
+// Named <name>_defconfig targets are accepted as well (see IsValidKernelConfigType).
 var KernelConfigTypes = []string{
 	"defconfig",
 	"tinyconfig",
@@ -99,3 +183,17 @@ var KernelConfigTypes = []string{
 	"localmodconfig",
 	"localyesconfig",
 }
+
+// IsValidKernelConfigType checks if configType is a known config target
+// or a named defconfig such as "rv32_defconfig".
+func IsValidKernelConfigType(configType string) bool {
+	if strings.HasSuffix(configType, "_defconfig") {
+		return true
+	}
+	for _, ct := range KernelConfigTypes {
+		if ct == configType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,383 @@
+// Package config provides configuration management for elmos.
+// This file contains dotted-key access and validation of configuration values.
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// KeyAliases maps short key names to their full dotted keys.
+var KeyAliases = map[string]string{
+	"arch":          "build.arch",
+	"jobs":          "build.jobs",
+	"llvm":          "build.llvm",
+	"cross_compile": "build.cross_compile",
+	"fragments":     "build.fragments",
+	"memory":        "qemu.memory",
+	"smp":           "qemu.smp",
+	"gdb_port":      "qemu.gdb_port",
+	"ssh_port":      "qemu.ssh_port",
+	"backend":       "image.backend",
+	"size":          "image.size",
+}
+
+// Setting is a single flattened configuration key and its value.
+type Setting struct {
+	Key   string
+	Value string
+}
+
+// ValidationIssue describes a problem found in a configuration file.
+type ValidationIssue struct {
+	Key     string
+	Message string
+}
+
+var (
+	sizePattern   = regexp.MustCompile(`^[0-9]+[MmGgTt]$`)
+	memoryPattern = regexp.MustCompile(`^[0-9]+[MmGg]?$`)
+)
+
+// leafValidators validates values by the last segment of their key.
+// Profile fields share the validators of the settings they override.
+var leafValidators = map[string]func(string) error{
+	"arch": func(v string) error {
+		if !IsValidArch(v) {
+			return fmt.Errorf("unsupported architecture %q (valid: %s)", v, strings.Join(SupportedArchitectures(), ", "))
+		}
+		return nil
+	},
+	"backend": func(v string) error {
+		if !IsValidVolumeBackend(v) {
+			return fmt.Errorf("unknown volume backend %q (valid: %s)", v, strings.Join(VolumeBackends, ", "))
+		}
+		return nil
+	},
+	"size": func(v string) error {
+		if !sizePattern.MatchString(v) {
+			return fmt.Errorf("invalid size %q (expected format: 40G, 512M, 1T)", v)
+		}
+		return nil
+	},
+	"memory": func(v string) error {
+		if !memoryPattern.MatchString(v) {
+			return fmt.Errorf("invalid memory %q (expected format: 2G, 2048M)", v)
+		}
+		return nil
+	},
+	"tool": func(v string) error {
+		if !IsValidCacheTool(v) {
+			return fmt.Errorf("unknown cache tool %q (valid: %s)", v, strings.Join(CacheTools, ", "))
+		}
+		return nil
+	},
+	"jobs":     validatePositive,
+	"smp":      validatePositive,
+	"gdb_port": validatePort,
+	"ssh_port": validatePort,
+}
+
+// validatePositive checks that an integer value is at least 1.
+func validatePositive(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return fmt.Errorf("must be a positive integer, got %q", v)
+	}
+	return nil
+}
+
+// validatePort checks that an integer value is a valid TCP port.
+func validatePort(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("must be a port between 1 and 65535, got %q", v)
+	}
+	return nil
+}
+
+// ResolveKey expands a short alias into its full dotted key.
+func ResolveKey(key string) string {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if full, ok := KeyAliases[key]; ok {
+		return full
+	}
+	return key
+}
+
+// ValidateValue validates a raw string value for the given dotted key.
+func ValidateValue(key, value string) error {
+	path := strings.Split(ResolveKey(key), ".")
+	if validate, ok := leafValidators[path[len(path)-1]]; ok && value != "" {
+		return validate(value)
+	}
+	return nil
+}
+
+// GetValue returns the string form of the value at a dotted key.
+func (cfg *Config) GetValue(key string) (string, error) {
+	key = ResolveKey(key)
+	v, err := lookupPath(reflect.ValueOf(cfg).Elem(), strings.Split(key, "."))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", key, err)
+	}
+	return formatValue(v), nil
+}
+
+// SetValue parses and validates values, then stores them at a dotted key.
+// List keys accept several values or a single comma-separated value.
+func (cfg *Config) SetValue(key string, values ...string) error {
+	key = ResolveKey(key)
+	if len(values) == 1 {
+		if err := ValidateValue(key, values[0]); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	if err := setPath(reflect.ValueOf(cfg).Elem(), strings.Split(key, "."), values); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	cfg.releaseOverlayKey(key)
+	return nil
+}
+
+// Settings returns all configuration keys and values in sorted order.
+func (cfg *Config) Settings() []Setting {
+	var settings []Setting
+	flattenValue(reflect.ValueOf(cfg).Elem(), "", &settings)
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Key < settings[j].Key })
+	return settings
+}
+
+// ValidateFile checks a config file for unknown keys, type errors and invalid values.
+func ValidateFile(path string) ([]ValidationIssue, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	var issues []ValidationIssue
+	scratch := &Config{}
+	for _, key := range v.AllKeys() {
+		raw := v.Get(key)
+		if raw == nil {
+			continue // empty section, e.g. "image:" with every key commented out
+		}
+		if m, ok := raw.(map[string]interface{}); ok && len(m) == 0 {
+			continue // empty section, e.g. "profiles: {}"
+		}
+
+		path := strings.Split(key, ".")
+		if !isKnownPath(reflect.TypeOf(*scratch), path) {
+			issues = append(issues, ValidationIssue{Key: key, Message: "unknown key"})
+			continue
+		}
+
+		values := rawToStrings(raw)
+		if err := setPath(reflect.ValueOf(scratch).Elem(), path, values); err != nil {
+			issues = append(issues, ValidationIssue{Key: key, Message: err.Error()})
+			continue
+		}
+		if len(values) == 1 {
+			if err := ValidateValue(key, values[0]); err != nil {
+				issues = append(issues, ValidationIssue{Key: key, Message: err.Error()})
+			}
+		}
+	}
+
+	// Architecture definitions are only meaningful once merged with their base
+	archErrs := ValidateArchDefinitions(scratch.Arches)
+	for _, name := range sortedKeys(archErrs) {
+		issues = append(issues, ValidationIssue{Key: "arches." + name, Message: archErrs[name].Err.Error()})
+	}
+	return issues, nil
+}
+
+// fieldByTag returns the struct field whose mapstructure tag matches name.
+func fieldByTag(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("mapstructure"); tag != "" && tag == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// lookupPath walks a value along a dotted key path.
+func lookupPath(v reflect.Value, path []string) (reflect.Value, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		f, ok := fieldByTag(v, path[0])
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("unknown key")
+		}
+		return lookupPath(f, path[1:])
+	case reflect.Map:
+		elem := v.MapIndex(reflect.ValueOf(path[0]))
+		if !elem.IsValid() {
+			return reflect.Value{}, fmt.Errorf("%q not found", path[0])
+		}
+		return lookupPath(elem, path[1:])
+	default:
+		return reflect.Value{}, fmt.Errorf("unknown key")
+	}
+}
+
+// setPath walks a value along a dotted key path and assigns the leaf.
+// Map entries are copied, modified and stored back since they are not addressable.
+func setPath(v reflect.Value, path []string, values []string) error {
+	if len(path) == 0 {
+		return assignValue(v, values)
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		f, ok := fieldByTag(v, path[0])
+		if !ok {
+			return fmt.Errorf("unknown key")
+		}
+		return setPath(f, path[1:], values)
+	case reflect.Map:
+		if len(path) < 2 {
+			return fmt.Errorf("cannot set a whole section, use a full key")
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		mapKey := reflect.ValueOf(path[0])
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(mapKey); existing.IsValid() {
+			elem.Set(existing)
+		}
+		if err := setPath(elem, path[1:], values); err != nil {
+			return err
+		}
+		v.SetMapIndex(mapKey, elem)
+		return nil
+	default:
+		return fmt.Errorf("unknown key")
+	}
+}
+
+// assignValue parses string values into a leaf field according to its kind.
+func assignValue(v reflect.Value, values []string) error {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String {
+		var items []string
+		for _, val := range values {
+			for _, item := range strings.Split(val, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+		return nil
+	}
+
+	if len(values) != 1 {
+		return fmt.Errorf("expected a single value, got %d", len(values))
+	}
+	raw := values[0]
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %q", raw)
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("expected true or false, got %q", raw)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("cannot set a whole section, use a full key")
+	}
+	return nil
+}
+
+// formatValue returns the string form of a leaf value.
+func formatValue(v reflect.Value) string {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String {
+		return strings.Join(v.Interface().([]string), ",")
+	}
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+// flattenValue collects leaf values under prefix into settings.
+func flattenValue(v reflect.Value, prefix string, settings *[]Setting) {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			tag := t.Field(i).Tag.Get("mapstructure")
+			if tag == "" {
+				continue
+			}
+			flattenValue(v.Field(i), joinKey(prefix, tag), settings)
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			flattenValue(v.MapIndex(k), joinKey(prefix, k.String()), settings)
+		}
+	default:
+		*settings = append(*settings, Setting{Key: prefix, Value: formatValue(v)})
+	}
+}
+
+// isKnownPath checks a dotted key path against the Config type.
+func isKnownPath(t reflect.Type, path []string) bool {
+	if len(path) == 0 {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).Tag.Get("mapstructure") == path[0] {
+				return isKnownPath(t.Field(i).Type, path[1:])
+			}
+		}
+		return false
+	case reflect.Map:
+		return isKnownPath(t.Elem(), path[1:])
+	default:
+		return false
+	}
+}
+
+// rawToStrings converts a value decoded by viper into string values.
+func rawToStrings(raw interface{}) []string {
+	switch val := raw.(type) {
+	case []interface{}:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			out = append(out, fmt.Sprintf("%v", item))
+		}
+		return out
+	case []string:
+		return val
+	default:
+		return []string{fmt.Sprintf("%v", val)}
+	}
+}
+
+// joinKey joins a key prefix and a segment with a dot.
+func joinKey(prefix, segment string) string {
+	if prefix == "" {
+		return segment
+	}
+	return prefix + "." + segment
+}
@@ -80,6 +80,9 @@ func Load(configPath string) (*Config, error) {
 	// Save which file was used
 	cfg.ConfigFile = v.ConfigFileUsed()
 
+	// Merge custom architectures over the built-ins
+	LoadArchitectures(cfg)
+
 	// Update singleton
 	configInstance = cfg
 	return cfg, loadErr
@@ -105,6 +108,7 @@ func setDefaults(v *viper.Viper) {
 	// Image defaults
 	v.SetDefault("image.volume_name", DefaultVolumeName)
 	v.SetDefault("image.size", DefaultImageSize)
+	v.SetDefault("image.backend", DefaultVolumeBackend())
 
 	// Build defaults
 	v.SetDefault("build.arch", DefaultArch)
@@ -120,6 +124,9 @@ func setDefaults(v *viper.Viper) {
 
 	// Paths defaults
 	v.SetDefault("paths.debian_mirror", DefaultDebianMirror)
+
+	// Cache defaults
+	v.SetDefault("cache.tool", CacheToolAuto)
 }
 
 // applyComputedDefaults fills in paths based on project root.
@@ -141,11 +148,14 @@ func applyProjectRoot(cfg *Config) {
 // applyImageDefaults sets image-related defaults.
 func applyImageDefaults(cfg *Config) {
 	root := cfg.Paths.ProjectRoot
+	if cfg.Image.Backend == "" {
+		cfg.Image.Backend = DefaultVolumeBackend()
+	}
 	if cfg.Image.Path == "" {
-		cfg.Image.Path = filepath.Join(root, "data", fmt.Sprintf("%s.sparseimage", cfg.Image.VolumeName))
+		cfg.Image.Path = DefaultImagePath(root, cfg.Image.VolumeName, cfg.Image.Backend)
 	}
 	if cfg.Image.MountPoint == "" {
-		cfg.Image.MountPoint = filepath.Join("/Volumes", cfg.Image.VolumeName)
+		cfg.Image.MountPoint = DefaultMountPoint(root, cfg.Image.VolumeName, cfg.Image.Backend)
 	}
 }
 
@@ -159,9 +169,12 @@ func applyPathDefaults(cfg *Config) {
 	setIfEmpty(&cfg.Paths.AppsDir, filepath.Join(root, "examples", "apps"))
 	setIfEmpty(&cfg.Paths.LibrariesDir, filepath.Join(root, "assets", "libraries"))
 	setIfEmpty(&cfg.Paths.PatchesDir, filepath.Join(root, "patches"))
+	setIfEmpty(&cfg.Paths.FragmentsDir, filepath.Join(root, "fragments"))
+	setIfEmpty(&cfg.Paths.BuildDir, filepath.Join(mount, "build"))
 	setIfEmpty(&cfg.Paths.RootfsDir, filepath.Join(mount, "rootfs"))
 	setIfEmpty(&cfg.Paths.DiskImage, filepath.Join(mount, "disk.img"))
 	setIfEmpty(&cfg.Paths.ToolchainsDir, filepath.Join(mount, "toolchains"))
+	setIfEmpty(&cfg.Cache.Dir, filepath.Join(mount, "cache"))
 }
 
 // setIfEmpty sets the target to value if target is empty.
@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -22,7 +25,14 @@ func (cfg *Config) Save(path string) error {
 	v.Set("build", saveCfg.Build)
 	v.Set("qemu", saveCfg.QEMU)
 	v.Set("paths", saveCfg.Paths)
+	v.Set("cache", saveCfg.Cache)
 	v.Set("profiles", saveCfg.Profiles)
+	if len(saveCfg.Arches) > 0 {
+		v.Set("arches", saveCfg.Arches)
+	}
+	if saveCfg.ActiveProfile != "" {
+		v.Set("active_profile", saveCfg.ActiveProfile)
+	}
 
 	if err := ensureDir(filepath.Dir(path)); err != nil {
 		return err
@@ -41,15 +51,26 @@ func (cfg *Config) computeDefaults() *Config {
 	if cfg.Paths.ProjectRoot != "" {
 		defaults.Paths.ProjectRoot = cfg.Paths.ProjectRoot
 	}
+	defaults.Image.VolumeName = cfg.Image.VolumeName
+	// Image paths depend on the configured backend, but the backend itself
+	// is compared against the host default so it is never pinned in elmos.yaml.
+	defaults.Image.Backend = cfg.Image.Backend
 	applyComputedDefaults(defaults)
+	defaults.Image.Backend = DefaultVolumeBackend()
 	return defaults
 }
 
 // prepareForSave creates a copy with default values cleared.
 func (cfg *Config) prepareForSave(defaults *Config) Config {
 	saveCfg := *cfg
+	if cfg.overlay != nil {
+		saveCfg.dropOverlay(cfg.overlay)
+	}
 	saveCfg.Paths = clearDefaultPaths(cfg.Paths, defaults.Paths)
 	saveCfg.Image = clearDefaultImage(cfg.Image, defaults.Image)
+	if cfg.Cache.Dir == defaults.Cache.Dir {
+		saveCfg.Cache.Dir = ""
+	}
 	return saveCfg
 }
 
@@ -74,18 +95,30 @@ func clearDefaultPaths(paths, defaults PathsConfig) PathsConfig {
 	if paths.PatchesDir == defaults.PatchesDir {
 		result.PatchesDir = ""
 	}
+	if paths.FragmentsDir == defaults.FragmentsDir {
+		result.FragmentsDir = ""
+	}
+	if paths.BuildDir == defaults.BuildDir {
+		result.BuildDir = ""
+	}
 	if paths.RootfsDir == defaults.RootfsDir {
 		result.RootfsDir = ""
 	}
 	if paths.DiskImage == defaults.DiskImage {
 		result.DiskImage = ""
 	}
+	if paths.ToolchainsDir == defaults.ToolchainsDir {
+		result.ToolchainsDir = ""
+	}
 	return result
 }
 
 // clearDefaultImage clears image values that match defaults.
 func clearDefaultImage(image, defaults ImageConfig) ImageConfig {
 	result := image
+	if image.Backend == defaults.Backend {
+		result.Backend = ""
+	}
 	if image.Path == defaults.Path {
 		result.Path = ""
 	}
@@ -104,12 +137,51 @@ func ensureDir(dir string) error {
 }
 
 // ApplyProfile applies a named profile to the current configuration.
+// Only fields set in the profile override the current values.
 func (cfg *Config) ApplyProfile(name string) error {
 	profile, ok := cfg.Profiles[name]
 	if !ok {
 		return fmt.Errorf("profile not found: %s", name)
 	}
 
+	// A persistent profile replaces any --profile overlay rather than stacking on it
+	if cfg.overlay != nil {
+		cfg.dropOverlay(cfg.overlay)
+		cfg.overlay = nil
+	}
+	cfg.applyProfileSettings(profile)
+	cfg.ActiveProfile = name
+	return nil
+}
+
+// OverlayProfile applies a named profile for the current invocation only.
+// Save writes back the values the profile replaced, so the overlay never
+// reaches elmos.yaml unless the setting is set again with SetValue.
+func (cfg *Config) OverlayProfile(name string) error {
+	profile, ok := cfg.Profiles[name]
+	if !ok {
+		return fmt.Errorf("profile not found: %s", name)
+	}
+
+	if cfg.overlay == nil {
+		cfg.overlay = &profileOverlay{
+			base:          cfg.SnapshotProfile(),
+			activeProfile: cfg.ActiveProfile,
+			keys:          make(map[string]bool),
+		}
+	}
+	for _, key := range profileKeys(profile) {
+		cfg.overlay.keys[key] = true
+	}
+	cfg.overlay.keys["active_profile"] = true
+
+	cfg.applyProfileSettings(profile)
+	cfg.ActiveProfile = name
+	return nil
+}
+
+// applyProfileSettings copies the fields set in a profile over the current values.
+func (cfg *Config) applyProfileSettings(profile ProfileConfig) {
 	if profile.Arch != "" {
 		cfg.Build.Arch = profile.Arch
 	}
@@ -122,8 +194,170 @@ func (cfg *Config) ApplyProfile(name string) error {
 	if profile.CrossCompile != "" {
 		cfg.Build.CrossCompile = profile.CrossCompile
 	}
+	if profile.SMP > 0 {
+		cfg.QEMU.SMP = profile.SMP
+	}
+	if profile.GDBPort > 0 {
+		cfg.QEMU.GDBPort = profile.GDBPort
+	}
+	if profile.SSHPort > 0 {
+		cfg.QEMU.SSHPort = profile.SSHPort
+	}
+	if len(profile.Fragments) > 0 {
+		cfg.Build.Fragments = append([]string(nil), profile.Fragments...)
+	}
+}
 
-	return nil
+// profileOverlay records the settings replaced by OverlayProfile.
+type profileOverlay struct {
+	base          ProfileConfig
+	activeProfile string
+	keys          map[string]bool // Dotted keys still holding overlay values
+}
+
+// profileKeys returns the dotted config keys a profile overrides.
+func profileKeys(p ProfileConfig) []string {
+	var keys []string
+	for _, f := range []struct {
+		key string
+		set bool
+	}{
+		{"build.arch", p.Arch != ""},
+		{"build.jobs", p.Jobs > 0},
+		{"qemu.memory", p.Memory != ""},
+		{"build.cross_compile", p.CrossCompile != ""},
+		{"qemu.smp", p.SMP > 0},
+		{"qemu.gdb_port", p.GDBPort > 0},
+		{"qemu.ssh_port", p.SSHPort > 0},
+		{"build.fragments", len(p.Fragments) > 0},
+	} {
+		if f.set {
+			keys = append(keys, f.key)
+		}
+	}
+	return keys
+}
+
+// releaseOverlayKey marks an explicitly set key as no longer coming from the
+// profile overlay, so that Save keeps its value.
+func (cfg *Config) releaseOverlayKey(key string) {
+	if cfg.overlay != nil {
+		delete(cfg.overlay.keys, key)
+	}
+}
+
+// dropOverlay restores the values replaced by a profile overlay.
+// Keys set explicitly since the overlay was applied are kept.
+func (cfg *Config) dropOverlay(o *profileOverlay) {
+	base := o.base
+	for key := range o.keys {
+		switch key {
+		case "build.arch":
+			cfg.Build.Arch = base.Arch
+		case "build.jobs":
+			cfg.Build.Jobs = base.Jobs
+		case "qemu.memory":
+			cfg.QEMU.Memory = base.Memory
+		case "build.cross_compile":
+			cfg.Build.CrossCompile = base.CrossCompile
+		case "qemu.smp":
+			cfg.QEMU.SMP = base.SMP
+		case "qemu.gdb_port":
+			cfg.QEMU.GDBPort = base.GDBPort
+		case "qemu.ssh_port":
+			cfg.QEMU.SSHPort = base.SSHPort
+		case "build.fragments":
+			cfg.Build.Fragments = base.Fragments
+		case "active_profile":
+			cfg.ActiveProfile = o.activeProfile
+		}
+	}
+}
+
+// SnapshotProfile captures the profile-managed settings of the current configuration.
+func (cfg *Config) SnapshotProfile() ProfileConfig {
+	return ProfileConfig{
+		Arch:         cfg.Build.Arch,
+		Jobs:         cfg.Build.Jobs,
+		Memory:       cfg.QEMU.Memory,
+		CrossCompile: cfg.Build.CrossCompile,
+		SMP:          cfg.QEMU.SMP,
+		GDBPort:      cfg.QEMU.GDBPort,
+		SSHPort:      cfg.QEMU.SSHPort,
+		Fragments:    append([]string(nil), cfg.Build.Fragments...),
+	}
+}
+
+// SaveProfile stores the current settings as a named profile.
+func (cfg *Config) SaveProfile(name string) {
+	if cfg.Profiles == nil {
+		cfg.Profiles = make(map[string]ProfileConfig)
+	}
+	cfg.Profiles[name] = cfg.SnapshotProfile()
+}
+
+// ProfileChange describes a single setting that differs between two profiles.
+type ProfileChange struct {
+	Key  string
+	From string
+	To   string
+}
+
+// DiffProfiles returns the settings that differ between two profiles.
+// Unset fields in either profile are reported as "(unset)".
+func DiffProfiles(from, to ProfileConfig) []ProfileChange {
+	fields := []struct {
+		key      string
+		from, to string
+	}{
+		{"arch", from.Arch, to.Arch},
+		{"jobs", formatProfileInt(from.Jobs), formatProfileInt(to.Jobs)},
+		{"memory", from.Memory, to.Memory},
+		{"cross_compile", from.CrossCompile, to.CrossCompile},
+		{"smp", formatProfileInt(from.SMP), formatProfileInt(to.SMP)},
+		{"gdb_port", formatProfileInt(from.GDBPort), formatProfileInt(to.GDBPort)},
+		{"ssh_port", formatProfileInt(from.SSHPort), formatProfileInt(to.SSHPort)},
+		{"fragments", strings.Join(from.Fragments, ","), strings.Join(to.Fragments, ",")},
+	}
+
+	var changes []ProfileChange
+	for _, f := range fields {
+		if f.from == f.to {
+			continue
+		}
+		changes = append(changes, ProfileChange{
+			Key:  f.key,
+			From: valueOrUnset(f.from),
+			To:   valueOrUnset(f.to),
+		})
+	}
+	return changes
+}
+
+// formatProfileInt formats an integer profile field, treating zero as unset.
+func formatProfileInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// valueOrUnset returns "(unset)" for empty values.
+func valueOrUnset(s string) string {
+	if s == "" {
+		return "(unset)"
+	}
+	return s
+}
+
+// ProfileNames returns the names of all profiles in sorted order.
+func (cfg *Config) ProfileNames() []string {
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 // GetArchConfig returns the architecture configuration for the current build arch.
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestSaveDropsProfileOverlay(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "elmos.yaml")
+
+	cfg := &Config{}
+	cfg.Paths.ProjectRoot = dir
+	cfg.Image.VolumeName = DefaultVolumeName
+	cfg.Build.Arch = "arm64"
+	cfg.QEMU.Memory = "2G"
+	cfg.QEMU.SMP = 4
+	cfg.Build.Jobs = 2
+	cfg.Profiles = map[string]ProfileConfig{
+		"dbg": {Arch: "riscv", Memory: "8G", SMP: 8, Jobs: 4},
+	}
+	applyComputedDefaults(cfg)
+
+	if err := cfg.OverlayProfile("dbg"); err != nil {
+		t.Fatalf("OverlayProfile: %v", err)
+	}
+	if cfg.Build.Arch != "riscv" || cfg.ActiveProfile != "dbg" {
+		t.Fatalf("overlay not applied: arch=%s active=%s", cfg.Build.Arch, cfg.ActiveProfile)
+	}
+
+	// 'elmos -p dbg config set smp 2' changes an overlaid value, and
+	// 'config set build.jobs 4' explicitly sets the value dbg already has
+	if err := cfg.SetValue("smp", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetValue("build.jobs", "4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Config
+	if err := yaml.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.ActiveProfile != "" {
+		t.Errorf("saved active_profile = %q, want none", saved.ActiveProfile)
+	}
+	if saved.Build.Arch != "arm64" || saved.QEMU.Memory != "2G" {
+		t.Errorf("saved arch=%s memory=%s, want arm64 and 2G", saved.Build.Arch, saved.QEMU.Memory)
+	}
+	if saved.QEMU.SMP != 2 {
+		t.Errorf("saved smp = %d, want the value set after the overlay (2)", saved.QEMU.SMP)
+	}
+	if saved.Build.Jobs != 4 {
+		t.Errorf("saved jobs = %d, want the explicitly set value (4)", saved.Build.Jobs)
+	}
+	if cfg.Build.Arch != "riscv" {
+		t.Error("Save modified the in-memory overlay")
+	}
+}
+
+func TestApplyProfileReplacesOverlay(t *testing.T) {
+	cfg := &Config{}
+	cfg.Build.Arch = "arm64"
+	cfg.QEMU.Memory = "2G"
+	cfg.Profiles = map[string]ProfileConfig{
+		"dbg": {Arch: "riscv", Memory: "8G"},
+		"rel": {Arch: "x86_64"},
+	}
+
+	if err := cfg.OverlayProfile("dbg"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.ApplyProfile("rel"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Build.Arch != "x86_64" || cfg.QEMU.Memory != "2G" || cfg.ActiveProfile != "rel" {
+		t.Errorf("got arch=%s memory=%s active=%s", cfg.Build.Arch, cfg.QEMU.Memory, cfg.ActiveProfile)
+	}
+}
@@ -3,68 +3,97 @@
 package config
 
 // Config holds the application configuration.
+// The yaml tags mirror the mapstructure tags so that Save round-trips through Load.
 type Config struct {
 	// ConfigFile is the path to the loaded configuration file
 	ConfigFile string `yaml:"-"`
 
 	// Image settings
-	Image ImageConfig `mapstructure:"image"`
+	Image ImageConfig `mapstructure:"image" yaml:"image"`
 
 	// Build settings
-	Build BuildConfig `mapstructure:"build"`
+	Build BuildConfig `mapstructure:"build" yaml:"build"`
 
 	// QEMU settings
-	QEMU QEMUConfig `mapstructure:"qemu"`
+	QEMU QEMUConfig `mapstructure:"qemu" yaml:"qemu"`
 
 	// Paths
-	Paths PathsConfig `mapstructure:"paths"`
+	Paths PathsConfig `mapstructure:"paths" yaml:"paths"`
+
+	// Compiler cache settings
+	Cache CacheConfig `mapstructure:"cache" yaml:"cache"`
 
 	// Profiles for different configurations
-	Profiles map[string]ProfileConfig `mapstructure:"profiles"`
+	Profiles map[string]ProfileConfig `mapstructure:"profiles" yaml:"profiles,omitempty"`
+
+	// Arches declares custom architectures and machine variants, merged over the built-ins
+	Arches map[string]ArchConfig `mapstructure:"arches" yaml:"arches,omitempty"`
+
+	// ActiveProfile is the name of the profile last applied with 'elmos profile use'
+	ActiveProfile string `mapstructure:"active_profile" yaml:"active_profile,omitempty"`
+
+	// overlay tracks a profile applied with --profile so Save can leave it out
+	overlay *profileOverlay
 }
 
 // ImageConfig holds disk image configuration.
 type ImageConfig struct {
-	Path       string `mapstructure:"path"`
-	VolumeName string `mapstructure:"volume_name"`
-	Size       string `mapstructure:"size"`
-	MountPoint string `mapstructure:"mount_point"`
+	Path       string `mapstructure:"path" yaml:"path,omitempty"`
+	VolumeName string `mapstructure:"volume_name" yaml:"volume_name,omitempty"`
+	Size       string `mapstructure:"size" yaml:"size,omitempty"`
+	MountPoint string `mapstructure:"mount_point" yaml:"mount_point,omitempty"`
+	Backend    string `mapstructure:"backend" yaml:"backend,omitempty"` // "hdiutil", "loop" or "dir"
 }
 
 // BuildConfig holds kernel build configuration.
 type BuildConfig struct {
-	Arch         string `mapstructure:"arch"`
-	Jobs         int    `mapstructure:"jobs"`
-	LLVM         bool   `mapstructure:"llvm"`
-	CrossCompile string `mapstructure:"cross_compile"`
+	Arch         string   `mapstructure:"arch" yaml:"arch,omitempty"`
+	Jobs         int      `mapstructure:"jobs" yaml:"jobs,omitempty"`
+	LLVM         bool     `mapstructure:"llvm" yaml:"llvm"`
+	CrossCompile string   `mapstructure:"cross_compile" yaml:"cross_compile,omitempty"`
+	Fragments    []string `mapstructure:"fragments" yaml:"fragments,omitempty"` // Kernel config fragments to merge
+	OutOfTree    bool     `mapstructure:"out_of_tree" yaml:"out_of_tree"`       // Build with O=<build_dir>/<arch>
 }
 
 // QEMUConfig holds QEMU configuration.
 type QEMUConfig struct {
-	Memory  string `mapstructure:"memory"`
-	GDBPort int    `mapstructure:"gdb_port"`
-	SSHPort int    `mapstructure:"ssh_port"`
-	SMP     int    `mapstructure:"smp"`
+	Memory  string `mapstructure:"memory" yaml:"memory,omitempty"`
+	GDBPort int    `mapstructure:"gdb_port" yaml:"gdb_port,omitempty"`
+	SSHPort int    `mapstructure:"ssh_port" yaml:"ssh_port,omitempty"`
+	SMP     int    `mapstructure:"smp" yaml:"smp,omitempty"`
 }
 
 // PathsConfig holds important paths.
 type PathsConfig struct {
-	ProjectRoot   string `mapstructure:"project_root"`
-	KernelDir     string `mapstructure:"kernel_dir"`
-	ModulesDir    string `mapstructure:"modules_dir"`
-	AppsDir       string `mapstructure:"apps_dir"`
-	LibrariesDir  string `mapstructure:"libraries_dir"`
-	PatchesDir    string `mapstructure:"patches_dir"`
-	RootfsDir     string `mapstructure:"rootfs_dir"`
-	DiskImage     string `mapstructure:"disk_image"`
-	DebianMirror  string `mapstructure:"debian_mirror"`
-	ToolchainsDir string `mapstructure:"toolchains_dir"`
+	ProjectRoot   string `mapstructure:"project_root" yaml:"project_root,omitempty"`
+	KernelDir     string `mapstructure:"kernel_dir" yaml:"kernel_dir,omitempty"`
+	ModulesDir    string `mapstructure:"modules_dir" yaml:"modules_dir,omitempty"`
+	AppsDir       string `mapstructure:"apps_dir" yaml:"apps_dir,omitempty"`
+	LibrariesDir  string `mapstructure:"libraries_dir" yaml:"libraries_dir,omitempty"`
+	PatchesDir    string `mapstructure:"patches_dir" yaml:"patches_dir,omitempty"`
+	FragmentsDir  string `mapstructure:"fragments_dir" yaml:"fragments_dir,omitempty"` // Team kernel config fragments
+	BuildDir      string `mapstructure:"build_dir" yaml:"build_dir,omitempty"`         // Out-of-tree kernel build trees
+	RootfsDir     string `mapstructure:"rootfs_dir" yaml:"rootfs_dir,omitempty"`
+	DiskImage     string `mapstructure:"disk_image" yaml:"disk_image,omitempty"`
+	DebianMirror  string `mapstructure:"debian_mirror" yaml:"debian_mirror,omitempty"`
+	ToolchainsDir string `mapstructure:"toolchains_dir" yaml:"toolchains_dir,omitempty"`
+}
+
+// CacheConfig holds compiler cache configuration.
+type CacheConfig struct {
+	Tool string `mapstructure:"tool" yaml:"tool,omitempty"` // "auto", "ccache", "sccache" or "none"
+	Dir  string `mapstructure:"dir" yaml:"dir,omitempty"`   // Cache directory (on the case-sensitive volume)
+	Size string `mapstructure:"size" yaml:"size,omitempty"` // Maximum cache size, e.g. "20G"
 }
 
 // ProfileConfig holds a named configuration profile.
 type ProfileConfig struct {
-	Arch         string `mapstructure:"arch"`
-	Jobs         int    `mapstructure:"jobs"`
-	Memory       string `mapstructure:"memory"`
-	CrossCompile string `mapstructure:"cross_compile"`
+	Arch         string   `mapstructure:"arch" yaml:"arch,omitempty"`
+	Jobs         int      `mapstructure:"jobs" yaml:"jobs,omitempty"`
+	Memory       string   `mapstructure:"memory" yaml:"memory,omitempty"`
+	CrossCompile string   `mapstructure:"cross_compile" yaml:"cross_compile,omitempty"`
+	SMP          int      `mapstructure:"smp" yaml:"smp,omitempty"`
+	GDBPort      int      `mapstructure:"gdb_port" yaml:"gdb_port,omitempty"`
+	SSHPort      int      `mapstructure:"ssh_port" yaml:"ssh_port,omitempty"`
+	Fragments    []string `mapstructure:"fragments" yaml:"fragments,omitempty"`
 }
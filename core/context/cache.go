@@ -0,0 +1,68 @@
+// Package context provides build context management for elmos.
+// This file contains compiler cache (ccache/sccache) resolution.
+package context
+
+import (
+	"path/filepath"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/config"
+)
+
+// CompilerCache returns the compiler cache wrapper to use ("ccache" or "sccache"),
+// or "" when caching is disabled or the configured tool is not installed.
+func (ctx *Context) CompilerCache() string {
+	var candidates []string
+	switch ctx.Config.Cache.Tool {
+	case config.CacheToolNone:
+		return ""
+	case config.CacheToolCcache, config.CacheToolSccache:
+		candidates = []string{ctx.Config.Cache.Tool}
+	default:
+		candidates = []string{config.CacheToolCcache, config.CacheToolSccache}
+	}
+	for _, tool := range candidates {
+		if _, err := ctx.Exec.LookPath(tool); err == nil {
+			return tool
+		}
+	}
+	return ""
+}
+
+// WrapCompiler prefixes a compiler with the compiler cache, if one is in use.
+func (ctx *Context) WrapCompiler(compiler string) string {
+	if tool := ctx.CompilerCache(); tool != "" {
+		return tool + " " + compiler
+	}
+	return compiler
+}
+
+// GetCacheDir returns the compiler cache directory on the workspace volume.
+func (ctx *Context) GetCacheDir() string {
+	return ctx.Config.Cache.Dir
+}
+
+// GetCacheEnv returns the environment variables that point the compiler cache
+// at the workspace volume. It is empty when no cache is in use.
+func (ctx *Context) GetCacheEnv() []string {
+	cfg := ctx.Config.Cache
+	switch ctx.CompilerCache() {
+	case config.CacheToolCcache:
+		env := []string{
+			"CCACHE_DIR=" + cfg.Dir,
+			// Hash paths relative to the volume so out-of-tree builds share hits
+			"CCACHE_BASEDIR=" + filepath.Dir(cfg.Dir),
+		}
+		if cfg.Size != "" {
+			env = append(env, "CCACHE_MAXSIZE="+cfg.Size)
+		}
+		return env
+	case config.CacheToolSccache:
+		env := []string{"SCCACHE_DIR=" + cfg.Dir}
+		if cfg.Size != "" {
+			env = append(env, "SCCACHE_CACHE_SIZE="+cfg.Size)
+		}
+		return env
+	default:
+		return nil
+	}
+}
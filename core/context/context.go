@@ -2,8 +2,6 @@
 package context
 
 import (
-	gocontext "context"
-	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -33,29 +31,19 @@ func New(cfg *config.Config, exec executor.Executor, fs filesystem.FileSystem) *
 	}
 }
 
+// Volume returns the workspace volume for the configured backend.
+// It is resolved on each call so config reloads are honoured.
+func (ctx *Context) Volume() (Volume, error) {
+	return NewVolume(ctx.Config, ctx.Exec, ctx.FS)
+}
+
 // IsMounted checks if the kernel volume is currently mounted.
 func (ctx *Context) IsMounted() bool {
-	mountPoint := ctx.Config.Image.MountPoint
-
-	// Check if the directory exists first
-	if !ctx.FS.IsDir(mountPoint) {
-		// It might be mounted at a different location
-		// Check hdiutil info for our image file path
-		out, err := ctx.Exec.Output(gocontext.Background(), "hdiutil", "info")
-		if err != nil {
-			return false
-		}
-		// Check if our image file is mounted (regardless of mount point)
-		return strings.Contains(string(out), ctx.Config.Image.Path)
-	}
-
-	// Verify it's actually a mount point using 'mount'
-	out, err := ctx.Exec.Output(gocontext.Background(), "mount")
+	vol, err := ctx.Volume()
 	if err != nil {
 		return false
 	}
-
-	return strings.Contains(string(out), mountPoint)
+	return vol.IsMounted()
 }
 
 // EnsureMounted ensures the kernel volume is mounted.
@@ -66,59 +54,14 @@ func (ctx *Context) EnsureMounted() error {
 	return nil
 }
 
-// GetMountPoint returns the actual mount point path of the kernel volume.
+// GetActualMountPoint returns the actual mount point path of the kernel volume.
 // This handles cases where the volume is mounted at a different location (e.g. " 1" suffix).
 func (ctx *Context) GetActualMountPoint() (string, error) {
-	mountPoint := ctx.Config.Image.MountPoint
-
-	// Fast path: if configured path exists
-	if ctx.FS.IsDir(mountPoint) {
-		return mountPoint, nil
-	}
-
-	// Slow path: check hdiutil info for our specific image file
-	out, err := ctx.Exec.Output(gocontext.Background(), "hdiutil", "info")
+	vol, err := ctx.Volume()
 	if err != nil {
 		return "", err
 	}
-
-	return parseMountPointFromHdiutil(string(out), ctx.Config.Image.Path)
-}
-
-// parseMountPointFromHdiutil extracts the mount point for an image from hdiutil info output.
-func parseMountPointFromHdiutil(output, imagePath string) (string, error) {
-	lines := strings.Split(output, "\n")
-
-	// Find the image block and look for mount point
-	foundImage := false
-	for i, line := range lines {
-		if strings.Contains(line, imagePath) {
-			foundImage = true
-			// Look for /Volumes/ in subsequent lines (up to 20 lines)
-			if mp := findMountPointInLines(lines, i+1, i+20); mp != "" {
-				return mp, nil
-			}
-			break
-		}
-	}
-
-	if !foundImage {
-		return "", fmt.Errorf("image not mounted: %s", imagePath)
-	}
-	return "", fmt.Errorf("volume not found")
-}
-
-// findMountPointInLines searches for a /Volumes/ path in a range of lines.
-func findMountPointInLines(lines []string, start, end int) string {
-	for j := start; j < len(lines) && j < end; j++ {
-		if idx := strings.Index(lines[j], "/Volumes/"); idx != -1 {
-			mountStr := strings.TrimSpace(lines[j][idx:])
-			if parts := strings.Fields(mountStr); len(parts) > 0 {
-				return parts[0]
-			}
-		}
-	}
-	return ""
+	return vol.MountPoint()
 }
 
 // KernelExists checks if the kernel source directory exists.
@@ -127,10 +70,25 @@ func (ctx *Context) KernelExists() bool {
 	return ctx.FS.Exists(gitDir)
 }
 
+// GetKernelOutDir returns the kernel build output directory for the current arch.
+func (ctx *Context) GetKernelOutDir() string {
+	return ctx.Config.KernelOutDir()
+}
+
+// GetKernelConfig returns the path of the kernel .config for the current arch.
+func (ctx *Context) GetKernelConfig() string {
+	return filepath.Join(ctx.GetKernelOutDir(), ".config")
+}
+
 // HasConfig checks if the kernel has been configured (.config exists).
 func (ctx *Context) HasConfig() bool {
-	configFile := filepath.Join(ctx.Config.Paths.KernelDir, ".config")
-	return ctx.FS.Exists(configFile)
+	return ctx.FS.Exists(ctx.GetKernelConfig())
+}
+
+// HasInTreeBuild reports whether the source tree itself has been configured,
+// which makes kbuild refuse out-of-tree (O=) builds until it is cleaned.
+func (ctx *Context) HasInTreeBuild() bool {
+	return ctx.Config.Build.OutOfTree && ctx.FS.Exists(filepath.Join(ctx.Config.Paths.KernelDir, ".config"))
 }
 
 // GetKernelImage returns the path to the built kernel image for the current arch.
@@ -139,12 +97,26 @@ func (ctx *Context) GetKernelImage() string {
 	if archCfg == nil {
 		return ""
 	}
-	return filepath.Join(ctx.Config.Paths.KernelDir, "arch", archCfg.KernelArch, "boot", archCfg.KernelImage)
+	// Some architectures boot the ELF vmlinux from the top of the tree
+	if archCfg.KernelImage == "vmlinux" {
+		return ctx.GetVmlinux()
+	}
+	return filepath.Join(ctx.GetKernelOutDir(), "arch", archCfg.SrcArch(), "boot", archCfg.KernelImage)
 }
 
 // GetVmlinux returns the path to vmlinux (for debugging).
 func (ctx *Context) GetVmlinux() string {
-	return filepath.Join(ctx.Config.Paths.KernelDir, "vmlinux")
+	return filepath.Join(ctx.GetKernelOutDir(), "vmlinux")
+}
+
+// GetLogDir returns the directory for build and console logs on the workspace volume.
+func (ctx *Context) GetLogDir() string {
+	return filepath.Join(ctx.Config.Image.MountPoint, "logs")
+}
+
+// GetRunDir returns the directory for runtime state such as QMP sockets.
+func (ctx *Context) GetRunDir() string {
+	return filepath.Join(ctx.Config.Image.MountPoint, "run")
 }
 
 // HasKernelImage checks if the kernel image has been built.
@@ -191,11 +163,14 @@ func (ctx *Context) GetMakeEnv() []string {
 
 	// Add build-specific environment
 	env = append(env,
-		"ARCH="+cfg.Build.Arch,
+		"ARCH="+cfg.KernelArch(),
 		"LLVM=1",
 		"CROSS_COMPILE="+cfg.Build.CrossCompile,
 	)
 
+	// Compiler cache location on the workspace volume
+	env = append(env, ctx.GetCacheEnv()...)
+
 	// Add HOSTCFLAGS for macOS compatibility
 	hostcflags := ctx.buildHostCFlags()
 	if hostcflags != "" {
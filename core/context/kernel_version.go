@@ -0,0 +1,101 @@
+// Package context provides build context management for elmos.
+// This file contains detection of the checked-out kernel version.
+package context
+
+import (
+	gocontext "context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// KernelVersion is a kernel release such as 6.18.3-rc1.
+type KernelVersion struct {
+	Major int
+	Minor int
+	Patch int    // SUBLEVEL, 0 when absent
+	Extra string // EXTRAVERSION or git describe suffix, e.g. "-rc1"
+}
+
+// kernelVersionRe matches "v6.18", "6.18.3", "v6.18-rc1" and "v6.7-123-gabcdef".
+var kernelVersionRe = regexp.MustCompile(`^v?(\d+)\.(\d+)(?:\.(\d+))?(.*)$`)
+
+// ParseKernelVersion parses a kernel version or tag name.
+func ParseKernelVersion(s string) (*KernelVersion, error) {
+	m := kernelVersionRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return nil, fmt.Errorf("invalid kernel version %q", s)
+	}
+	kv := &KernelVersion{Extra: m[4]}
+	kv.Major, _ = strconv.Atoi(m[1])
+	kv.Minor, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		kv.Patch, _ = strconv.Atoi(m[3])
+	}
+	return kv, nil
+}
+
+// String returns the version in 'make kernelversion' form.
+func (v *KernelVersion) String() string {
+	if v.Patch == 0 {
+		return fmt.Sprintf("%d.%d%s", v.Major, v.Minor, v.Extra)
+	}
+	return fmt.Sprintf("%d.%d.%d%s", v.Major, v.Minor, v.Patch, v.Extra)
+}
+
+// Compare orders versions by major, minor and patch level, ignoring Extra.
+// It returns -1, 0 or 1.
+func (v *KernelVersion) Compare(o *KernelVersion) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// KernelVersion detects the version of the checked-out kernel source from
+// its top-level Makefile, falling back to 'git describe'.
+func (ctx *Context) KernelVersion(gctx gocontext.Context) (*KernelVersion, error) {
+	kernelDir := ctx.Config.Paths.KernelDir
+	if data, err := ctx.FS.ReadFile(filepath.Join(kernelDir, "Makefile")); err == nil {
+		if kv := parseMakefileVersion(string(data)); kv != nil {
+			return kv, nil
+		}
+	}
+
+	out, err := ctx.Exec.Output(gctx, "git", "-C", kernelDir, "describe", "--tags")
+	if err != nil {
+		return nil, fmt.Errorf("cannot detect kernel version in %s: %w", kernelDir, err)
+	}
+	return ParseKernelVersion(string(out))
+}
+
+// parseMakefileVersion reads VERSION, PATCHLEVEL, SUBLEVEL and EXTRAVERSION
+// from the head of a kernel Makefile. It returns nil when they are missing.
+func parseMakefileVersion(makefile string) *KernelVersion {
+	vars := make(map[string]string)
+	for _, line := range strings.SplitN(makefile, "\n", 20) {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		vars[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	kv := &KernelVersion{Extra: vars["EXTRAVERSION"]}
+	var err error
+	if kv.Major, err = strconv.Atoi(vars["VERSION"]); err != nil {
+		return nil
+	}
+	if kv.Minor, err = strconv.Atoi(vars["PATCHLEVEL"]); err != nil {
+		return nil
+	}
+	kv.Patch, _ = strconv.Atoi(vars["SUBLEVEL"])
+	return kv
+}
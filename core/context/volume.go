@@ -0,0 +1,71 @@
+// Package context provides build context management for elmos.
+// This file contains the workspace volume abstraction.
+package context
+
+import (
+	gocontext "context"
+	"fmt"
+	"strings"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/config"
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/executor"
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/filesystem"
+)
+
+// Volume is the case-sensitive workspace volume that holds the kernel tree,
+// toolchains and rootfs. Implementations differ per host OS.
+type Volume interface {
+	// Backend returns the backend name (e.g., "hdiutil", "loop", "dir").
+	Backend() string
+
+	// Exists returns true if the backing storage has been created.
+	Exists() bool
+
+	// Create creates the backing storage (disk image or directory).
+	Create(ctx gocontext.Context) error
+
+	// Mount attaches the volume at its configured mount point.
+	Mount(ctx gocontext.Context) error
+
+	// Unmount detaches the volume. Force is used when the resource is busy.
+	Unmount(ctx gocontext.Context, force bool) error
+
+	// IsMounted checks if the volume is currently mounted.
+	IsMounted() bool
+
+	// MountPoint returns the actual mount point of the volume.
+	MountPoint() (string, error)
+
+	// Info returns human readable details about the mounted volume.
+	Info(ctx gocontext.Context) ([]string, error)
+}
+
+// NewVolume returns the Volume implementation for the configured backend.
+// An empty backend selects the default for the host OS.
+func NewVolume(cfg *config.Config, exec executor.Executor, fs filesystem.FileSystem) (Volume, error) {
+	backend := cfg.Image.Backend
+	if backend == "" {
+		backend = config.DefaultVolumeBackend()
+	}
+
+	switch backend {
+	case config.VolumeBackendHdiutil:
+		return &HdiutilVolume{cfg: cfg, exec: exec, fs: fs}, nil
+	case config.VolumeBackendLoop:
+		return &LoopVolume{cfg: cfg, exec: exec, fs: fs}, nil
+	case config.VolumeBackendDir:
+		return &DirVolume{cfg: cfg, exec: exec, fs: fs}, nil
+	default:
+		return nil, ConfigError(fmt.Sprintf("unknown volume backend: %s (valid: %s)",
+			backend, strings.Join(config.VolumeBackends, ", ")), nil)
+	}
+}
+
+// dfInfo returns the "df -h" lines for a mount point.
+func dfInfo(ctx gocontext.Context, exec executor.Executor, mountPoint string) ([]string, error) {
+	out, err := exec.Output(ctx, "df", "-h", mountPoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get volume info: %w", err)
+	}
+	return strings.Split(strings.TrimSpace(string(out)), "\n"), nil
+}
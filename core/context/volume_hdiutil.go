@@ -0,0 +1,207 @@
+// Package context provides build context management for elmos.
+// This file contains the macOS hdiutil sparse image volume backend.
+package context
+
+import (
+	gocontext "context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/config"
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/executor"
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/filesystem"
+)
+
+// HdiutilVolume is a case-sensitive APFS sparse image managed by hdiutil.
+type HdiutilVolume struct {
+	cfg  *config.Config
+	exec executor.Executor
+	fs   filesystem.FileSystem
+}
+
+// Backend returns the backend name.
+func (v *HdiutilVolume) Backend() string {
+	return config.VolumeBackendHdiutil
+}
+
+// Exists returns true if the sparse image exists.
+func (v *HdiutilVolume) Exists() bool {
+	return v.fs.Exists(v.cfg.Image.Path)
+}
+
+// Create creates the sparse disk image.
+func (v *HdiutilVolume) Create(ctx gocontext.Context) error {
+	if err := v.exec.Run(ctx, "hdiutil", "create",
+		"-size", v.cfg.Image.Size,
+		"-fs", "Case-sensitive APFS",
+		"-volname", v.cfg.Image.VolumeName,
+		"-type", "SPARSE",
+		v.cfg.Image.Path,
+	); err != nil {
+		return fmt.Errorf("failed to create disk image: %w", err)
+	}
+	return nil
+}
+
+// Mount attaches the sparse image at the configured mount point.
+func (v *HdiutilVolume) Mount(ctx gocontext.Context) error {
+	if err := v.exec.Run(ctx, "hdiutil", "attach",
+		"-mountpoint", v.cfg.Image.MountPoint,
+		v.cfg.Image.Path,
+	); err != nil {
+		return fmt.Errorf("failed to mount: %w", err)
+	}
+	return nil
+}
+
+// Unmount detaches the disk device backing our image.
+func (v *HdiutilVolume) Unmount(ctx gocontext.Context, force bool) error {
+	out, err := v.exec.Output(ctx, "hdiutil", "info")
+	if err != nil {
+		return fmt.Errorf("failed to find disk device: %w", err)
+	}
+	diskDevice, err := parseDiskDeviceFromHdiutil(string(out), v.cfg.Image.Path)
+	if err != nil {
+		return fmt.Errorf("failed to find disk device: %w", err)
+	}
+
+	// Use disk device which is reliable
+	args := []string{"detach", diskDevice}
+	if force {
+		args = append(args, "-force")
+	}
+	if err := v.exec.Run(ctx, "hdiutil", args...); err != nil {
+		return fmt.Errorf("failed to unmount: %w", err)
+	}
+	return nil
+}
+
+// IsMounted checks if the sparse image is currently mounted.
+func (v *HdiutilVolume) IsMounted() bool {
+	mountPoint := v.cfg.Image.MountPoint
+
+	// Check if the directory exists first
+	if !v.fs.IsDir(mountPoint) {
+		// It might be mounted at a different location
+		// Check hdiutil info for our image file path
+		out, err := v.exec.Output(gocontext.Background(), "hdiutil", "info")
+		if err != nil {
+			return false
+		}
+		// Check if our image file is mounted (regardless of mount point)
+		return strings.Contains(string(out), v.cfg.Image.Path)
+	}
+
+	// Verify it's actually a mount point using 'mount'
+	out, err := v.exec.Output(gocontext.Background(), "mount")
+	if err != nil {
+		return false
+	}
+
+	return strings.Contains(string(out), mountPoint)
+}
+
+// MountPoint returns the actual mount point of the image.
+// This handles cases where the volume is mounted at a different location (e.g. " 1" suffix).
+func (v *HdiutilVolume) MountPoint() (string, error) {
+	mountPoint := v.cfg.Image.MountPoint
+
+	// Fast path: if configured path exists
+	if v.fs.IsDir(mountPoint) {
+		return mountPoint, nil
+	}
+
+	// Slow path: check hdiutil info for our specific image file
+	out, err := v.exec.Output(gocontext.Background(), "hdiutil", "info")
+	if err != nil {
+		return "", err
+	}
+
+	return parseMountPointFromHdiutil(string(out), v.cfg.Image.Path)
+}
+
+// Info returns the hdiutil info block for our image.
+func (v *HdiutilVolume) Info(ctx gocontext.Context) ([]string, error) {
+	out, err := v.exec.Output(ctx, "hdiutil", "info")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hdiutil info: %w", err)
+	}
+
+	// Filter the output to our image
+	var info []string
+	inOurImage := false
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.Contains(line, v.cfg.Image.Path) {
+			inOurImage = true
+		}
+		if inOurImage {
+			info = append(info, line)
+			if strings.HasPrefix(line, "/dev/disk") && strings.Contains(line, "/Volumes/") {
+				break
+			}
+		}
+	}
+	return info, nil
+}
+
+// parseMountPointFromHdiutil extracts the mount point for an image from hdiutil info output.
+func parseMountPointFromHdiutil(output, imagePath string) (string, error) {
+	lines := strings.Split(output, "\n")
+
+	// Find the image block and look for mount point
+	foundImage := false
+	for i, line := range lines {
+		if strings.Contains(line, imagePath) {
+			foundImage = true
+			// Look for /Volumes/ in subsequent lines (up to 20 lines)
+			if mp := findMountPointInLines(lines, i+1, i+20); mp != "" {
+				return mp, nil
+			}
+			break
+		}
+	}
+
+	if !foundImage {
+		return "", fmt.Errorf("image not mounted: %s", imagePath)
+	}
+	return "", fmt.Errorf("volume not found")
+}
+
+// findMountPointInLines searches for a /Volumes/ path in a range of lines.
+// The mount point is the last tab-separated column and may contain spaces,
+// e.g. "/Volumes/elmos 1" when a volume of the same name is already mounted.
+func findMountPointInLines(lines []string, start, end int) string {
+	for j := start; j < len(lines) && j < end; j++ {
+		fields := strings.Split(lines[j], "\t")
+		mountStr := strings.TrimSpace(fields[len(fields)-1])
+		if strings.HasPrefix(mountStr, "/Volumes/") {
+			return mountStr
+		}
+	}
+	return ""
+}
+
+// parseDiskDeviceFromHdiutil extracts the disk device for an image from hdiutil info output.
+// Returns the disk device path like "/dev/disk4" that can be used with hdiutil detach.
+func parseDiskDeviceFromHdiutil(output, imagePath string) (string, error) {
+	// hdiutil output structure is:
+	// image-path: ...
+	// /dev/disk...
+	//
+	// We need to find the block for our image, so split by block for safety.
+	blocks := strings.Split(output, "===")
+	for _, block := range blocks {
+		if strings.Contains(block, imagePath) {
+			re := regexp.MustCompile(`/dev/disk\d+`)
+			if match := re.FindString(block); match != "" {
+				return match, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("disk device not found for image: %s", imagePath)
+}
+
+// Ensure HdiutilVolume implements Volume.
+var _ Volume = (*HdiutilVolume)(nil)
@@ -0,0 +1,155 @@
+// Package context provides build context management for elmos.
+// This file contains the Linux volume backends (loopback ext4 image and plain directory).
+package context
+
+import (
+	gocontext "context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/config"
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/executor"
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/filesystem"
+)
+
+// LoopVolume is a sparse ext4 image attached through a loop device.
+// It gives the workspace a fixed size cap and can be moved between hosts.
+type LoopVolume struct {
+	cfg  *config.Config
+	exec executor.Executor
+	fs   filesystem.FileSystem
+}
+
+// Backend returns the backend name.
+func (v *LoopVolume) Backend() string {
+	return config.VolumeBackendLoop
+}
+
+// Exists returns true if the ext4 image exists.
+func (v *LoopVolume) Exists() bool {
+	return v.fs.Exists(v.cfg.Image.Path)
+}
+
+// Create creates a sparse ext4 image.
+func (v *LoopVolume) Create(ctx gocontext.Context) error {
+	if err := v.fs.MkdirAll(filepath.Dir(v.cfg.Image.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create image directory: %w", err)
+	}
+	if err := v.exec.Run(ctx, "truncate", "-s", v.cfg.Image.Size, v.cfg.Image.Path); err != nil {
+		return fmt.Errorf("failed to create disk image: %w", err)
+	}
+	if err := v.exec.Run(ctx, "mkfs.ext4", "-q", "-F",
+		"-L", v.cfg.Image.VolumeName,
+		v.cfg.Image.Path,
+	); err != nil {
+		return fmt.Errorf("failed to format disk image: %w", err)
+	}
+	return nil
+}
+
+// Mount attaches the image through a loop device and hands ownership to the current user.
+func (v *LoopVolume) Mount(ctx gocontext.Context) error {
+	mountPoint := v.cfg.Image.MountPoint
+	if err := v.exec.Run(ctx, "sudo", "mkdir", "-p", mountPoint); err != nil {
+		return fmt.Errorf("failed to create mount point: %w", err)
+	}
+	if err := v.exec.Run(ctx, "sudo", "mount", "-o", "loop", v.cfg.Image.Path, mountPoint); err != nil {
+		return fmt.Errorf("failed to mount: %w", err)
+	}
+	owner := fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid())
+	if err := v.exec.Run(ctx, "sudo", "chown", owner, mountPoint); err != nil {
+		return fmt.Errorf("failed to take ownership of %s: %w", mountPoint, err)
+	}
+	return nil
+}
+
+// Unmount detaches the loop device. Force performs a lazy unmount.
+func (v *LoopVolume) Unmount(ctx gocontext.Context, force bool) error {
+	args := []string{"umount"}
+	if force {
+		args = append(args, "-l")
+	}
+	args = append(args, v.cfg.Image.MountPoint)
+	if err := v.exec.Run(ctx, "sudo", args...); err != nil {
+		return fmt.Errorf("failed to unmount: %w", err)
+	}
+	return nil
+}
+
+// IsMounted checks the mount table for the configured mount point.
+func (v *LoopVolume) IsMounted() bool {
+	out, err := v.exec.Output(gocontext.Background(), "mount")
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(out), " on "+v.cfg.Image.MountPoint+" ")
+}
+
+// MountPoint returns the configured mount point.
+func (v *LoopVolume) MountPoint() (string, error) {
+	return v.cfg.Image.MountPoint, nil
+}
+
+// Info returns disk usage of the mounted image.
+func (v *LoopVolume) Info(ctx gocontext.Context) ([]string, error) {
+	return dfInfo(ctx, v.exec, v.cfg.Image.MountPoint)
+}
+
+// DirVolume is a plain directory used as the workspace.
+// Linux filesystems are case-sensitive, so no disk image is required.
+type DirVolume struct {
+	cfg  *config.Config
+	exec executor.Executor
+	fs   filesystem.FileSystem
+}
+
+// Backend returns the backend name.
+func (v *DirVolume) Backend() string {
+	return config.VolumeBackendDir
+}
+
+// Exists returns true if the workspace directory exists.
+func (v *DirVolume) Exists() bool {
+	return v.fs.IsDir(v.cfg.Image.MountPoint)
+}
+
+// Create creates the workspace directory.
+func (v *DirVolume) Create(ctx gocontext.Context) error {
+	if err := v.fs.MkdirAll(v.cfg.Image.MountPoint, 0755); err != nil {
+		return fmt.Errorf("failed to create workspace directory: %w", err)
+	}
+	return nil
+}
+
+// Mount is a no-op beyond ensuring the directory exists.
+func (v *DirVolume) Mount(ctx gocontext.Context) error {
+	return v.Create(ctx)
+}
+
+// Unmount is a no-op; the directory is left in place.
+func (v *DirVolume) Unmount(ctx gocontext.Context, force bool) error {
+	return nil
+}
+
+// IsMounted returns true if the workspace directory exists.
+func (v *DirVolume) IsMounted() bool {
+	return v.Exists()
+}
+
+// MountPoint returns the workspace directory.
+func (v *DirVolume) MountPoint() (string, error) {
+	return v.cfg.Image.MountPoint, nil
+}
+
+// Info returns disk usage of the filesystem holding the workspace.
+func (v *DirVolume) Info(ctx gocontext.Context) ([]string, error) {
+	return dfInfo(ctx, v.exec, v.cfg.Image.MountPoint)
+}
+
+// Ensure LoopVolume and DirVolume implement Volume.
+var (
+	_ Volume = (*LoopVolume)(nil)
+	_ Volume = (*DirVolume)(nil)
+)
@@ -0,0 +1,213 @@
+package context
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/config"
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/executor"
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/filesystem"
+)
+
+// newTestConfig returns a config whose image lives under dir.
+func newTestConfig(dir, backend string) *config.Config {
+	cfg := &config.Config{}
+	cfg.Image.Backend = backend
+	cfg.Image.VolumeName = "kernel-dev"
+	cfg.Image.Size = "20G"
+	cfg.Image.Path = config.DefaultImagePath(dir, cfg.Image.VolumeName, backend)
+	cfg.Image.MountPoint = config.DefaultMountPoint(dir, cfg.Image.VolumeName, backend)
+	return cfg
+}
+
+// assertCalls compares the recorded command lines against want.
+func assertCalls(t *testing.T, got []executor.CommandCall, want [][]string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d calls %v, want %d", len(got), got, len(want))
+	}
+	for i, call := range got {
+		line := append([]string{call.Cmd}, call.Args...)
+		if !reflect.DeepEqual(line, want[i]) {
+			t.Errorf("call %d = %v, want %v", i, line, want[i])
+		}
+	}
+}
+
+func TestNewVolumeSelectsBackend(t *testing.T) {
+	tests := []struct {
+		backend string
+		want    string
+	}{
+		{config.VolumeBackendHdiutil, config.VolumeBackendHdiutil},
+		{config.VolumeBackendLoop, config.VolumeBackendLoop},
+		{config.VolumeBackendDir, config.VolumeBackendDir},
+		{"", config.DefaultVolumeBackend()},
+	}
+	for _, tt := range tests {
+		cfg := newTestConfig(t.TempDir(), tt.backend)
+		vol, err := NewVolume(cfg, executor.NewMockExecutor(), filesystem.NewOSFileSystem())
+		if err != nil {
+			t.Fatalf("NewVolume(%q): %v", tt.backend, err)
+		}
+		if vol.Backend() != tt.want {
+			t.Errorf("NewVolume(%q).Backend() = %q, want %q", tt.backend, vol.Backend(), tt.want)
+		}
+	}
+}
+
+func TestNewVolumeUnknownBackend(t *testing.T) {
+	cfg := newTestConfig(t.TempDir(), "zfs")
+	if _, err := NewVolume(cfg, executor.NewMockExecutor(), filesystem.NewOSFileSystem()); err == nil {
+		t.Fatal("expected error for unknown backend")
+	}
+}
+
+func TestLoopVolumeLifecycle(t *testing.T) {
+	dir := t.TempDir()
+	cfg := newTestConfig(dir, config.VolumeBackendLoop)
+	mock := executor.NewMockExecutor()
+	vol := &LoopVolume{cfg: cfg, exec: mock, fs: filesystem.NewOSFileSystem()}
+	ctx := gocontext.Background()
+
+	if err := vol.Create(ctx); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := vol.Mount(ctx); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+	if err := vol.Unmount(ctx, true); err != nil {
+		t.Fatalf("Unmount: %v", err)
+	}
+
+	owner := fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid())
+	assertCalls(t, mock.Calls, [][]string{
+		{"truncate", "-s", "20G", cfg.Image.Path},
+		{"mkfs.ext4", "-q", "-F", "-L", "kernel-dev", cfg.Image.Path},
+		{"sudo", "mkdir", "-p", cfg.Image.MountPoint},
+		{"sudo", "mount", "-o", "loop", cfg.Image.Path, cfg.Image.MountPoint},
+		{"sudo", "chown", owner, cfg.Image.MountPoint},
+		{"sudo", "umount", "-l", cfg.Image.MountPoint},
+	})
+	if !vol.fs.IsDir(filepath.Dir(cfg.Image.Path)) {
+		t.Error("Create did not create the image directory")
+	}
+}
+
+func TestLoopVolumeCreateError(t *testing.T) {
+	cfg := newTestConfig(t.TempDir(), config.VolumeBackendLoop)
+	mock := executor.NewMockExecutor()
+	mock.RunError = errors.New("boom")
+	vol := &LoopVolume{cfg: cfg, exec: mock, fs: filesystem.NewOSFileSystem()}
+
+	if err := vol.Create(gocontext.Background()); err == nil {
+		t.Fatal("expected error when truncate fails")
+	}
+	if len(mock.Calls) != 1 {
+		t.Errorf("expected to stop after the first command, got %d calls", len(mock.Calls))
+	}
+}
+
+func TestLoopVolumeIsMounted(t *testing.T) {
+	cfg := newTestConfig(t.TempDir(), config.VolumeBackendLoop)
+	mock := executor.NewMockExecutor()
+	vol := &LoopVolume{cfg: cfg, exec: mock, fs: filesystem.NewOSFileSystem()}
+
+	mock.OutputResponses["mount"] = []byte("/dev/sda1 on / type ext4 (rw)\n")
+	if vol.IsMounted() {
+		t.Error("IsMounted() = true with no matching mount entry")
+	}
+
+	mock.OutputResponses["mount"] = []byte(fmt.Sprintf("/dev/loop0 on %s type ext4 (rw)\n", cfg.Image.MountPoint))
+	if !vol.IsMounted() {
+		t.Error("IsMounted() = false with a matching mount entry")
+	}
+}
+
+func TestDirVolumeLifecycle(t *testing.T) {
+	cfg := newTestConfig(t.TempDir(), config.VolumeBackendDir)
+	mock := executor.NewMockExecutor()
+	vol := &DirVolume{cfg: cfg, exec: mock, fs: filesystem.NewOSFileSystem()}
+	ctx := gocontext.Background()
+
+	if vol.Exists() || vol.IsMounted() {
+		t.Fatal("volume reported present before Create")
+	}
+	if err := vol.Mount(ctx); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+	if !vol.Exists() || !vol.IsMounted() {
+		t.Error("volume not present after Mount")
+	}
+	if err := vol.Unmount(ctx, false); err != nil {
+		t.Fatalf("Unmount: %v", err)
+	}
+	if !vol.Exists() {
+		t.Error("Unmount removed the workspace directory")
+	}
+	if len(mock.Calls) != 0 {
+		t.Errorf("dir backend should not run commands, got %v", mock.Calls)
+	}
+}
+
+const hdiutilInfo = `framework       : 671.0.0
+driver          : 671.0.0
+================================================
+image-path      : /Users/dev/elmos/data/kernel-dev.sparseimage
+shadow-path     : <none>
+icon-path       : /System/Library/PrivateFrameworks/DiskImages.framework/Resources/CDiskImage.icns
+image-type      : sparse disk image
+/dev/disk4          	GUID_partition_scheme
+/dev/disk4s1        	Apple_APFS
+/dev/disk5          	EF57347C-0000-11AA-AA11-0030654
+/dev/disk5s1        	41504653-0000-11AA-AA11-0030654	/Volumes/kernel-dev 1
+`
+
+func TestHdiutilVolumeLifecycle(t *testing.T) {
+	cfg := newTestConfig("/Users/dev/elmos", config.VolumeBackendHdiutil)
+	mock := executor.NewMockExecutor()
+	mock.OutputResponses["hdiutil"] = []byte(hdiutilInfo)
+	vol := &HdiutilVolume{cfg: cfg, exec: mock, fs: filesystem.NewOSFileSystem()}
+	ctx := gocontext.Background()
+
+	if err := vol.Create(ctx); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := vol.Mount(ctx); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+	if err := vol.Unmount(ctx, true); err != nil {
+		t.Fatalf("Unmount: %v", err)
+	}
+
+	assertCalls(t, mock.Calls, [][]string{
+		{"hdiutil", "create", "-size", "20G", "-fs", "Case-sensitive APFS",
+			"-volname", "kernel-dev", "-type", "SPARSE", cfg.Image.Path},
+		{"hdiutil", "attach", "-mountpoint", "/Volumes/kernel-dev", cfg.Image.Path},
+		{"hdiutil", "info"},
+		{"hdiutil", "detach", "/dev/disk4", "-force"},
+	})
+}
+
+func TestHdiutilVolumeMountPointFallback(t *testing.T) {
+	cfg := newTestConfig("/Users/dev/elmos", config.VolumeBackendHdiutil)
+	mock := executor.NewMockExecutor()
+	mock.OutputResponses["hdiutil"] = []byte(hdiutilInfo)
+	vol := &HdiutilVolume{cfg: cfg, exec: mock, fs: filesystem.NewOSFileSystem()}
+
+	mp, err := vol.MountPoint()
+	if err != nil {
+		t.Fatalf("MountPoint: %v", err)
+	}
+	if mp != "/Volumes/kernel-dev 1" {
+		t.Errorf("MountPoint() = %q, want /Volumes/kernel-dev 1", mp)
+	}
+	if !vol.IsMounted() {
+		t.Error("IsMounted() = false for an image listed by hdiutil info")
+	}
+}
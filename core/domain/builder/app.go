@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"path/filepath"
 	"strings"
 	"text/template"
@@ -19,9 +20,9 @@ import (
 
 // AppInfo contains information about a userspace application.
 type AppInfo struct {
-	Name  string
-	Path  string
-	Built bool
+	Name  string `json:"name" yaml:"name"`
+	Path  string `json:"path" yaml:"path"`
+	Built bool   `json:"built" yaml:"built"`
 }
 
 // AppBuilder orchestrates userspace application build operations.
@@ -44,43 +45,87 @@ func NewAppBuilder(exec executor.Executor, fs filesystem.FileSystem, cfg *elconf
 	}
 }
 
-// Build builds one or all userspace applications.
+// Build builds one or all userspace applications, one at a time, stopping
+// at the first failure. Up-to-date apps are skipped.
 func (a *AppBuilder) Build(ctx context.Context, name string) error {
-	apps, err := a.GetApps(name)
+	report, err := a.BuildWithOptions(ctx, name, TargetBuildOptions{Jobs: 1})
 	if err != nil {
 		return err
 	}
+	return report.Err()
+}
+
+// BuildWithOptions builds one or all userspace applications, several at
+// once. Apps whose binary is newer than their sources and was built with the
+// same architecture and compiler are skipped unless opts.Force is set; apps
+// with a Makefile are always run through make.
+func (a *AppBuilder) BuildWithOptions(ctx context.Context, name string, opts TargetBuildOptions) (*BuildReport, error) {
+	apps, err := a.GetApps(name)
+	if err != nil {
+		return nil, err
+	}
 
 	if len(apps) == 0 {
-		return nil
+		return &BuildReport{}, nil
 	}
 
 	// Get environment with correct toolchain
 	env, crossCompile, err := getToolchainEnv(a.ctx, a.cfg, a.tm, a.fs, a.cfg.Build.Arch)
 	if err != nil {
-		return fmt.Errorf("failed to configure toolchain environment: %w", err)
+		return nil, fmt.Errorf("failed to configure toolchain environment: %w", err)
 	}
 
 	compiler := a.getCrossCompiler(crossCompile)
+	stamp := buildStamp(
+		"arch", a.cfg.KernelArch(),
+		"cross_compile", crossCompile,
+		"compiler", compiler,
+	)
 
+	targets := make([]buildTarget, 0, len(apps))
 	for _, app := range apps {
-		if err := a.buildApp(ctx, app, compiler, env); err != nil {
-			return err
-		}
+		app := app
+		out := filepath.Join(app.Path, app.Name)
+		targets = append(targets, buildTarget{
+			name: app.Name,
+			stale: func() bool {
+				// make decides for itself what to rebuild
+				if a.fs.Exists(filepath.Join(app.Path, "Makefile")) {
+					return true
+				}
+				return olderThan(a.fs, out, SourcesModTime(a.fs, app.Path)) || stampChanged(a.fs, out, stamp)
+			},
+			build: func(ctx context.Context, w io.Writer) error {
+				if err := a.buildApp(ctx, app, compiler, env, stampChanged(a.fs, out, stamp), w); err != nil {
+					return err
+				}
+				writeStamp(a.fs, out, stamp)
+				return nil
+			},
+		})
 	}
 
-	return nil
+	if opts.Jobs == 0 {
+		opts.Jobs = a.cfg.Build.Jobs
+	}
+	s := &scheduler{fs: a.fs, logDir: a.ctx.GetLogDir(), kind: "app", opts: opts}
+	return s.run(ctx, targets)
 }
 
-// buildApp builds a single application.
-func (a *AppBuilder) buildApp(ctx context.Context, app AppInfo, compiler string, env []string) error {
+// buildApp builds a single application, sending output to w, or to the terminal when w is nil.
+// rebuild makes make rebuild every target, for apps last built with another toolchain.
+func (a *AppBuilder) buildApp(ctx context.Context, app AppInfo, compiler string, env []string, rebuild bool, w io.Writer) error {
 	// Check for Makefile
 	makefilePath := filepath.Join(app.Path, "Makefile")
 	if a.fs.Exists(makefilePath) {
-		return a.exec.RunWithEnvInDir(ctx, env, app.Path, "make",
-			fmt.Sprintf("CC=%s", compiler),
-			fmt.Sprintf("ARCH=%s", a.cfg.Build.Arch),
-		)
+		args := []string{
+			fmt.Sprintf("CC=%s", a.ctx.WrapCompiler(compiler)),
+			fmt.Sprintf("ARCH=%s", a.cfg.KernelArch()),
+		}
+		if rebuild {
+			args = append(args, "-B")
+		}
+		return runTo(ctx, a.exec, env, app.Path, w, "make", args...)
 	}
 
 	// Simple compilation
@@ -90,7 +135,10 @@ func (a *AppBuilder) buildApp(ctx context.Context, app AppInfo, compiler string,
 	}
 
 	outFile := filepath.Join(app.Path, app.Name)
-	return a.exec.RunWithEnv(ctx, env, compiler, "-static", "-o", outFile, srcFile)
+	if tool := a.ctx.CompilerCache(); tool != "" {
+		return runTo(ctx, a.exec, env, "", w, tool, compiler, "-static", "-o", outFile, srcFile)
+	}
+	return runTo(ctx, a.exec, env, "", w, compiler, "-static", "-o", outFile, srcFile)
 }
 
 // Clean cleans one or all applications.
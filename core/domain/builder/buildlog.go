@@ -0,0 +1,224 @@
+// Package builder provides kernel and module build orchestration for elmos.
+// This file contains build log capture and diagnostic extraction.
+package builder
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severity levels for build diagnostics.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// BuildIssue is a single diagnostic extracted from build output.
+type BuildIssue struct {
+	File     string
+	Line     int
+	Column   int
+	Severity string
+	Message  string
+}
+
+// String formats the issue like a compiler diagnostic.
+func (i BuildIssue) String() string {
+	var loc string
+	switch {
+	case i.File != "" && i.Line > 0 && i.Column > 0:
+		loc = fmt.Sprintf("%s:%d:%d: ", i.File, i.Line, i.Column)
+	case i.File != "" && i.Line > 0:
+		loc = fmt.Sprintf("%s:%d: ", i.File, i.Line)
+	case i.File != "":
+		loc = i.File + ": "
+	}
+	return fmt.Sprintf("%s%s: %s", loc, i.Severity, i.Message)
+}
+
+// BuildLogSummary holds the diagnostics parsed from a build log.
+type BuildLogSummary struct {
+	LogPath  string
+	Errors   []BuildIssue
+	Warnings []BuildIssue
+}
+
+// BuildFailure is returned when a build fails and carries the parsed log summary.
+type BuildFailure struct {
+	Summary *BuildLogSummary
+	Err     error
+}
+
+// Error implements the error interface.
+func (f *BuildFailure) Error() string {
+	return fmt.Sprintf("build failed with %d error(s), log: %s: %v", len(f.Summary.Errors), f.Summary.LogPath, f.Err)
+}
+
+// Unwrap returns the underlying error.
+func (f *BuildFailure) Unwrap() error {
+	return f.Err
+}
+
+var (
+	// ansiPattern matches terminal color escapes emitted by clang/gcc.
+	ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+	// compilerPattern matches "file:line:col: error: msg" and Kconfig's "file:line:warning: msg".
+	compilerPattern = regexp.MustCompile(`^([^\s:]+):(\d+):(?:(\d+):)?\s*(fatal error|error|warning):\s*(.+)$`)
+
+	// kconfigPattern matches Kconfig parser and .config errors without a
+	// severity, e.g. "arch/arm64/Kconfig:12: syntax error".
+	kconfigPattern = regexp.MustCompile(`^(\S*(?:Kconfig[\w.-]*|\.config|defconfig)):(\d+):\s*(.+)$`)
+
+	// linkerPattern matches "ld.lld: error: msg" and GNU ld diagnostics.
+	linkerPattern = regexp.MustCompile(`^(\S*ld(?:\.lld|\.bfd|\.gold)?):\s*(error|warning):\s*(.+)$`)
+
+	// undefinedRefPattern matches GNU ld "file.o: undefined reference to `sym'".
+	undefinedRefPattern = regexp.MustCompile(`^(\S+?):(?:\(\S+\):)?\s*(undefined reference to .+)$`)
+
+	// modpostPattern matches "ERROR: modpost: msg".
+	modpostPattern = regexp.MustCompile(`^(ERROR|WARNING): modpost: (.+)$`)
+
+	// makePattern matches "make[2]: *** [Makefile:1234: vmlinux] Error 1".
+	makePattern = regexp.MustCompile(`^make(?:\[\d+\])?: \*\*\* (.+)$`)
+)
+
+// ParseBuildLog extracts errors and warnings from build output.
+// make's own "***" lines are only reported when nothing more specific was found.
+func ParseBuildLog(r io.Reader) []BuildIssue {
+	var issues, makeIssues []BuildIssue
+	seen := make(map[string]bool)
+
+	add := func(list *[]BuildIssue, issue BuildIssue) {
+		key := issue.String()
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		*list = append(*list, issue)
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(ansiPattern.ReplaceAllString(scanner.Text(), ""))
+		if line == "" {
+			continue
+		}
+
+		if m := compilerPattern.FindStringSubmatch(line); m != nil {
+			lineNo, _ := strconv.Atoi(m[2])
+			col, _ := strconv.Atoi(m[3])
+			severity := SeverityError
+			if m[4] == "warning" {
+				severity = SeverityWarning
+			}
+			add(&issues, BuildIssue{File: m[1], Line: lineNo, Column: col, Severity: severity, Message: m[5]})
+			continue
+		}
+		if m := kconfigPattern.FindStringSubmatch(line); m != nil {
+			lineNo, _ := strconv.Atoi(m[2])
+			add(&issues, BuildIssue{File: m[1], Line: lineNo, Severity: SeverityError, Message: m[3]})
+			continue
+		}
+		if m := linkerPattern.FindStringSubmatch(line); m != nil {
+			add(&issues, BuildIssue{File: m[1], Severity: m[2], Message: m[3]})
+			continue
+		}
+		if m := modpostPattern.FindStringSubmatch(line); m != nil {
+			add(&issues, BuildIssue{File: "modpost", Severity: strings.ToLower(m[1]), Message: m[2]})
+			continue
+		}
+		if m := undefinedRefPattern.FindStringSubmatch(line); m != nil {
+			add(&issues, BuildIssue{File: m[1], Severity: SeverityError, Message: m[2]})
+			continue
+		}
+		if m := makePattern.FindStringSubmatch(line); m != nil {
+			add(&makeIssues, BuildIssue{File: "make", Severity: SeverityError, Message: m[1]})
+		}
+	}
+
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return issues
+		}
+	}
+	return append(issues, makeIssues...)
+}
+
+// SummarizeBuildLog splits parsed issues into errors and warnings.
+func SummarizeBuildLog(logPath string, issues []BuildIssue) *BuildLogSummary {
+	summary := &BuildLogSummary{LogPath: logPath}
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			summary.Errors = append(summary.Errors, issue)
+		} else {
+			summary.Warnings = append(summary.Warnings, issue)
+		}
+	}
+	return summary
+}
+
+// LogDir returns the directory holding build logs on the workspace volume.
+func (b *KernelBuilder) LogDir() string {
+	return b.ctx.GetLogDir()
+}
+
+// newLogPath returns a timestamped log path for a kernel build.
+func (b *KernelBuilder) newLogPath() string {
+	name := fmt.Sprintf("kernel-build-%s-%s.log", b.cfg.Build.Arch, time.Now().Format("20060102-150405"))
+	return filepath.Join(b.LogDir(), name)
+}
+
+// LastBuildLog returns the path of the most recent kernel build log.
+func (b *KernelBuilder) LastBuildLog() (string, error) {
+	entries, err := b.fs.ReadDir(b.LogDir())
+	if err != nil {
+		return "", fmt.Errorf("no build logs found in %s", b.LogDir())
+	}
+
+	// Names embed the arch, so order by the timestamp suffix
+	var logs []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), "kernel-build-") && strings.HasSuffix(e.Name(), ".log") {
+			logs = append(logs, e.Name())
+		}
+	}
+	if len(logs) == 0 {
+		return "", fmt.Errorf("no build logs found in %s", b.LogDir())
+	}
+	sort.Slice(logs, func(i, j int) bool {
+		return logTimestamp(logs[i]) < logTimestamp(logs[j])
+	})
+	return filepath.Join(b.LogDir(), logs[len(logs)-1]), nil
+}
+
+// LastBuildSummary parses the most recent kernel build log.
+func (b *KernelBuilder) LastBuildSummary() (*BuildLogSummary, error) {
+	logPath, err := b.LastBuildLog()
+	if err != nil {
+		return nil, err
+	}
+	f, err := b.fs.Open(logPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open build log: %w", err)
+	}
+	defer f.Close()
+	return SummarizeBuildLog(logPath, ParseBuildLog(f)), nil
+}
+
+// logTimestamp extracts the "YYYYMMDD-HHMMSS" suffix from a log file name.
+func logTimestamp(name string) string {
+	base := strings.TrimSuffix(name, ".log")
+	if len(base) < len("20060102-150405") {
+		return base
+	}
+	return base[len(base)-len("20060102-150405"):]
+}
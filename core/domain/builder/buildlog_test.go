@@ -0,0 +1,148 @@
+package builder
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBuildLog(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want []BuildIssue
+	}{
+		{
+			name: "clang error and warning",
+			log: `  CC      drivers/misc/foo.o
+drivers/misc/foo.c:42:13: error: use of undeclared identifier 'bar'
+drivers/misc/foo.c:10:5: warning: no previous prototype for function 'foo_init' [-Wmissing-prototypes]
+make[4]: *** [scripts/Makefile.build:243: drivers/misc/foo.o] Error 1`,
+			want: []BuildIssue{
+				{File: "drivers/misc/foo.c", Line: 42, Column: 13, Severity: SeverityError, Message: "use of undeclared identifier 'bar'"},
+				{File: "drivers/misc/foo.c", Line: 10, Column: 5, Severity: SeverityWarning, Message: "no previous prototype for function 'foo_init' [-Wmissing-prototypes]"},
+			},
+		},
+		{
+			name: "clang fatal error with color escapes",
+			log:  "\x1b[1minclude/linux/foo.h:3:10: \x1b[0;1;31mfatal error: \x1b[0m'asm/bar.h' file not found\x1b[0m",
+			want: []BuildIssue{
+				{File: "include/linux/foo.h", Line: 3, Column: 10, Severity: SeverityError, Message: "'asm/bar.h' file not found"},
+			},
+		},
+		{
+			name: "ld.lld error",
+			log: `  LD      .tmp_vmlinux.kallsyms1
+ld.lld: error: undefined symbol: foo_register
+make[2]: *** [scripts/Makefile.vmlinux:34: vmlinux] Error 1`,
+			want: []BuildIssue{
+				{File: "ld.lld", Severity: SeverityError, Message: "undefined symbol: foo_register"},
+			},
+		},
+		{
+			name: "GNU ld undefined reference",
+			log:  "drivers/misc/foo.o: undefined reference to `bar_probe'",
+			want: []BuildIssue{
+				{File: "drivers/misc/foo.o", Severity: SeverityError, Message: "undefined reference to `bar_probe'"},
+			},
+		},
+		{
+			name: "modpost",
+			log: `  MODPOST Module.symvers
+ERROR: modpost: "foo_register" [drivers/misc/bar.ko] undefined!
+WARNING: modpost: missing MODULE_DESCRIPTION() in drivers/misc/bar.o`,
+			want: []BuildIssue{
+				{File: "modpost", Severity: SeverityError, Message: `"foo_register" [drivers/misc/bar.ko] undefined!`},
+				{File: "modpost", Severity: SeverityWarning, Message: "missing MODULE_DESCRIPTION() in drivers/misc/bar.o"},
+			},
+		},
+		{
+			name: "Kconfig parser errors",
+			log: `arch/arm64/Kconfig:12: syntax error
+arch/arm64/Kconfig:14: unknown statement "foo"
+drivers/misc/Kconfig.debug:3:warning: ignoring unsupported character '$'
+make[2]: *** [scripts/kconfig/Makefile:94: defconfig] Error 1`,
+			want: []BuildIssue{
+				{File: "arch/arm64/Kconfig", Line: 12, Severity: SeverityError, Message: "syntax error"},
+				{File: "arch/arm64/Kconfig", Line: 14, Severity: SeverityError, Message: `unknown statement "foo"`},
+				{File: "drivers/misc/Kconfig.debug", Line: 3, Severity: SeverityWarning, Message: "ignoring unsupported character '$'"},
+			},
+		},
+		{
+			name: ".config warning",
+			log:  ".config:45:warning: symbol value 'abc' invalid for NR_CPUS",
+			want: []BuildIssue{
+				{File: ".config", Line: 45, Severity: SeverityWarning, Message: "symbol value 'abc' invalid for NR_CPUS"},
+			},
+		},
+		{
+			name: "make error only when nothing more specific",
+			log: `  CC      init/main.o
+make[1]: *** [Makefile:1234: init] Error 2
+make: *** [Makefile:224: __sub-make] Error 2`,
+			want: []BuildIssue{
+				{File: "make", Severity: SeverityError, Message: "[Makefile:1234: init] Error 2"},
+				{File: "make", Severity: SeverityError, Message: "[Makefile:224: __sub-make] Error 2"},
+			},
+		},
+		{
+			name: "duplicates are dropped",
+			log: `drivers/misc/foo.c:1:1: warning: unused variable 'x'
+drivers/misc/foo.c:1:1: warning: unused variable 'x'`,
+			want: []BuildIssue{
+				{File: "drivers/misc/foo.c", Line: 1, Column: 1, Severity: SeverityWarning, Message: "unused variable 'x'"},
+			},
+		},
+		{
+			name: "clean build",
+			log: `  CC      init/main.o
+  LD      vmlinux
+  OBJCOPY arch/arm64/boot/Image`,
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseBuildLog(strings.NewReader(tt.log))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseBuildLog() =\n%#v\nwant\n%#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeBuildLog(t *testing.T) {
+	issues := []BuildIssue{
+		{File: "a.c", Line: 1, Severity: SeverityWarning, Message: "w1"},
+		{File: "b.c", Line: 2, Severity: SeverityError, Message: "e1"},
+		{File: "modpost", Severity: SeverityWarning, Message: "w2"},
+	}
+	summary := SummarizeBuildLog("/tmp/build.log", issues)
+	if summary.LogPath != "/tmp/build.log" {
+		t.Errorf("LogPath = %q", summary.LogPath)
+	}
+	if len(summary.Errors) != 1 || summary.Errors[0].Message != "e1" {
+		t.Errorf("Errors = %v, want [e1]", summary.Errors)
+	}
+	if len(summary.Warnings) != 2 || summary.Warnings[0].Message != "w1" || summary.Warnings[1].Message != "w2" {
+		t.Errorf("Warnings = %v, want [w1 w2]", summary.Warnings)
+	}
+}
+
+func TestBuildIssueString(t *testing.T) {
+	tests := []struct {
+		issue BuildIssue
+		want  string
+	}{
+		{BuildIssue{File: "a.c", Line: 3, Column: 7, Severity: SeverityError, Message: "m"}, "a.c:3:7: error: m"},
+		{BuildIssue{File: "Kconfig", Line: 3, Severity: SeverityError, Message: "syntax error"}, "Kconfig:3: error: syntax error"},
+		{BuildIssue{File: "ld.lld", Severity: SeverityError, Message: "m"}, "ld.lld: error: m"},
+		{BuildIssue{Severity: SeverityWarning, Message: "m"}, "warning: m"},
+	}
+	for _, tt := range tests {
+		if got := tt.issue.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -74,3 +74,13 @@ func getToolchainEnv(ctx *elcontext.Context, cfg *elconfig.Config, tm *toolchain
 
 	return newEnv, crossCompile, nil
 }
+
+// compilerCacheArgs returns make variables that route kbuild's clang through
+// the compiler cache. Make variables are used because LLVM=1 overrides CC from
+// the environment.
+func compilerCacheArgs(ctx *elcontext.Context) []string {
+	if ctx.CompilerCache() == "" {
+		return nil
+	}
+	return []string{"CC=" + ctx.WrapCompiler("clang"), "HOSTCC=" + ctx.WrapCompiler("clang")}
+}
@@ -0,0 +1,222 @@
+// Package builder provides kernel and module build orchestration for elmos.
+// This file contains kernel config fragment discovery and merging.
+package builder
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/NguyenTrongPhuc552003/elmos/assets"
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/kconfig"
+)
+
+// Fragment sources, in increasing order of precedence.
+const (
+	FragmentSourceBuiltin   = "builtin"
+	FragmentSourceRepo      = "repo"
+	FragmentSourceWorkspace = "workspace"
+)
+
+// fragmentExt is the file extension of config fragments.
+const fragmentExt = ".config"
+
+// Fragment is a named kernel config fragment.
+type Fragment struct {
+	Name        string
+	Source      string // builtin, repo or workspace
+	Path        string // Empty for builtin fragments
+	Description string // First comment line of the fragment
+	data        []byte
+}
+
+// SymbolChange records a symbol whose value differs between two points of a merge.
+type SymbolChange struct {
+	Symbol   string
+	Old      string // Previous (or requested) value, "n" when unset
+	New      string // New (or actual) value, "n" when unset
+	Fragment string // Fragment that requested the value
+}
+
+// FragmentMergeResult describes the outcome of merging fragments into .config.
+type FragmentMergeResult struct {
+	Fragments []string
+	Requested int            // Number of distinct symbols requested
+	Redefined []SymbolChange // Symbols set differently by an earlier fragment
+	Dropped   []SymbolChange // Requested values that did not survive olddefconfig
+}
+
+// FragmentDirs returns the repo and workspace fragment directories.
+func (b *KernelBuilder) FragmentDirs() map[string]string {
+	return map[string]string{
+		FragmentSourceRepo:      b.cfg.Paths.FragmentsDir,
+		FragmentSourceWorkspace: filepath.Join(b.cfg.Image.MountPoint, "fragments"),
+	}
+}
+
+// ListFragments returns all available fragments sorted by name.
+// A repo fragment overrides a builtin one of the same name, and a workspace
+// fragment overrides both.
+func (b *KernelBuilder) ListFragments() ([]Fragment, error) {
+	byName := make(map[string]Fragment)
+
+	builtin, err := assets.GetFragments()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read builtin fragments: %w", err)
+	}
+	for file, data := range builtin {
+		name := strings.TrimSuffix(file, fragmentExt)
+		byName[name] = Fragment{Name: name, Source: FragmentSourceBuiltin, Description: fragmentDescription(data), data: data}
+	}
+
+	dirs := b.FragmentDirs()
+	for _, source := range []string{FragmentSourceRepo, FragmentSourceWorkspace} {
+		dir := dirs[source]
+		entries, err := b.fs.ReadDir(dir)
+		if err != nil {
+			continue // Directory is optional
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), fragmentExt) {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			data, err := b.fs.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read fragment %s: %w", path, err)
+			}
+			name := strings.TrimSuffix(e.Name(), fragmentExt)
+			byName[name] = Fragment{Name: name, Source: source, Path: path, Description: fragmentDescription(data), data: data}
+		}
+	}
+
+	fragments := make([]Fragment, 0, len(byName))
+	for _, f := range byName {
+		fragments = append(fragments, f)
+	}
+	sort.Slice(fragments, func(i, j int) bool { return fragments[i].Name < fragments[j].Name })
+	return fragments, nil
+}
+
+// GetFragment resolves a fragment by name or by path to a .config file.
+func (b *KernelBuilder) GetFragment(name string) (*Fragment, error) {
+	if strings.ContainsRune(name, filepath.Separator) {
+		data, err := b.fs.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fragment %s: %w", name, err)
+		}
+		base := strings.TrimSuffix(filepath.Base(name), fragmentExt)
+		return &Fragment{Name: base, Source: "file", Path: name, Description: fragmentDescription(data), data: data}, nil
+	}
+
+	fragments, err := b.ListFragments()
+	if err != nil {
+		return nil, err
+	}
+	name = strings.TrimSuffix(name, fragmentExt)
+	for i := range fragments {
+		if fragments[i].Name == name {
+			return &fragments[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown config fragment: %s (see 'elmos kernel config fragment list')", name)
+}
+
+// Content returns the raw fragment text.
+func (f *Fragment) Content() []byte {
+	return f.data
+}
+
+// MergeFragments merges fragments into the kernel .config and runs olddefconfig.
+// Later fragments override earlier ones, like scripts/kconfig/merge_config.sh.
+// Requested values that Kconfig changed (usually because of unmet dependencies)
+// are reported in the result's Dropped list.
+func (b *KernelBuilder) MergeFragments(ctx context.Context, names []string) (*FragmentMergeResult, error) {
+	result := &FragmentMergeResult{}
+	requested := kconfig.New()
+	requestedBy := make(map[string]string)
+
+	for _, name := range names {
+		frag, err := b.GetFragment(name)
+		if err != nil {
+			return nil, err
+		}
+		result.Fragments = append(result.Fragments, frag.Name)
+		for _, sym := range kconfig.ParseBytes(frag.data).Symbols() {
+			if prev, ok := requested.Lookup(sym.Name); ok && prev != sym.Value {
+				result.Redefined = append(result.Redefined, SymbolChange{
+					Symbol: sym.Name, Old: prev, New: sym.Value, Fragment: frag.Name,
+				})
+			}
+			requested.Set(sym.Name, sym.Value)
+			requestedBy[sym.Name] = frag.Name
+		}
+	}
+	result.Requested = requested.Len()
+	if requested.Len() == 0 {
+		return result, nil
+	}
+
+	dropped, err := b.ApplyConfigSymbols(ctx, requested.Symbols())
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range dropped {
+		c.Fragment = requestedBy[c.Symbol]
+		result.Dropped = append(result.Dropped, c)
+	}
+	return result, nil
+}
+
+// ApplyConfigSymbols writes symbol values into the kernel .config and runs olddefconfig.
+// It returns the requested values that did not survive, with Old holding the
+// requested value and New the value Kconfig settled on.
+func (b *KernelBuilder) ApplyConfigSymbols(ctx context.Context, symbols []kconfig.Symbol) ([]SymbolChange, error) {
+	cfg, err := b.LoadConfig("")
+	if err != nil {
+		return nil, err
+	}
+	for _, sym := range symbols {
+		cfg.Set(sym.Name, sym.Value)
+	}
+	configFile := b.ConfigPath()
+	if err := b.fs.WriteFile(configFile, cfg.Bytes(), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write .config: %w", err)
+	}
+
+	if err := b.Configure(ctx, "olddefconfig"); err != nil {
+		return nil, err
+	}
+
+	final, err := b.LoadConfig("")
+	if err != nil {
+		return nil, err
+	}
+	var dropped []SymbolChange
+	for _, sym := range symbols {
+		if got := final.Value(sym.Name); got != sym.Value {
+			dropped = append(dropped, SymbolChange{Symbol: sym.Name, Old: sym.Value, New: got})
+		}
+	}
+	return dropped, nil
+}
+
+// fragmentDescription returns the first comment line of a fragment.
+func fragmentDescription(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") && kconfig.ParseBytes([]byte(line)).Len() == 0 {
+			return strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		}
+		return ""
+	}
+	return ""
+}
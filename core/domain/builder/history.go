@@ -0,0 +1,246 @@
+// Package builder provides kernel and module build orchestration for elmos.
+// This file contains the kernel build timing history and regression detection.
+package builder
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/domain/cache"
+)
+
+// historyFile is the build history file name inside the log directory.
+const historyFile = "kernel-build-history.jsonl"
+
+// Regression detection settings.
+const (
+	// historyBaselineSize is how many earlier comparable builds form the baseline.
+	historyBaselineSize = 5
+	// slowdownFactor marks a build as a regression when it takes this much longer than the baseline.
+	slowdownFactor = 1.3
+	// minSlowdown ignores regressions smaller than this, since short incremental builds are noisy.
+	minSlowdown = 30 * time.Second
+)
+
+// BuildRecord is one kernel build in the build history.
+type BuildRecord struct {
+	Started    time.Time `json:"started"`
+	Seconds    float64   `json:"seconds"`
+	Success    bool      `json:"success"`
+	Arch       string    `json:"arch"`
+	Profile    string    `json:"profile,omitempty"`
+	Jobs       int       `json:"jobs"`
+	Targets    []string  `json:"targets"`
+	Toolchain  string    `json:"toolchain"`             // CROSS_COMPILE prefix
+	Compiler   string    `json:"compiler,omitempty"`    // First line of 'clang --version'
+	GitHead    string    `json:"git_head,omitempty"`    // Short commit hash of the kernel tree
+	ConfigHash string    `json:"config_hash,omitempty"` // Short hash of .config
+	CacheTool  string    `json:"cache_tool,omitempty"`
+	CacheHits  int64     `json:"cache_hits,omitempty"`
+	CacheMiss  int64     `json:"cache_misses,omitempty"`
+}
+
+// Duration returns the wall-clock build time.
+func (r *BuildRecord) Duration() time.Duration {
+	return time.Duration(r.Seconds * float64(time.Second))
+}
+
+// CacheHitRate returns the compiler cache hit rate during the build, or -1 without a cache.
+func (r *BuildRecord) CacheHitRate() float64 {
+	total := r.CacheHits + r.CacheMiss
+	if r.CacheTool == "" || total == 0 {
+		return -1
+	}
+	return float64(r.CacheHits) * 100 / float64(total)
+}
+
+// comparable reports whether two builds did the same work and can be timed against each other.
+func (r *BuildRecord) comparable(o *BuildRecord) bool {
+	return r.Arch == o.Arch && strings.Join(r.Targets, " ") == strings.Join(o.Targets, " ")
+}
+
+// BuildTrend is a history record compared against earlier comparable builds.
+type BuildTrend struct {
+	Record     BuildRecord
+	Baseline   time.Duration // Median of up to historyBaselineSize earlier builds, 0 if none
+	Regression bool
+	Changes    []string // What differs from the previous comparable build
+}
+
+// Slowdown returns how much slower the build was than its baseline, as a ratio.
+func (t *BuildTrend) Slowdown() float64 {
+	if t.Baseline == 0 {
+		return 0
+	}
+	return float64(t.Record.Duration()) / float64(t.Baseline)
+}
+
+// HistoryPath returns the path of the build history file.
+func (b *KernelBuilder) HistoryPath() string {
+	return filepath.Join(b.LogDir(), historyFile)
+}
+
+// startRecord captures the build inputs and the cache counters before a build.
+func (b *KernelBuilder) startRecord(ctx context.Context, env []string, jobs int, targets []string, crossCompile string) (*BuildRecord, *cache.Stats) {
+	rec := &BuildRecord{
+		Started:   time.Now(),
+		Arch:      b.cfg.Build.Arch,
+		Profile:   b.cfg.ActiveProfile,
+		Jobs:      jobs,
+		Targets:   targets,
+		Toolchain: crossCompile,
+	}
+	if out, err := b.exec.OutputWithEnv(ctx, env, "clang", "--version"); err == nil {
+		rec.Compiler = strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+	}
+	if out, err := b.exec.Output(ctx, "git", "-C", b.cfg.Paths.KernelDir, "rev-parse", "--short", "HEAD"); err == nil {
+		rec.GitHead = strings.TrimSpace(string(out))
+	}
+	if data, err := b.fs.ReadFile(b.ConfigPath()); err == nil {
+		sum := sha256.Sum256(data)
+		rec.ConfigHash = hex.EncodeToString(sum[:6])
+	}
+
+	var before *cache.Stats
+	if b.cache.Tool() != "" {
+		before, _ = b.cache.Stats(ctx)
+	}
+	return rec, before
+}
+
+// finishRecord completes a record after the build and appends it to the history.
+// History is best effort: failing to record never fails the build.
+func (b *KernelBuilder) finishRecord(ctx context.Context, rec *BuildRecord, before *cache.Stats, success bool) {
+	rec.Seconds = time.Since(rec.Started).Round(time.Millisecond).Seconds()
+	rec.Success = success
+	if before != nil {
+		if after, err := b.cache.Stats(ctx); err == nil {
+			rec.CacheTool = after.Tool
+			// Counters only grow unless the cache was cleared mid-build
+			if after.Hits >= before.Hits && after.Misses >= before.Misses {
+				rec.CacheHits = after.Hits - before.Hits
+				rec.CacheMiss = after.Misses - before.Misses
+			}
+		}
+	}
+	_ = b.appendHistory(rec)
+}
+
+// appendHistory appends a record to the history file.
+func (b *KernelBuilder) appendHistory(rec *BuildRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	path := b.HistoryPath()
+	if err := b.fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := b.fs.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return b.fs.WriteFile(path, append(append(data, line...), '\n'), 0644)
+}
+
+// History returns the recorded builds, oldest first.
+// Lines that cannot be parsed are skipped.
+func (b *KernelBuilder) History() ([]BuildRecord, error) {
+	data, err := b.fs.ReadFile(b.HistoryPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read build history: %w", err)
+	}
+	var records []BuildRecord
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var rec BuildRecord
+		if json.Unmarshal(scanner.Bytes(), &rec) == nil {
+			records = append(records, rec)
+		}
+	}
+	return records, nil
+}
+
+// BuildTrends compares each successful build against the median of the
+// earlier successful builds of the same arch and targets. Failed builds are
+// included without a baseline.
+func BuildTrends(records []BuildRecord) []BuildTrend {
+	trends := make([]BuildTrend, 0, len(records))
+	for i := range records {
+		t := BuildTrend{Record: records[i]}
+		if !records[i].Success {
+			trends = append(trends, t)
+			continue
+		}
+
+		var earlier []*BuildRecord
+		for j := i - 1; j >= 0 && len(earlier) < historyBaselineSize; j-- {
+			if records[j].Success && records[j].comparable(&records[i]) {
+				earlier = append(earlier, &records[j])
+			}
+		}
+		if len(earlier) > 0 {
+			t.Baseline = medianDuration(earlier)
+			slower := records[i].Duration() - t.Baseline
+			t.Regression = t.Slowdown() >= slowdownFactor && slower >= minSlowdown
+			t.Changes = recordChanges(earlier[0], &records[i])
+		}
+		trends = append(trends, t)
+	}
+	return trends
+}
+
+// medianDuration returns the median build time of the given records.
+func medianDuration(records []*BuildRecord) time.Duration {
+	d := make([]time.Duration, len(records))
+	for i, r := range records {
+		d[i] = r.Duration()
+	}
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	if len(d)%2 == 1 {
+		return d[len(d)/2]
+	}
+	return (d[len(d)/2-1] + d[len(d)/2]) / 2
+}
+
+// recordChanges lists the build inputs that differ between two builds.
+func recordChanges(prev, cur *BuildRecord) []string {
+	var changes []string
+	diff := func(what, a, b string) {
+		if a != b {
+			changes = append(changes, fmt.Sprintf("%s %s -> %s", what, valueOrNone(a), valueOrNone(b)))
+		}
+	}
+	diff("compiler", prev.Compiler, cur.Compiler)
+	diff("toolchain", prev.Toolchain, cur.Toolchain)
+	diff("config", prev.ConfigHash, cur.ConfigHash)
+	diff("HEAD", prev.GitHead, cur.GitHead)
+	if prev.Jobs != cur.Jobs {
+		changes = append(changes, fmt.Sprintf("jobs %d -> %d", prev.Jobs, cur.Jobs))
+	}
+	if p, c := prev.CacheHitRate(), cur.CacheHitRate(); p >= 0 && c >= 0 && p-c >= 20 {
+		changes = append(changes, fmt.Sprintf("cache hit rate %.0f%% -> %.0f%%", p, c))
+	}
+	return changes
+}
+
+// valueOrNone returns s, or "none" when it is empty.
+func valueOrNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
@@ -0,0 +1,52 @@
+// Package builder provides kernel and module build orchestration for elmos.
+// This file contains .config inspection and comparison.
+package builder
+
+import (
+	"fmt"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/kconfig"
+)
+
+// ConfigPath returns the path of the kernel .config.
+func (b *KernelBuilder) ConfigPath() string {
+	return b.ctx.GetKernelConfig()
+}
+
+// LoadConfig parses a config file, or the kernel .config when path is empty.
+func (b *KernelBuilder) LoadConfig(path string) (*kconfig.Config, error) {
+	if path == "" {
+		path = b.ConfigPath()
+		if !b.fs.Exists(path) {
+			return nil, fmt.Errorf(".config not found - run 'elmos kernel config' first")
+		}
+	}
+	f, err := b.fs.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config %s: %w", path, err)
+	}
+	defer f.Close()
+	return kconfig.Parse(f)
+}
+
+// DiffConfigs compares two config files. An empty path means the kernel .config.
+func (b *KernelBuilder) DiffConfigs(pathA, pathB string) ([]kconfig.Change, error) {
+	a, err := b.LoadConfig(pathA)
+	if err != nil {
+		return nil, err
+	}
+	c, err := b.LoadConfig(pathB)
+	if err != nil {
+		return nil, err
+	}
+	return kconfig.Diff(a, c), nil
+}
+
+// CheckConfig returns the requirements the kernel .config does not meet.
+func (b *KernelBuilder) CheckConfig(reqs []kconfig.Requirement) ([]kconfig.Failure, error) {
+	cfg, err := b.LoadConfig("")
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Check(reqs), nil
+}
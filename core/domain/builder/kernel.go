@@ -5,9 +5,11 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	elconfig "github.com/NguyenTrongPhuc552003/elmos/core/config"
 	elcontext "github.com/NguyenTrongPhuc552003/elmos/core/context"
+	"github.com/NguyenTrongPhuc552003/elmos/core/domain/cache"
 	"github.com/NguyenTrongPhuc552003/elmos/core/domain/toolchain"
 	"github.com/NguyenTrongPhuc552003/elmos/core/infra/executor"
 	"github.com/NguyenTrongPhuc552003/elmos/core/infra/filesystem"
@@ -15,21 +17,23 @@ import (
 
 // KernelBuilder orchestrates kernel build operations.
 type KernelBuilder struct {
-	exec executor.Executor
-	fs   filesystem.FileSystem
-	cfg  *elconfig.Config
-	ctx  *elcontext.Context
-	tm   *toolchain.Manager
+	exec  executor.Executor
+	fs    filesystem.FileSystem
+	cfg   *elconfig.Config
+	ctx   *elcontext.Context
+	tm    *toolchain.Manager
+	cache *cache.Manager
 }
 
 // NewKernelBuilder creates a new KernelBuilder with the given dependencies.
-func NewKernelBuilder(exec executor.Executor, fs filesystem.FileSystem, cfg *elconfig.Config, ctx *elcontext.Context, tm *toolchain.Manager) *KernelBuilder {
+func NewKernelBuilder(exec executor.Executor, fs filesystem.FileSystem, cfg *elconfig.Config, ctx *elcontext.Context, tm *toolchain.Manager, cm *cache.Manager) *KernelBuilder {
 	return &KernelBuilder{
-		exec: exec,
-		fs:   fs,
-		cfg:  cfg,
-		ctx:  ctx,
-		tm:   tm,
+		exec:  exec,
+		fs:    fs,
+		cfg:   cfg,
+		ctx:   ctx,
+		tm:    tm,
+		cache: cm,
 	}
 }
 
@@ -43,7 +47,7 @@ type BuildOptions struct {
 func (b *KernelBuilder) Build(ctx context.Context, opts BuildOptions) error {
 	// Validate targets
 	for _, target := range opts.Targets {
-		if !elconfig.ValidBuildTargets[target] {
+		if !b.cfg.IsValidBuildTarget(target) {
 			return fmt.Errorf("invalid build target: %s", target)
 		}
 	}
@@ -60,133 +64,156 @@ func (b *KernelBuilder) Build(ctx context.Context, opts BuildOptions) error {
 		return fmt.Errorf("failed to configure toolchain environment: %w", err)
 	}
 
+	dirArgs, err := kbuildDirArgs(b.ctx)
+	if err != nil {
+		return err
+	}
+
 	// Build make arguments
-	args := []string{
-		"-C", b.cfg.Paths.KernelDir,
+	args := append(dirArgs,
 		fmt.Sprintf("-j%d", jobs),
-		fmt.Sprintf("ARCH=%s", b.cfg.Build.Arch),
+		fmt.Sprintf("ARCH=%s", b.cfg.KernelArch()),
 		"LLVM=1",
 		fmt.Sprintf("CROSS_COMPILE=%s", crossCompile),
-	}
+	)
+	args = append(args, compilerCacheArgs(b.ctx)...)
 	args = append(args, opts.Targets...)
 
-	return b.exec.RunWithEnv(ctx, env, "make", args...)
+	rec, cacheBefore := b.startRecord(ctx, env, jobs, opts.Targets, crossCompile)
+	err = b.runLogged(ctx, env, args)
+	b.finishRecord(ctx, rec, cacheBefore, err == nil)
+	return err
 }
 
-// Configure runs kernel configuration (menuconfig, defconfig, etc.).
-func (b *KernelBuilder) Configure(ctx context.Context, configType string) error {
-	// Validate config type
-	valid := false
-	for _, ct := range elconfig.KernelConfigTypes {
-		if ct == configType {
-			valid = true
-			break
-		}
-	}
-	if !valid {
-		return fmt.Errorf("invalid config type: %s", configType)
+// runLogged runs make while teeing its output to a timestamped build log.
+// On failure the log is parsed and a BuildFailure with the diagnostics is returned.
+func (b *KernelBuilder) runLogged(ctx context.Context, env, args []string) error {
+	logPath := b.newLogPath()
+	if err := b.fs.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
 	}
-
-	// Get environment with correct toolchain
-	env, crossCompile, err := getToolchainEnv(b.ctx, b.cfg, b.tm, b.fs, b.cfg.Build.Arch)
+	logFile, err := b.fs.Create(logPath)
 	if err != nil {
-		return fmt.Errorf("failed to configure toolchain environment: %w", err)
+		return fmt.Errorf("failed to create build log: %w", err)
 	}
 
-	args := []string{
-		"-C", b.cfg.Paths.KernelDir,
-		fmt.Sprintf("ARCH=%s", b.cfg.Build.Arch),
-		"LLVM=1",
-		fmt.Sprintf("CROSS_COMPILE=%s", crossCompile),
-		configType,
+	runErr := b.exec.RunWithEnvTee(ctx, env, logFile, "make", args...)
+	if err := logFile.Close(); err != nil && runErr == nil {
+		return fmt.Errorf("failed to write build log: %w", err)
 	}
-
-	if err := b.exec.RunWithEnv(ctx, env, "make", args...); err != nil {
-		return err
+	if runErr == nil {
+		return nil
 	}
 
-	// For kvm_guest.config, force graphics options to be built-in (=y)
-	if configType == "kvm_guest.config" {
-		if err := b.forceGraphicsConfig(ctx); err != nil {
-			return fmt.Errorf("failed to enable graphics options: %w", err)
-		}
+	f, err := b.fs.Open(logPath)
+	if err != nil {
+		return runErr
 	}
-
-	return nil
+	defer f.Close()
+	return &BuildFailure{Summary: SummarizeBuildLog(logPath, ParseBuildLog(f)), Err: runErr}
 }
 
-// forceGraphicsConfig forces graphics-related options to be built-in for QEMU GUI.
-func (b *KernelBuilder) forceGraphicsConfig(ctx context.Context) error {
-	scriptPath := filepath.Join(b.cfg.Paths.KernelDir, "scripts", "config")
-	configFile := filepath.Join(b.cfg.Paths.KernelDir, ".config")
-
-	// Use scripts/config to force graphics options to =y (built-in)
-	options := []string{
-		"--enable", "CONFIG_DRM",
-		"--enable", "CONFIG_DRM_VIRTIO_GPU",
-		"--enable", "CONFIG_FB",
-		"--enable", "CONFIG_FRAMEBUFFER_CONSOLE",
+// Configure runs kernel configuration (menuconfig, defconfig, etc.).
+func (b *KernelBuilder) Configure(ctx context.Context, configType string) error {
+	// Validate config type
+	if !elconfig.IsValidKernelConfigType(configType) {
+		return fmt.Errorf("invalid config type: %s", configType)
 	}
 
-	configArgs := append([]string{"--file", configFile}, options...)
-	if err := b.exec.Run(ctx, scriptPath, configArgs...); err != nil {
-		return fmt.Errorf("scripts/config failed: %w", err)
+	// Get environment with correct toolchain
+	env, crossCompile, err := getToolchainEnv(b.ctx, b.cfg, b.tm, b.fs, b.cfg.Build.Arch)
+	if err != nil {
+		return fmt.Errorf("failed to configure toolchain environment: %w", err)
 	}
 
-	// Run olddefconfig to finalize the config
-	args := []string{
-		"-C", b.cfg.Paths.KernelDir,
-		fmt.Sprintf("ARCH=%s", b.cfg.Build.Arch),
-		"LLVM=1",
-		"olddefconfig",
+	dirArgs, err := kbuildDirArgs(b.ctx)
+	if err != nil {
+		return err
 	}
+	args := append(dirArgs,
+		fmt.Sprintf("ARCH=%s", b.cfg.KernelArch()),
+		"LLVM=1",
+		fmt.Sprintf("CROSS_COMPILE=%s", crossCompile),
+	)
+	args = append(args, compilerCacheArgs(b.ctx)...)
+	args = append(args, configType)
 
-	env := b.ctx.GetMakeEnv()
 	return b.exec.RunWithEnv(ctx, env, "make", args...)
 }
 
-// EnableKVMConfig enables KVM-specific kernel config options.
-func (b *KernelBuilder) EnableKVMConfig(ctx context.Context) error {
-	configFile := filepath.Join(b.cfg.Paths.KernelDir, ".config")
+// baseConfigTypes are the config targets that generate a fresh .config,
+// after which the configured fragments are merged.
+var baseConfigTypes = map[string]bool{
+	"defconfig":        true,
+	"tinyconfig":       true,
+	"kvm_guest.config": true,
+	"allnoconfig":      true,
+	"allyesconfig":     true,
+	"allmodconfig":     true,
+}
 
-	if !b.fs.Exists(configFile) {
-		return fmt.Errorf(".config not found - run 'elmos kernel config' first")
+// ConfigFragments returns the fragments to merge after running configType:
+// build.fragments from the config for base config types, then extra.
+// kvm_guest.config always gets the graphics fragment for the QEMU GUI.
+func (b *KernelBuilder) ConfigFragments(configType string, extra []string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
 	}
-
-	// Read current config
-	content, err := b.fs.ReadFile(configFile)
-	if err != nil {
-		return err
+	if configType == "kvm_guest.config" {
+		add("graphics")
 	}
-
-	// This is a simplified implementation
-	// In practice, you'd use scripts/config or similar
-	_ = content // For now, just run olddefconfig which applies defaults
-
-	args := []string{
-		"-C", b.cfg.Paths.KernelDir,
-		fmt.Sprintf("ARCH=%s", b.cfg.Build.Arch),
-		"LLVM=1",
-		"olddefconfig",
+	if baseConfigTypes[configType] || strings.HasSuffix(configType, "_defconfig") {
+		for _, name := range b.cfg.Build.Fragments {
+			add(name)
+		}
 	}
-
-	env := b.ctx.GetMakeEnv()
-	return b.exec.RunWithEnv(ctx, env, "make", args...)
+	for _, name := range extra {
+		add(name)
+	}
+	return names
 }
 
-// Clean runs distclean on the kernel source.
+// Clean runs distclean on the kernel build tree of the current architecture.
 func (b *KernelBuilder) Clean(ctx context.Context) error {
-	args := []string{
-		"-C", b.cfg.Paths.KernelDir,
-		fmt.Sprintf("ARCH=%s", b.cfg.Build.Arch),
+	args := []string{"-C", b.cfg.Paths.KernelDir}
+	if b.cfg.Build.OutOfTree {
+		args = append(args, "O="+b.ctx.GetKernelOutDir())
+	}
+	args = append(args,
+		fmt.Sprintf("ARCH=%s", b.cfg.KernelArch()),
 		"LLVM=1",
 		"distclean",
-	}
+	)
 
 	env := b.ctx.GetMakeEnv()
 	return b.exec.RunWithEnvSilent(ctx, env, "make", args...)
 }
 
+// CleanSource runs mrproper in the kernel source tree, removing an in-tree
+// build that would otherwise block out-of-tree builds.
+func (b *KernelBuilder) CleanSource(ctx context.Context) error {
+	env := b.ctx.GetMakeEnv()
+	return b.exec.RunWithEnvSilent(ctx, env, "make", "-C", b.cfg.Paths.KernelDir, "mrproper")
+}
+
+// kbuildDirArgs returns the make arguments selecting the kernel source tree and,
+// for out-of-tree builds, the per-arch output directory.
+func kbuildDirArgs(ctx *elcontext.Context) ([]string, error) {
+	args := []string{"-C", ctx.Config.Paths.KernelDir}
+	if !ctx.Config.Build.OutOfTree {
+		return args, nil
+	}
+	if ctx.HasInTreeBuild() {
+		return nil, fmt.Errorf("kernel source tree has an in-tree build, which blocks O= builds (run 'elmos kernel clean --source')")
+	}
+	return append(args, "O="+ctx.GetKernelOutDir()), nil
+}
+
 // GetDefaultTargets returns the default build targets for the current architecture.
 func (b *KernelBuilder) GetDefaultTargets() []string {
 	return b.ctx.GetDefaultTargets()
@@ -0,0 +1,221 @@
+// Package builder provides kernel and module build orchestration for elmos.
+// This file contains .modinfo parsing of built modules and vermagic checks.
+package builder
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/kconfig"
+)
+
+// ModParam is a module parameter declared with module_param().
+type ModParam struct {
+	Name        string `json:"name" yaml:"name"`
+	Type        string `json:"type,omitempty" yaml:"type,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// ModInfo is the metadata stored in the .modinfo section of a built module.
+type ModInfo struct {
+	Name        string     `json:"name,omitempty" yaml:"name,omitempty"`
+	License     string     `json:"license,omitempty" yaml:"license,omitempty"`
+	Author      string     `json:"author,omitempty" yaml:"author,omitempty"` // Several MODULE_AUTHOR()s are joined with ", "
+	Description string     `json:"description,omitempty" yaml:"description,omitempty"`
+	Version     string     `json:"version,omitempty" yaml:"version,omitempty"`
+	SrcVersion  string     `json:"srcversion,omitempty" yaml:"srcversion,omitempty"`
+	Vermagic    string     `json:"vermagic,omitempty" yaml:"vermagic,omitempty"`
+	Machine     string     `json:"machine,omitempty" yaml:"machine,omitempty"` // ELF e_machine, e.g. EM_AARCH64
+	Depends     []string   `json:"depends,omitempty" yaml:"depends,omitempty"`
+	Params      []ModParam `json:"params,omitempty" yaml:"params,omitempty"`
+	Aliases     []string   `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+}
+
+// vermagicTokens are the option tokens of a vermagic string that are
+// compared with the kernel. Architecture-specific tokens are not.
+var vermagicTokens = []string{"SMP", "preempt", "preempt_rt", "mod_unload", "modversions"}
+
+// archMachines maps kernel ARCH= values to the ELF machines of their modules.
+// um is missing: its modules are built for the host.
+var archMachines = map[string][]elf.Machine{
+	"arm":       {elf.EM_ARM},
+	"arm64":     {elf.EM_AARCH64},
+	"i386":      {elf.EM_386},
+	"loongarch": {elf.EM_LOONGARCH},
+	"mips":      {elf.EM_MIPS},
+	"powerpc":   {elf.EM_PPC64, elf.EM_PPC},
+	"riscv":     {elf.EM_RISCV},
+	"s390":      {elf.EM_S390},
+	"sparc":     {elf.EM_SPARCV9, elf.EM_SPARC},
+	"x86":       {elf.EM_X86_64, elf.EM_386},
+	"x86_64":    {elf.EM_X86_64},
+}
+
+// kernelVermagicTokens returns which vermagicTokens a kernel config produces,
+// following include/linux/vermagic.h.
+func kernelVermagicTokens(cfg *kconfig.Config) map[string]bool {
+	rt := cfg.BuiltIn("PREEMPT_RT")
+	return map[string]bool{
+		"SMP":        cfg.BuiltIn("SMP"),
+		"preempt_rt": rt,
+		// Kernels before 5.15 have no PREEMPT_BUILD
+		"preempt":     !rt && (cfg.BuiltIn("PREEMPT_BUILD") || cfg.BuiltIn("PREEMPT")),
+		"mod_unload":  cfg.BuiltIn("MODULE_UNLOAD"),
+		"modversions": cfg.BuiltIn("MODVERSIONS"),
+	}
+}
+
+// ReadModInfo reads the .modinfo section of a kernel module file.
+func (m *ModuleBuilder) ReadModInfo(koPath string) (*ModInfo, error) {
+	f, err := m.fs.Open(koPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", koPath, err)
+	}
+	defer f.Close()
+
+	ef, err := elf.NewFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not an ELF file: %w", filepath.Base(koPath), err)
+	}
+	sec := ef.Section(".modinfo")
+	if sec == nil {
+		return nil, fmt.Errorf("%s has no .modinfo section", filepath.Base(koPath))
+	}
+	data, err := sec.Data()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .modinfo of %s: %w", filepath.Base(koPath), err)
+	}
+	info := parseModInfo(data)
+	info.Machine = ef.Machine.String()
+	return info, nil
+}
+
+// parseModInfo parses the NUL-separated key=value entries of a .modinfo section.
+func parseModInfo(data []byte) *ModInfo {
+	info := &ModInfo{}
+	var authors []string
+	params := make(map[string]*ModParam)
+	var paramOrder []string
+	param := func(name string) *ModParam {
+		if p, ok := params[name]; ok {
+			return p
+		}
+		params[name] = &ModParam{Name: name}
+		paramOrder = append(paramOrder, name)
+		return params[name]
+	}
+
+	for _, entry := range bytes.Split(data, []byte{0}) {
+		key, value, ok := strings.Cut(string(entry), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "name":
+			info.Name = value
+		case "license":
+			info.License = value
+		case "author":
+			authors = append(authors, value)
+		case "description":
+			info.Description = value
+		case "version":
+			info.Version = value
+		case "srcversion":
+			info.SrcVersion = value
+		case "vermagic":
+			info.Vermagic = strings.TrimSpace(value)
+		case "depends":
+			for _, dep := range strings.Split(value, ",") {
+				if dep != "" {
+					info.Depends = append(info.Depends, dep)
+				}
+			}
+		case "alias":
+			info.Aliases = append(info.Aliases, value)
+		case "parm":
+			// parm=<name>:<description>
+			name, desc, _ := strings.Cut(value, ":")
+			param(name).Description = desc
+		case "parmtype":
+			// parmtype=<name>:<type>
+			name, typ, _ := strings.Cut(value, ":")
+			param(name).Type = typ
+		}
+	}
+
+	info.Author = strings.Join(authors, ", ")
+	for _, name := range paramOrder {
+		info.Params = append(info.Params, *params[name])
+	}
+	return info
+}
+
+// KernelRelease returns the release of the current kernel build, as written
+// by kbuild to include/config/kernel.release, or "" before the first build.
+func (m *ModuleBuilder) KernelRelease() string {
+	data, err := m.fs.ReadFile(filepath.Join(m.ctx.GetKernelOutDir(), "include", "config", "kernel.release"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// CheckVermagic compares a module with the current architecture and kernel
+// build: its ELF machine, and the release and the SMP, preemption, module
+// unloading and modversions options of its vermagic. It returns the
+// differences, and false when there is nothing to compare against.
+func (m *ModuleBuilder) CheckVermagic(info *ModInfo) ([]string, bool) {
+	var mismatches []string
+	if mm := m.checkMachine(info.Machine); mm != "" {
+		mismatches = append(mismatches, mm)
+	}
+
+	release := m.KernelRelease()
+	data, err := m.fs.ReadFile(m.ctx.GetKernelConfig())
+	if release == "" || err != nil {
+		return mismatches, len(mismatches) > 0
+	}
+
+	fields := strings.Fields(info.Vermagic)
+	if len(fields) == 0 {
+		return append(mismatches, "module has no vermagic"), true
+	}
+	if fields[0] != release {
+		mismatches = append(mismatches, fmt.Sprintf("built for %s, kernel is %s", fields[0], release))
+	}
+
+	has := make(map[string]bool, len(fields))
+	for _, f := range fields[1:] {
+		has[f] = true
+	}
+	want := kernelVermagicTokens(kconfig.ParseBytes(data))
+	for _, token := range vermagicTokens {
+		switch {
+		case has[token] && !want[token]:
+			mismatches = append(mismatches, fmt.Sprintf("module has %s, kernel does not", token))
+		case !has[token] && want[token]:
+			mismatches = append(mismatches, fmt.Sprintf("kernel has %s, module does not", token))
+		}
+	}
+	return mismatches, true
+}
+
+// checkMachine describes how a module's ELF machine differs from the current
+// architecture, or returns "" when it matches or cannot be checked.
+func (m *ModuleBuilder) checkMachine(machine string) string {
+	kernelArch := m.cfg.KernelArch()
+	want, ok := archMachines[kernelArch]
+	if machine == "" || !ok {
+		return ""
+	}
+	for _, w := range want {
+		if machine == w.String() {
+			return ""
+		}
+	}
+	return fmt.Sprintf("built for %s, %s expects %s", machine, kernelArch, want[0])
+}
@@ -2,14 +2,13 @@
 package builder
 
 import (
-	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"path/filepath"
 	"strings"
-	"text/template"
+	"time"
 
-	"github.com/NguyenTrongPhuc552003/elmos/assets"
 	elconfig "github.com/NguyenTrongPhuc552003/elmos/core/config"
 	elcontext "github.com/NguyenTrongPhuc552003/elmos/core/context"
 	"github.com/NguyenTrongPhuc552003/elmos/core/domain/toolchain"
@@ -19,10 +18,14 @@ import (
 
 // ModuleInfo contains information about a kernel module.
 type ModuleInfo struct {
-	Name        string
-	Path        string
-	Description string
-	Built       bool
+	Name        string `json:"name" yaml:"name"`
+	Path        string `json:"path" yaml:"path"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Built       bool   `json:"built" yaml:"built"`
+
+	// From the built .ko; VermagicMismatch lists how it differs from the kernel build
+	Vermagic         string   `json:"vermagic,omitempty" yaml:"vermagic,omitempty"`
+	VermagicMismatch []string `json:"vermagic_mismatch,omitempty" yaml:"vermagic_mismatch,omitempty"`
 }
 
 // ModuleBuilder orchestrates kernel module build operations.
@@ -45,44 +48,156 @@ func NewModuleBuilder(exec executor.Executor, fs filesystem.FileSystem, cfg *elc
 	}
 }
 
-// Build builds one or all kernel modules.
+// Build builds one or all kernel modules, one at a time, stopping at the
+// first failure. Up-to-date modules are skipped.
 func (m *ModuleBuilder) Build(ctx context.Context, name string) error {
-	modules, err := m.GetModules(name)
+	report, err := m.BuildWithOptions(ctx, name, TargetBuildOptions{Jobs: 1})
 	if err != nil {
 		return err
 	}
+	return report.Err()
+}
 
-	if len(modules) == 0 {
-		return nil // No modules to build
+// BuildWithOptions builds all modules, or one module and the modules it
+// depends on. Independent modules are built concurrently, and modules whose
+// .ko is newer than their sources, the kernel and their dependencies, and
+// that were built for the same architecture, kernel and toolchain, are
+// skipped unless opts.Force is set.
+func (m *ModuleBuilder) BuildWithOptions(ctx context.Context, name string, opts TargetBuildOptions) (*BuildReport, error) {
+	modules, err := m.getAllModules()
+	if err != nil && name == "" {
+		return nil, err
 	}
-
-	for _, mod := range modules {
-		if err := m.buildModule(ctx, mod); err != nil {
-			return err
+	if name != "" && !containsModule(modules, name) {
+		mod, err := m.getSpecificModule(name)
+		if err != nil {
+			return nil, err
 		}
+		modules = append(modules, mod...)
+	}
+	if len(modules) == 0 {
+		return &BuildReport{}, nil // No modules to build
 	}
 
-	return nil
-}
-
-// buildModule builds a single module.
-func (m *ModuleBuilder) buildModule(ctx context.Context, mod ModuleInfo) error {
 	// Get environment with correct toolchain
 	env, crossCompile, err := getToolchainEnv(m.ctx, m.cfg, m.tm, m.fs, m.cfg.Build.Arch)
 	if err != nil {
-		return fmt.Errorf("failed to configure toolchain environment: %w", err)
+		return nil, fmt.Errorf("failed to configure toolchain environment: %w", err)
+	}
+	dirArgs, err := kbuildDirArgs(m.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Modules built before the kernel was last rebuilt must be rebuilt too
+	var kernelTime time.Time
+	if info, err := m.fs.Stat(filepath.Join(m.ctx.GetKernelOutDir(), "Module.symvers")); err == nil {
+		kernelTime = info.ModTime()
+	}
+	stamp := buildStamp(
+		"arch", m.cfg.KernelArch(),
+		"kernel_out", m.ctx.GetKernelOutDir(),
+		"kernel_release", m.KernelRelease(),
+		"cross_compile", crossCompile,
+	)
+
+	known := make(map[string]bool, len(modules))
+	for _, mod := range modules {
+		known[mod.Name] = true
+	}
+	targets := make([]buildTarget, 0, len(modules))
+	for _, mod := range modules {
+		mod := mod
+		deps := m.moduleDeps(mod, known)
+		targets = append(targets, buildTarget{
+			name: mod.Name,
+			deps: deps,
+			stale: func() bool {
+				inputs := []time.Time{SourcesModTime(m.fs, mod.Path), kernelTime}
+				for _, dep := range deps {
+					if info, err := m.fs.Stat(m.koPath(dep)); err == nil {
+						inputs = append(inputs, info.ModTime())
+					}
+				}
+				return olderThan(m.fs, m.koPath(mod.Name), inputs...) || stampChanged(m.fs, m.koPath(mod.Name), stamp)
+			},
+			build: func(ctx context.Context, w io.Writer) error {
+				if err := m.buildModule(ctx, mod, env, crossCompile, dirArgs, w); err != nil {
+					return err
+				}
+				writeStamp(m.fs, m.koPath(mod.Name), stamp)
+				return nil
+			},
+		})
+	}
+	if name != "" {
+		targets = withDeps(targets, name)
 	}
 
-	args := []string{
-		"-C", m.cfg.Paths.KernelDir,
+	if opts.Jobs == 0 {
+		opts.Jobs = m.cfg.Build.Jobs
+	}
+	s := &scheduler{fs: m.fs, logDir: m.ctx.GetLogDir(), kind: "module", opts: opts}
+	return s.run(ctx, targets)
+}
+
+// buildModule builds a single module, sending make's output to w, or to the terminal when w is nil.
+func (m *ModuleBuilder) buildModule(ctx context.Context, mod ModuleInfo, env []string, crossCompile string, dirArgs []string, w io.Writer) error {
+	args := append(append([]string(nil), dirArgs...),
 		fmt.Sprintf("M=%s", mod.Path),
-		fmt.Sprintf("ARCH=%s", m.cfg.Build.Arch),
+		fmt.Sprintf("ARCH=%s", m.cfg.KernelArch()),
 		"LLVM=1",
 		fmt.Sprintf("CROSS_COMPILE=%s", crossCompile),
-		"modules",
+	)
+	args = append(args, compilerCacheArgs(m.ctx)...)
+	args = append(args, "modules")
+
+	return runTo(ctx, m.exec, env, "", w, "make", args...)
+}
+
+// koPath returns the module file a module's build produces.
+func (m *ModuleBuilder) koPath(name string) string {
+	return filepath.Join(m.cfg.Paths.ModulesDir, name, name+".ko")
+}
+
+// moduleDeps returns the modules whose Module.symvers a module lists in
+// KBUILD_EXTRA_SYMBOLS, i.e. the modules whose symbols it uses.
+func (m *ModuleBuilder) moduleDeps(mod ModuleInfo, known map[string]bool) []string {
+	var deps []string
+	seen := make(map[string]bool)
+	for _, file := range []string{"Makefile", "Kbuild"} {
+		data, err := m.fs.ReadFile(filepath.Join(mod.Path, file))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if !strings.Contains(line, "KBUILD_EXTRA_SYMBOLS") {
+				continue
+			}
+			for _, field := range strings.Fields(line) {
+				field = strings.Trim(field, `"'`)
+				if !strings.HasSuffix(field, "/Module.symvers") {
+					continue
+				}
+				dep := filepath.Base(filepath.Dir(field))
+				if known[dep] && dep != mod.Name && !seen[dep] {
+					seen[dep] = true
+					deps = append(deps, dep)
+				}
+			}
+		}
 	}
+	return deps
+}
 
-	return m.exec.RunWithEnv(ctx, env, "make", args...)
+// containsModule reports whether a module list includes the named module.
+func containsModule(modules []ModuleInfo, name string) bool {
+	for _, mod := range modules {
+		if mod.Name == name {
+			return true
+		}
+	}
+	return false
 }
 
 // Clean cleans one or all kernel modules.
@@ -92,13 +207,16 @@ func (m *ModuleBuilder) Clean(ctx context.Context, name string) error {
 		return err
 	}
 
+	dirArgs, err := kbuildDirArgs(m.ctx)
+	if err != nil {
+		return err
+	}
 	for _, mod := range modules {
-		args := []string{
-			"-C", m.cfg.Paths.KernelDir,
+		args := append(append([]string(nil), dirArgs...),
 			fmt.Sprintf("M=%s", mod.Path),
-			fmt.Sprintf("ARCH=%s", m.cfg.Build.Arch),
+			fmt.Sprintf("ARCH=%s", m.cfg.KernelArch()),
 			"clean",
-		}
+		)
 
 		// Ignore errors during clean
 		_ = m.exec.Run(ctx, "make", args...)
@@ -166,15 +284,48 @@ func (m *ModuleBuilder) getModuleInfo(name, path string) ModuleInfo {
 	koFile := filepath.Join(path, name+".ko")
 	info.Built = m.fs.Exists(koFile)
 
-	// Extract description from source file
-	srcFile := filepath.Join(path, name+".c")
-	if content, err := m.fs.ReadFile(srcFile); err == nil {
-		info.Description = extractModuleDescription(string(content))
+	info.Description = m.sourceDescription(name, path)
+
+	// The built module is authoritative
+	if info.Built {
+		if mi, err := m.ReadModInfo(koFile); err == nil {
+			if mi.Description != "" {
+				info.Description = mi.Description
+			}
+			info.Vermagic = mi.Vermagic
+			info.VermagicMismatch, _ = m.CheckVermagic(mi)
+		}
 	}
 
 	return info
 }
 
+// sourceDescription returns the MODULE_DESCRIPTION of a module's sources,
+// looking in <name>.c first and then in the other .c files, as a
+// multi-file Kbuild module has no <name>.c.
+func (m *ModuleBuilder) sourceDescription(name, path string) string {
+	if content, err := m.fs.ReadFile(filepath.Join(path, name+".c")); err == nil {
+		if desc := extractModuleDescription(string(content)); desc != "" {
+			return desc
+		}
+	}
+	entries, err := m.fs.ReadDir(path)
+	if err != nil {
+		return ""
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".c") || strings.HasSuffix(e.Name(), ".mod.c") || e.Name() == name+".c" {
+			continue
+		}
+		if content, err := m.fs.ReadFile(filepath.Join(path, e.Name())); err == nil {
+			if desc := extractModuleDescription(string(content)); desc != "" {
+				return desc
+			}
+		}
+	}
+	return ""
+}
+
 // extractModuleDescription extracts MODULE_DESCRIPTION from source code.
 func extractModuleDescription(content string) string {
 	lines := strings.Split(content, "\n")
@@ -198,88 +349,18 @@ func (m *ModuleBuilder) PrepareHeaders(ctx context.Context) error {
 		return fmt.Errorf("failed to configure toolchain environment: %w", err)
 	}
 
-	args := []string{
-		"-C", m.cfg.Paths.KernelDir,
+	dirArgs, err := kbuildDirArgs(m.ctx)
+	if err != nil {
+		return err
+	}
+	args := append(dirArgs,
 		fmt.Sprintf("-j%d", m.cfg.Build.Jobs),
-		fmt.Sprintf("ARCH=%s", m.cfg.Build.Arch),
+		fmt.Sprintf("ARCH=%s", m.cfg.KernelArch()),
 		"LLVM=1",
 		fmt.Sprintf("CROSS_COMPILE=%s", crossCompile),
-		"modules_prepare",
-	}
+	)
+	args = append(args, compilerCacheArgs(m.ctx)...)
+	args = append(args, "modules_prepare")
 
 	return m.exec.RunWithEnv(ctx, env, "make", args...)
 }
-
-// CreateModule creates a new module from template.
-func (m *ModuleBuilder) CreateModule(name string) error {
-	modPath := filepath.Join(m.cfg.Paths.ModulesDir, name)
-
-	// Check if already exists
-	if m.fs.Exists(modPath) {
-		return fmt.Errorf("module already exists: %s", name)
-	}
-
-	// Create directory
-	if err := m.fs.MkdirAll(modPath, 0755); err != nil {
-		return err
-	}
-
-	// Convert name to valid C identifier (replace dashes with underscores)
-	cName := strings.ReplaceAll(name, "-", "_")
-
-	// Template data
-	data := struct {
-		Name        string
-		CName       string
-		Description string
-	}{
-		Name:        name,
-		CName:       cName,
-		Description: "A simple kernel module",
-	}
-
-	// Load and execute source template
-	srcTmpl, err := assets.GetModuleTemplate()
-	if err != nil {
-		return fmt.Errorf("failed to load module template: %w", err)
-	}
-
-	srcContent, err := executeModuleTemplate("module.c", string(srcTmpl), data)
-	if err != nil {
-		return fmt.Errorf("failed to execute module template: %w", err)
-	}
-
-	srcPath := filepath.Join(modPath, cName+".c")
-	if err := m.fs.WriteFile(srcPath, []byte(srcContent), 0644); err != nil {
-		return err
-	}
-
-	// Load and execute Makefile template
-	makeTmpl, err := assets.GetModuleMakefile()
-	if err != nil {
-		return fmt.Errorf("failed to load module makefile template: %w", err)
-	}
-
-	makeContent, err := executeModuleTemplate("Makefile", string(makeTmpl), data)
-	if err != nil {
-		return fmt.Errorf("failed to execute module makefile template: %w", err)
-	}
-
-	makePath := filepath.Join(modPath, "Makefile")
-	return m.fs.WriteFile(makePath, []byte(makeContent), 0644)
-}
-
-// executeModuleTemplate executes a Go template with the given data.
-func executeModuleTemplate(name, tmplContent string, data interface{}) (string, error) {
-	tmpl, err := template.New(name).Parse(tmplContent)
-	if err != nil {
-		return "", err
-	}
-
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return "", err
-	}
-
-	return buf.String(), nil
-}
@@ -0,0 +1,320 @@
+// Package builder provides kernel and module build orchestration for elmos.
+// This file contains module scaffolding from built-in and workspace templates.
+package builder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/NguyenTrongPhuc552003/elmos/assets"
+)
+
+// Module template sources, in increasing order of precedence.
+const (
+	TemplateSourceBuiltin   = "builtin"
+	TemplateSourceWorkspace = "workspace"
+)
+
+// DefaultModuleTemplate is the template used when none is given.
+const DefaultModuleTemplate = "basic"
+
+// templateExt is stripped from template file names.
+const templateExt = ".tmpl"
+
+// builtinTemplateDescriptions describes the embedded module templates.
+var builtinTemplateDescriptions = map[string]string{
+	"basic":     "Single source file with init and exit",
+	"char":      "Character device with a read/write buffer",
+	"misc":      "Misc device with a dynamic minor",
+	"platform":  "Platform driver with a test device",
+	"netfilter": "IPv4 netfilter hooks counting packets",
+	"sysfs":     "Attributes under /sys/kernel",
+	"debugfs":   "Files under /sys/kernel/debug",
+	"kthread":   "Kernel thread running a periodic worker",
+}
+
+// moduleLicenses maps the MODULE_LICENSE() strings the kernel accepts
+// (include/linux/license.h) to the SPDX identifier used in source headers.
+var moduleLicenses = map[string]string{
+	"GPL":                       "GPL-2.0",
+	"GPL v2":                    "GPL-2.0",
+	"GPL and additional rights": "GPL-2.0",
+	"Dual BSD/GPL":              "GPL-2.0 OR BSD-3-Clause",
+	"Dual MIT/GPL":              "GPL-2.0 OR MIT",
+	"Dual MPL/GPL":              "GPL-2.0 OR MPL-1.1",
+	"Proprietary":               "LicenseRef-Proprietary",
+}
+
+// moduleNameRe matches names that stay valid C identifiers once dashes
+// become underscores.
+var moduleNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// ModuleTemplate is a named set of files a module is created from.
+type ModuleTemplate struct {
+	Name        string `json:"name" yaml:"name"`
+	Source      string `json:"source" yaml:"source"`                 // builtin or workspace
+	Path        string `json:"path,omitempty" yaml:"path,omitempty"` // Empty for builtin templates
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// ModuleScaffoldOptions controls how CreateModule fills in a template.
+type ModuleScaffoldOptions struct {
+	Template    string // Template name; empty uses DefaultModuleTemplate
+	Author      string // Empty uses the git user, or "Your Name"
+	License     string // MODULE_LICENSE() string; empty uses "GPL"
+	Description string // Empty uses "A simple kernel module"
+}
+
+// moduleTemplateData is the data module templates are executed with,
+// including their file names.
+type moduleTemplateData struct {
+	Name        string // Module name, as given
+	CName       string // Name as a C identifier
+	Upper       string // CName in upper case, for macros and include guards
+	Template    string
+	Author      string // Escaped for C string literals, as is Description
+	License     string
+	SPDX        string
+	Description string
+}
+
+// ModuleTemplatesDir returns the workspace directory whose templates
+// override the built-in ones.
+func (m *ModuleBuilder) ModuleTemplatesDir() string {
+	return filepath.Join(m.cfg.Image.MountPoint, "templates", "module")
+}
+
+// ListModuleTemplates returns all available module templates sorted by name.
+// A workspace template overrides a builtin one of the same name.
+func (m *ModuleBuilder) ListModuleTemplates() ([]ModuleTemplate, error) {
+	byName := make(map[string]ModuleTemplate)
+
+	builtin, err := assets.GetModuleTemplateNames()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read builtin module templates: %w", err)
+	}
+	for _, name := range builtin {
+		byName[name] = ModuleTemplate{Name: name, Source: TemplateSourceBuiltin, Description: builtinTemplateDescriptions[name]}
+	}
+
+	dir := m.ModuleTemplatesDir()
+	if entries, err := m.fs.ReadDir(dir); err == nil {
+		for _, e := range entries {
+			if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			byName[e.Name()] = ModuleTemplate{Name: e.Name(), Source: TemplateSourceWorkspace, Path: filepath.Join(dir, e.Name())}
+		}
+	}
+
+	templates := make([]ModuleTemplate, 0, len(byName))
+	for _, t := range byName {
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+// ModuleLicenses returns the licenses accepted by CreateModule.
+func ModuleLicenses() []string {
+	licenses := make([]string, 0, len(moduleLicenses))
+	for l := range moduleLicenses {
+		licenses = append(licenses, l)
+	}
+	sort.Strings(licenses)
+	return licenses
+}
+
+// CreateModule creates a new module from a template and returns the files
+// it wrote. Template file names are templates too, and a .tmpl suffix is
+// dropped.
+func (m *ModuleBuilder) CreateModule(ctx context.Context, name string, opts ModuleScaffoldOptions) ([]string, error) {
+	if !moduleNameRe.MatchString(name) {
+		return nil, fmt.Errorf("invalid module name %q: use letters, digits, '-' and '_'", name)
+	}
+	modPath := filepath.Join(m.cfg.Paths.ModulesDir, name)
+
+	// Check if already exists
+	if m.fs.Exists(modPath) {
+		return nil, fmt.Errorf("module already exists: %s", name)
+	}
+
+	data, err := m.scaffoldData(ctx, name, opts)
+	if err != nil {
+		return nil, err
+	}
+	files, err := m.moduleTemplateFiles(data.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	// Render everything first so that a broken template leaves nothing behind
+	rendered := make(map[string][]byte, len(files))
+	for rel, content := range files {
+		out, err := executeModuleTemplate(rel, rel, data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file name %s in template %s: %w", rel, data.Template, err)
+		}
+		out = filepath.Clean(filepath.FromSlash(strings.TrimSuffix(out, templateExt)))
+		if out == "." || filepath.IsAbs(out) || strings.HasPrefix(out, "..") {
+			return nil, fmt.Errorf("file %s of template %s is outside the module", rel, data.Template)
+		}
+		text, err := executeModuleTemplate(rel, string(content), data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute %s of template %s: %w", rel, data.Template, err)
+		}
+		rendered[out] = []byte(text)
+	}
+
+	paths := make([]string, 0, len(rendered))
+	for rel := range rendered {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+
+	for _, rel := range paths {
+		path := filepath.Join(modPath, rel)
+		if err := m.fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if err := m.fs.WriteFile(path, rendered[rel], 0644); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+// scaffoldData resolves the options into template data.
+func (m *ModuleBuilder) scaffoldData(ctx context.Context, name string, opts ModuleScaffoldOptions) (moduleTemplateData, error) {
+	data := moduleTemplateData{
+		Name:        name,
+		CName:       strings.ReplaceAll(name, "-", "_"),
+		Template:    opts.Template,
+		Author:      opts.Author,
+		License:     opts.License,
+		Description: opts.Description,
+	}
+	data.Upper = strings.ToUpper(data.CName)
+	if data.Template == "" {
+		data.Template = DefaultModuleTemplate
+	}
+	if data.Author == "" {
+		data.Author = m.gitAuthor(ctx)
+	}
+	if data.Description == "" {
+		data.Description = "A simple kernel module"
+	}
+
+	if data.License == "" {
+		data.License = "GPL"
+	}
+	for license, spdx := range moduleLicenses {
+		if strings.EqualFold(data.License, license) {
+			data.License, data.SPDX = license, spdx
+		}
+	}
+	if data.SPDX == "" {
+		return data, fmt.Errorf("unknown module license %q (one of: %s)", opts.License, strings.Join(ModuleLicenses(), ", "))
+	}
+
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ")
+	data.Author = escape.Replace(data.Author)
+	data.Description = escape.Replace(data.Description)
+	return data, nil
+}
+
+// gitAuthor returns "Name <email>" from the git configuration, or a
+// placeholder when git has no user configured.
+func (m *ModuleBuilder) gitAuthor(ctx context.Context) string {
+	out, err := m.exec.Output(ctx, "git", "config", "user.name")
+	name := strings.TrimSpace(string(out))
+	if err != nil || name == "" {
+		return "Your Name"
+	}
+	if out, err := m.exec.Output(ctx, "git", "config", "user.email"); err == nil {
+		if email := strings.TrimSpace(string(out)); email != "" {
+			return fmt.Sprintf("%s <%s>", name, email)
+		}
+	}
+	return name
+}
+
+// moduleTemplateFiles returns the files of a template keyed by their
+// slash-separated path, preferring a workspace template over a builtin one.
+func (m *ModuleBuilder) moduleTemplateFiles(name string) (map[string][]byte, error) {
+	if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("invalid module template name: %s", name)
+	}
+
+	dir := filepath.Join(m.ModuleTemplatesDir(), name)
+	if m.fs.IsDir(dir) {
+		files := make(map[string][]byte)
+		if err := m.readTemplateDir(dir, "", files); err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("module template %s is empty: %s", name, dir)
+		}
+		return files, nil
+	}
+
+	files, err := assets.GetModuleTemplate(name)
+	if err != nil || len(files) == 0 {
+		var names []string
+		if templates, err := m.ListModuleTemplates(); err == nil {
+			for _, t := range templates {
+				names = append(names, t.Name)
+			}
+		}
+		return nil, fmt.Errorf("unknown module template %q (one of: %s)", name, strings.Join(names, ", "))
+	}
+	return files, nil
+}
+
+// readTemplateDir reads the files below dir into files, skipping hidden
+// entries such as editor swap files.
+func (m *ModuleBuilder) readTemplateDir(dir, rel string, files map[string][]byte) error {
+	entries, err := m.fs.ReadDir(filepath.Join(dir, rel))
+	if err != nil {
+		return fmt.Errorf("failed to read module template %s: %w", dir, err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(rel, e.Name())
+		if e.IsDir() {
+			if err := m.readTemplateDir(dir, path, files); err != nil {
+				return err
+			}
+			continue
+		}
+		data, err := m.fs.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			return fmt.Errorf("failed to read module template file %s: %w", path, err)
+		}
+		files[filepath.ToSlash(path)] = data
+	}
+	return nil
+}
+
+// executeModuleTemplate executes a Go template with the given data.
+func executeModuleTemplate(name, tmplContent string, data interface{}) (string, error) {
+	tmpl, err := template.New(name).Parse(tmplContent)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,393 @@
+// Package builder provides kernel and module build orchestration for elmos.
+// This file contains the parallel, incremental scheduler for module and app builds.
+package builder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/executor"
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/filesystem"
+)
+
+// Target build statuses.
+const (
+	TargetBuilt    = "built"
+	TargetUpToDate = "up-to-date"
+	TargetFailed   = "failed"
+	TargetSkipped  = "skipped" // A dependency failed, or the build stopped at an earlier failure
+)
+
+// TargetBuildOptions controls a module or app build.
+type TargetBuildOptions struct {
+	Jobs      int  // Targets built at once; 0 uses build.jobs
+	KeepGoing bool // Build the remaining targets after a failure
+	Force     bool // Rebuild targets that are up to date
+
+	// OnStart and OnDone are called as each target starts and finishes,
+	// from a single goroutine. Either may be nil.
+	OnStart func(name string)
+	OnDone  func(r TargetResult)
+}
+
+// TargetResult is the outcome of one module or app in a build.
+type TargetResult struct {
+	Name    string       `json:"name" yaml:"name"`
+	Status  string       `json:"status" yaml:"status"`
+	Seconds float64      `json:"seconds,omitempty" yaml:"seconds,omitempty"`
+	Reason  string       `json:"reason,omitempty" yaml:"reason,omitempty"` // Why a target was skipped or failed
+	Log     string       `json:"log,omitempty" yaml:"log,omitempty"`       // Build log of a parallel build
+	Errors  []BuildIssue `json:"errors,omitempty" yaml:"errors,omitempty"` // Diagnostics parsed from the log
+}
+
+// BuildReport is the outcome of a module or app build, in scheduling order.
+type BuildReport struct {
+	Results []TargetResult `json:"results" yaml:"results"`
+}
+
+// Count returns how many targets ended with the given status.
+func (r *BuildReport) Count(status string) int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Err returns an error describing the failed targets, or nil if none failed.
+func (r *BuildReport) Err() error {
+	var failed []string
+	for _, res := range r.Results {
+		if res.Status == TargetFailed {
+			failed = append(failed, res.Name)
+		}
+	}
+	switch len(failed) {
+	case 0:
+		return nil
+	case 1:
+		for _, res := range r.Results {
+			if res.Status == TargetFailed && res.Reason != "" {
+				return fmt.Errorf("%s: %s", res.Name, res.Reason)
+			}
+		}
+	}
+	return fmt.Errorf("%d of %d build(s) failed: %s", len(failed), len(r.Results), strings.Join(failed, ", "))
+}
+
+// buildTarget is one module or app handed to the scheduler.
+type buildTarget struct {
+	name string
+	deps []string // Targets that must be built first
+	// stale reports whether the target needs a rebuild when none of its deps was rebuilt
+	stale func() bool
+	// build runs the build, sending output to w, or to the terminal when w is nil
+	build func(ctx context.Context, w io.Writer) error
+}
+
+// scheduler builds targets concurrently in dependency order.
+type scheduler struct {
+	fs     filesystem.FileSystem
+	logDir string // Per-target logs of parallel builds
+	kind   string // "module" or "app", used in log names
+	opts   TargetBuildOptions
+}
+
+// run builds the targets and returns a result for each. Targets whose
+// dependencies were all up to date are only rebuilt when stale.
+func (s *scheduler) run(ctx context.Context, targets []buildTarget) (*BuildReport, error) {
+	if err := checkCycles(targets); err != nil {
+		return nil, err
+	}
+	jobs := s.opts.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	// Output is only captured when builds can overlap
+	capture := jobs > 1 && len(targets) > 1
+
+	results := make(map[string]TargetResult, len(targets))
+	started := make(map[string]bool, len(targets))
+	done := make(chan TargetResult)
+	running, stopped := 0, false
+
+	finish := func(res TargetResult) {
+		results[res.Name] = res
+		if s.opts.OnDone != nil {
+			s.opts.OnDone(res)
+		}
+	}
+
+	for len(results) < len(targets) {
+		progressed := false
+		for _, t := range targets {
+			if started[t.name] {
+				continue
+			}
+			if stopped {
+				started[t.name] = true
+				progressed = true
+				finish(TargetResult{Name: t.name, Status: TargetSkipped, Reason: "stopped after a failure (use --keep-going)"})
+				continue
+			}
+			rebuild, blocked, ready := s.depState(t, results)
+			if !ready {
+				continue
+			}
+			if blocked != "" {
+				started[t.name] = true
+				progressed = true
+				finish(TargetResult{Name: t.name, Status: TargetSkipped, Reason: "dependency " + blocked + " was not built"})
+				continue
+			}
+			if !s.opts.Force && !rebuild && !t.stale() {
+				started[t.name] = true
+				progressed = true
+				finish(TargetResult{Name: t.name, Status: TargetUpToDate})
+				continue
+			}
+			if running >= jobs {
+				continue
+			}
+			started[t.name] = true
+			progressed = true
+			running++
+			if s.opts.OnStart != nil {
+				s.opts.OnStart(t.name)
+			}
+			go func(t buildTarget) {
+				done <- s.buildOne(ctx, t, capture)
+			}(t)
+		}
+
+		if running == 0 {
+			if !progressed {
+				return nil, fmt.Errorf("cannot schedule %s builds: missing dependencies", s.kind)
+			}
+			continue // Resolved without building; dependents may be ready now
+		}
+		res := <-done
+		running--
+		finish(res)
+		if res.Status == TargetFailed && !s.opts.KeepGoing {
+			stopped = true
+		}
+	}
+
+	report := &BuildReport{Results: make([]TargetResult, 0, len(targets))}
+	for _, t := range targets {
+		report.Results = append(report.Results, results[t.name])
+	}
+	return report, nil
+}
+
+// depState reports whether all dependencies of a target have finished,
+// whether any was rebuilt, and the first one that did not build.
+func (s *scheduler) depState(t buildTarget, results map[string]TargetResult) (rebuilt bool, blocked string, ready bool) {
+	for _, dep := range t.deps {
+		res, ok := results[dep]
+		if !ok {
+			return false, "", false
+		}
+		switch res.Status {
+		case TargetBuilt:
+			rebuilt = true
+		case TargetFailed, TargetSkipped:
+			if blocked == "" {
+				blocked = dep
+			}
+		}
+	}
+	return rebuilt, blocked, true
+}
+
+// buildOne builds a single target, capturing its output to a log when asked.
+func (s *scheduler) buildOne(ctx context.Context, t buildTarget, capture bool) TargetResult {
+	res := TargetResult{Name: t.name}
+	start := time.Now()
+
+	var err error
+	if capture {
+		var out bytes.Buffer
+		err = t.build(ctx, &out)
+		res.Log = s.writeLog(t.name, out.Bytes())
+		if err != nil {
+			res.Errors = SummarizeBuildLog(res.Log, ParseBuildLog(bytes.NewReader(out.Bytes()))).Errors
+		}
+	} else {
+		err = t.build(ctx, nil)
+	}
+
+	res.Seconds = time.Since(start).Round(10 * time.Millisecond).Seconds()
+	res.Status = TargetBuilt
+	if err != nil {
+		res.Status = TargetFailed
+		res.Reason = err.Error()
+	}
+	return res
+}
+
+// writeLog stores the output of a captured build and returns its path,
+// or "" when it cannot be written.
+func (s *scheduler) writeLog(name string, out []byte) string {
+	path := filepath.Join(s.logDir, fmt.Sprintf("%s-%s.log", s.kind, name))
+	if err := s.fs.MkdirAll(s.logDir, 0755); err != nil {
+		return ""
+	}
+	if err := s.fs.WriteFile(path, out, 0644); err != nil {
+		return ""
+	}
+	return path
+}
+
+// checkCycles returns an error when the targets' dependencies form a cycle.
+func checkCycles(targets []buildTarget) error {
+	deps := make(map[string][]string, len(targets))
+	for _, t := range targets {
+		deps[t.name] = t.deps
+	}
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(targets))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range deps[name] {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	for _, t := range targets {
+		if err := visit(t.name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withDeps returns the named targets plus everything they depend on, in the
+// original order, so that building one module also builds what it needs.
+func withDeps(all []buildTarget, names ...string) []buildTarget {
+	byName := make(map[string]buildTarget, len(all))
+	for _, t := range all {
+		byName[t.name] = t
+	}
+	want := make(map[string]bool)
+	var add func(name string)
+	add = func(name string) {
+		if want[name] {
+			return
+		}
+		want[name] = true
+		for _, dep := range byName[name].deps {
+			add(dep)
+		}
+	}
+	for _, name := range names {
+		add(name)
+	}
+
+	var targets []buildTarget
+	for _, t := range all {
+		if want[t.name] {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// SourcesModTime returns the newest modification time of the source files
+// (see IsSourceFile) under dir, skipping hidden directories.
+func SourcesModTime(fs filesystem.FileSystem, dir string) time.Time {
+	var latest time.Time
+	entries, err := fs.ReadDir(dir)
+	if err != nil {
+		return latest
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			if strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			if t := SourcesModTime(fs, filepath.Join(dir, e.Name())); t.After(latest) {
+				latest = t
+			}
+			continue
+		}
+		if !IsSourceFile(e.Name()) {
+			continue
+		}
+		if info, err := e.Info(); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// olderThan reports whether output is missing or older than any of the given times.
+func olderThan(fs filesystem.FileSystem, output string, inputs ...time.Time) bool {
+	info, err := fs.Stat(output)
+	if err != nil {
+		return true
+	}
+	for _, t := range inputs {
+		if t.After(info.ModTime()) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildStamp formats the settings an output is built with, as key=value
+// lines in the order given.
+func buildStamp(kv ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(kv); i += 2 {
+		fmt.Fprintf(&b, "%s=%s\n", kv[i], kv[i+1])
+	}
+	return b.String()
+}
+
+// stampPath returns the file that records the build stamp of an output.
+func stampPath(output string) string {
+	return filepath.Join(filepath.Dir(output), "."+filepath.Base(output)+".stamp")
+}
+
+// stampChanged reports whether output was last built with a different stamp,
+// e.g. for another architecture, or has no stamp.
+func stampChanged(fs filesystem.FileSystem, output, stamp string) bool {
+	data, err := fs.ReadFile(stampPath(output))
+	return err != nil || string(data) != stamp
+}
+
+// writeStamp records the stamp output was built with. A stamp that cannot be
+// written only costs a rebuild next time.
+func writeStamp(fs filesystem.FileSystem, output, stamp string) {
+	_ = fs.WriteFile(stampPath(output), []byte(stamp), 0644)
+}
+
+// runTo runs a command in dir, sending its output to w, or to the terminal when w is nil.
+func runTo(ctx context.Context, exec executor.Executor, env []string, dir string, w io.Writer, cmd string, args ...string) error {
+	if w == nil {
+		return exec.RunWithEnvInDir(ctx, env, dir, cmd, args...)
+	}
+	return exec.RunWithEnvInDirTo(ctx, env, dir, w, cmd, args...)
+}
@@ -0,0 +1,29 @@
+package builder
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/filesystem"
+)
+
+func TestStampChanged(t *testing.T) {
+	fs := filesystem.NewOSFileSystem()
+	out := filepath.Join(t.TempDir(), "hello.ko")
+	arm64 := buildStamp("arch", "arm64", "kernel_release", "6.12.0", "cross_compile", "aarch64-linux-gnu-")
+	riscv := buildStamp("arch", "riscv", "kernel_release", "6.12.0", "cross_compile", "riscv64-linux-gnu-")
+
+	if !stampChanged(fs, out, arm64) {
+		t.Error("stampChanged() = false for an output without a stamp, want true")
+	}
+	writeStamp(fs, out, arm64)
+	if stampChanged(fs, out, arm64) {
+		t.Error("stampChanged() = true for the same stamp, want false")
+	}
+	if !stampChanged(fs, out, riscv) {
+		t.Error("stampChanged() = false after 'arch set riscv', want true")
+	}
+	if got, want := stampPath(out), filepath.Join(filepath.Dir(out), ".hello.ko.stamp"); got != want {
+		t.Errorf("stampPath() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,379 @@
+// Package builder provides kernel and module build orchestration for elmos.
+// This file contains the source watcher that rebuilds modules and apps on change.
+package builder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	elconfig "github.com/NguyenTrongPhuc552003/elmos/core/config"
+	elcontext "github.com/NguyenTrongPhuc552003/elmos/core/context"
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/filesystem"
+)
+
+// Watch target kinds.
+const (
+	WatchModule = "module"
+	WatchApp    = "app"
+)
+
+// Watcher settings.
+const (
+	watchStatusFile = "watch.json"
+	// watchDebounce is how long the sources must stay quiet before a rebuild,
+	// so that editors saving several files trigger a single build.
+	watchDebounce = 300 * time.Millisecond
+)
+
+// WatchTarget is a module or app that the watcher rebuilds.
+type WatchTarget struct {
+	Kind string `json:"kind" yaml:"kind"` // WatchModule or WatchApp
+	Name string `json:"name" yaml:"name"`
+}
+
+// String returns the target as "kind/name".
+func (t WatchTarget) String() string {
+	return t.Kind + "/" + t.Name
+}
+
+// WatchResult is the outcome of one rebuild.
+type WatchResult struct {
+	WatchTarget `yaml:",inline"`
+	Success     bool      `json:"success" yaml:"success"`
+	Error       string    `json:"error,omitempty" yaml:"error,omitempty"`
+	Seconds     float64   `json:"seconds" yaml:"seconds"`
+	Finished    time.Time `json:"finished" yaml:"finished"`
+}
+
+// WatchStatus is the state of a running watcher, shared through the run
+// directory so that 'elmos watch status' and the TUI can show it.
+type WatchStatus struct {
+	PID      int           `json:"pid" yaml:"pid"`
+	Started  time.Time     `json:"started" yaml:"started"`
+	Building string        `json:"building,omitempty" yaml:"building,omitempty"` // Target being rebuilt, if any
+	Results  []WatchResult `json:"results" yaml:"results"`                       // Latest result per target
+}
+
+// WatchOptions controls a Watch. The hooks are called around each rebuild;
+// either may be nil.
+type WatchOptions struct {
+	// Targets limits the watch to these modules and apps. When empty, the
+	// whole modules and apps directories are watched and the watcher state
+	// is published for 'elmos watch status'.
+	Targets []WatchTarget
+
+	OnBuild  func(t WatchTarget)
+	OnResult func(r WatchResult)
+}
+
+// Watcher rebuilds modules and apps when their sources change.
+type Watcher struct {
+	fs      filesystem.FileSystem
+	cfg     *elconfig.Config
+	ctx     *elcontext.Context
+	modules *ModuleBuilder
+	apps    *AppBuilder
+	status  WatchStatus
+	publish bool // Write status to the run directory
+}
+
+// NewWatcher creates a new Watcher.
+func NewWatcher(fs filesystem.FileSystem, cfg *elconfig.Config, ctx *elcontext.Context, modules *ModuleBuilder, apps *AppBuilder) *Watcher {
+	return &Watcher{
+		fs:      fs,
+		cfg:     cfg,
+		ctx:     ctx,
+		modules: modules,
+		apps:    apps,
+	}
+}
+
+// StatusPath returns the file holding the state of the running watcher.
+func (w *Watcher) StatusPath() string {
+	return filepath.Join(w.ctx.GetRunDir(), watchStatusFile)
+}
+
+// Status returns the state of the running watcher, or nil when none is running.
+func (w *Watcher) Status() (*WatchStatus, error) {
+	data, err := w.fs.ReadFile(w.StatusPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read watch status: %w", err)
+	}
+	var status WatchStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("invalid watch status: %w", err)
+	}
+	// Left behind by a watcher that was killed
+	if syscall.Kill(status.PID, 0) == syscall.ESRCH {
+		return nil, nil
+	}
+	return &status, nil
+}
+
+// Watch watches the modules and apps directories, or only opts.Targets,
+// and rebuilds each module or app whose sources change, until ctx is
+// cancelled. Build failures are reported through the hooks and do not stop
+// the watch.
+func (w *Watcher) Watch(ctx context.Context, opts WatchOptions) error {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start file watcher: %w", err)
+	}
+	defer fw.Close()
+
+	if err := w.addRoots(fw, opts.Targets); err != nil {
+		return err
+	}
+	only := make(map[WatchTarget]bool, len(opts.Targets))
+	for _, t := range opts.Targets {
+		only[t] = true
+	}
+
+	// Status is only shared for the full watch, so that a single-target
+	// watch does not replace what 'elmos watch status' reports
+	w.status = WatchStatus{PID: os.Getpid(), Started: time.Now()}
+	w.publish = len(opts.Targets) == 0
+	if w.publish {
+		_ = w.saveStatus()
+		defer func() { _ = w.fs.Remove(w.StatusPath()) }()
+	}
+
+	pending := make(map[WatchTarget]bool)
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case ev, ok := <-fw.Events:
+			if !ok {
+				return nil
+			}
+			t, ok := w.handleEvent(fw, ev)
+			if !ok || (len(only) > 0 && !only[t]) {
+				continue
+			}
+			pending[t] = true
+			debounce = time.After(watchDebounce)
+
+		case err, ok := <-fw.Errors:
+			if !ok {
+				return nil
+			}
+			return fmt.Errorf("file watcher failed: %w", err)
+
+		case <-debounce:
+			debounce = nil
+			targets := make([]WatchTarget, 0, len(pending))
+			for t := range pending {
+				targets = append(targets, t)
+			}
+			pending = make(map[WatchTarget]bool)
+			sort.Slice(targets, func(i, j int) bool { return targets[i].String() < targets[j].String() })
+			for _, t := range targets {
+				if ctx.Err() != nil {
+					return nil
+				}
+				w.rebuild(ctx, t, opts)
+			}
+		}
+	}
+}
+
+// addRoots watches the directories of the given targets, or the modules and
+// apps directories when there are none.
+func (w *Watcher) addRoots(fw *fsnotify.Watcher, targets []WatchTarget) error {
+	if len(targets) > 0 {
+		for _, t := range targets {
+			dir := filepath.Join(w.cfg.Paths.ModulesDir, t.Name)
+			if t.Kind == WatchApp {
+				dir = filepath.Join(w.cfg.Paths.AppsDir, t.Name)
+			}
+			if !w.fs.IsDir(dir) {
+				return fmt.Errorf("nothing to watch: %s does not exist", dir)
+			}
+			if err := w.addTree(fw, dir); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	roots := 0
+	for _, root := range []string{w.cfg.Paths.ModulesDir, w.cfg.Paths.AppsDir} {
+		if !w.fs.IsDir(root) {
+			continue
+		}
+		if err := w.addTree(fw, root); err != nil {
+			return err
+		}
+		roots++
+	}
+	if roots == 0 {
+		return fmt.Errorf("nothing to watch: neither %s nor %s exists", w.cfg.Paths.ModulesDir, w.cfg.Paths.AppsDir)
+	}
+	return nil
+}
+
+// handleEvent starts watching new directories and returns the target whose
+// sources an event touched.
+func (w *Watcher) handleEvent(fw *fsnotify.Watcher, ev fsnotify.Event) (WatchTarget, bool) {
+	t, ok := w.targetFor(ev.Name)
+	if !ok {
+		return t, false
+	}
+	if ev.Has(fsnotify.Create) && w.fs.IsDir(ev.Name) {
+		// A new module or app, or a new subdirectory; files written before
+		// the watch was added would be missed, so rebuild right away
+		_ = w.addTree(fw, ev.Name)
+		return t, true
+	}
+	if ev.Has(fsnotify.Chmod) || !IsSourceFile(filepath.Base(ev.Name)) {
+		return t, false
+	}
+	return t, true
+}
+
+// targetFor maps a path below the modules or apps directory to its module or app.
+func (w *Watcher) targetFor(path string) (WatchTarget, bool) {
+	for _, root := range []struct{ dir, kind string }{
+		{w.cfg.Paths.ModulesDir, WatchModule},
+		{w.cfg.Paths.AppsDir, WatchApp},
+	} {
+		rel, err := filepath.Rel(root.dir, path)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		name := strings.SplitN(filepath.ToSlash(rel), "/", 2)[0]
+		if strings.HasPrefix(name, ".") {
+			return WatchTarget{}, false
+		}
+		// Files directly in the root, such as guesync.sh, belong to no target
+		if name == rel && !w.fs.IsDir(path) {
+			return WatchTarget{}, false
+		}
+		return WatchTarget{Kind: root.kind, Name: name}, true
+	}
+	return WatchTarget{}, false
+}
+
+// addTree watches a directory and its subdirectories, skipping hidden ones.
+func (w *Watcher) addTree(fw *fsnotify.Watcher, dir string) error {
+	if err := fw.Add(dir); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	entries, err := w.fs.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			if err := w.addTree(fw, filepath.Join(dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rebuild builds one target, records the result and calls the hooks.
+func (w *Watcher) rebuild(ctx context.Context, t WatchTarget, opts WatchOptions) {
+	var err error
+	if t.Kind == WatchModule {
+		_, err = w.modules.GetModules(t.Name)
+	} else {
+		_, err = w.apps.GetApps(t.Name)
+	}
+	if err != nil {
+		return // Deleted since the change
+	}
+
+	if opts.OnBuild != nil {
+		opts.OnBuild(t)
+	}
+	w.status.Building = t.String()
+	_ = w.saveStatus()
+
+	start := time.Now()
+	if t.Kind == WatchModule {
+		err = w.modules.Build(ctx, t.Name)
+	} else {
+		err = w.apps.Build(ctx, t.Name)
+	}
+	res := WatchResult{
+		WatchTarget: t,
+		Success:     err == nil,
+		Seconds:     time.Since(start).Round(10 * time.Millisecond).Seconds(),
+		Finished:    time.Now(),
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	w.status.Building = ""
+	w.recordResult(res)
+	_ = w.saveStatus()
+	if opts.OnResult != nil {
+		opts.OnResult(res)
+	}
+}
+
+// recordResult replaces the previous result of the same target.
+func (w *Watcher) recordResult(res WatchResult) {
+	for i := range w.status.Results {
+		if w.status.Results[i].WatchTarget == res.WatchTarget {
+			w.status.Results[i] = res
+			return
+		}
+	}
+	w.status.Results = append(w.status.Results, res)
+	sort.Slice(w.status.Results, func(i, j int) bool {
+		return w.status.Results[i].String() < w.status.Results[j].String()
+	})
+}
+
+// saveStatus writes the watcher state to the run directory.
+func (w *Watcher) saveStatus() error {
+	if !w.publish {
+		return nil
+	}
+	data, err := json.MarshalIndent(w.status, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := w.StatusPath()
+	if err := w.fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return w.fs.WriteFile(path, data, 0644)
+}
+
+// IsSourceFile reports whether a file in a module or app directory is a
+// source that should trigger a rebuild. Build output, including the
+// generated *.mod.c, and hidden files such as editor swap files are not.
+func IsSourceFile(name string) bool {
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".mod.c") {
+		return false
+	}
+	switch name {
+	case "Makefile", "Kbuild", "Kconfig":
+		return true
+	}
+	switch filepath.Ext(name) {
+	case ".c", ".h", ".S", ".s", ".cc", ".cpp", ".mk":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+// Package cache provides compiler cache (ccache/sccache) management for elmos.
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	elconfig "github.com/NguyenTrongPhuc552003/elmos/core/config"
+	elcontext "github.com/NguyenTrongPhuc552003/elmos/core/context"
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/executor"
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/filesystem"
+)
+
+// Stats holds compiler cache statistics.
+type Stats struct {
+	Tool    string `json:"tool" yaml:"tool"`
+	Dir     string `json:"dir" yaml:"dir"`
+	Hits    int64  `json:"hits" yaml:"hits"`
+	Misses  int64  `json:"misses" yaml:"misses"`
+	Size    int64  `json:"size" yaml:"size"`                             // Bytes used
+	MaxSize string `json:"max_size,omitempty" yaml:"max_size,omitempty"` // Limit as reported by the tool, e.g. "5.0 GB"
+}
+
+// HitRate returns the percentage of cacheable compilations served from the cache.
+func (s *Stats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) * 100 / float64(total)
+}
+
+// Manager handles compiler cache operations.
+type Manager struct {
+	exec executor.Executor
+	fs   filesystem.FileSystem
+	cfg  *elconfig.Config
+	ctx  *elcontext.Context
+}
+
+// NewManager creates a new cache Manager.
+func NewManager(exec executor.Executor, fs filesystem.FileSystem, cfg *elconfig.Config, ctx *elcontext.Context) *Manager {
+	return &Manager{
+		exec: exec,
+		fs:   fs,
+		cfg:  cfg,
+		ctx:  ctx,
+	}
+}
+
+// Tool returns the compiler cache in use, or "" when caching is disabled.
+func (m *Manager) Tool() string {
+	return m.ctx.CompilerCache()
+}
+
+// requireTool returns the cache tool or an error explaining why none is in use.
+func (m *Manager) requireTool() (string, error) {
+	tool := m.Tool()
+	if tool != "" {
+		return tool, nil
+	}
+	if m.cfg.Cache.Tool == elconfig.CacheToolNone {
+		return "", fmt.Errorf("compiler cache disabled (cache.tool is none)")
+	}
+	switch m.cfg.Cache.Tool {
+	case elconfig.CacheToolCcache, elconfig.CacheToolSccache:
+		return "", fmt.Errorf("compiler cache not found: %s is not installed", m.cfg.Cache.Tool)
+	}
+	return "", fmt.Errorf("no compiler cache found: install ccache or sccache")
+}
+
+// Stats returns hit/miss counts and size of the cache.
+func (m *Manager) Stats(ctx context.Context) (*Stats, error) {
+	tool, err := m.requireTool()
+	if err != nil {
+		return nil, err
+	}
+	env := m.ctx.GetCacheEnv()
+	stats := &Stats{Tool: tool, Dir: m.ctx.GetCacheDir()}
+
+	if tool == elconfig.CacheToolSccache {
+		out, err := m.exec.OutputWithEnv(ctx, env, "sccache", "--show-stats", "--stats-format=json")
+		if err != nil {
+			return nil, fmt.Errorf("sccache --show-stats failed: %w", err)
+		}
+		if err := parseSccacheStats(out, stats); err != nil {
+			return nil, err
+		}
+		return stats, nil
+	}
+
+	out, err := m.exec.OutputWithEnv(ctx, env, "ccache", "--print-stats")
+	if err != nil {
+		return nil, fmt.Errorf("ccache --print-stats failed: %w", err)
+	}
+	parseCcacheStats(out, stats)
+	if max, err := m.exec.OutputWithEnv(ctx, env, "ccache", "--get-config", "max_size"); err == nil {
+		stats.MaxSize = strings.TrimSpace(string(max))
+	}
+	return stats, nil
+}
+
+// parseCcacheStats reads the tab-separated output of 'ccache --print-stats'.
+// Both the ccache 3.x and 4.x counter names are recognised.
+func parseCcacheStats(out []byte, stats *Stats) {
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		n, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "direct_cache_hit", "preprocessed_cache_hit", "cache_hit_direct", "cache_hit_preprocessed":
+			stats.Hits += n
+		case "cache_miss":
+			stats.Misses += n
+		case "cache_size_kibibyte":
+			stats.Size = n * 1024
+		}
+	}
+}
+
+// sccacheStats mirrors the fields used from 'sccache --show-stats --stats-format=json'.
+type sccacheStats struct {
+	Stats struct {
+		CacheHits struct {
+			Counts map[string]int64 `json:"counts"`
+		} `json:"cache_hits"`
+		CacheMisses struct {
+			Counts map[string]int64 `json:"counts"`
+		} `json:"cache_misses"`
+	} `json:"stats"`
+	CacheSize    *int64 `json:"cache_size"`
+	MaxCacheSize *int64 `json:"max_cache_size"`
+}
+
+// parseSccacheStats reads the JSON statistics printed by sccache.
+func parseSccacheStats(out []byte, stats *Stats) error {
+	var raw sccacheStats
+	if err := json.Unmarshal(out, &raw); err != nil {
+		return fmt.Errorf("failed to parse sccache stats: %w", err)
+	}
+	for _, n := range raw.Stats.CacheHits.Counts {
+		stats.Hits += n
+	}
+	for _, n := range raw.Stats.CacheMisses.Counts {
+		stats.Misses += n
+	}
+	if raw.CacheSize != nil {
+		stats.Size = *raw.CacheSize
+	}
+	if raw.MaxCacheSize != nil {
+		stats.MaxSize = FormatSize(*raw.MaxCacheSize)
+	}
+	return nil
+}
+
+// Clear empties the cache and resets its statistics.
+func (m *Manager) Clear(ctx context.Context) error {
+	tool, err := m.requireTool()
+	if err != nil {
+		return err
+	}
+	env := m.ctx.GetCacheEnv()
+
+	if tool == elconfig.CacheToolSccache {
+		// sccache has no clear command; stop the server and remove its storage
+		_ = m.exec.RunWithEnvSilent(ctx, env, "sccache", "--stop-server")
+		if err := m.fs.RemoveAll(m.ctx.GetCacheDir()); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", m.ctx.GetCacheDir(), err)
+		}
+		return nil
+	}
+
+	if err := m.exec.RunWithEnv(ctx, env, "ccache", "--clear", "--zero-stats"); err != nil {
+		return fmt.Errorf("ccache --clear failed: %w", err)
+	}
+	return nil
+}
+
+// SetLimit changes the maximum cache size and stores it in cache.size.
+// The caller is responsible for saving the configuration.
+func (m *Manager) SetLimit(ctx context.Context, size string) error {
+	if err := elconfig.ValidateValue("cache.size", size); err != nil {
+		return err
+	}
+	tool, err := m.requireTool()
+	if err != nil {
+		return err
+	}
+	m.cfg.Cache.Size = size
+	env := m.ctx.GetCacheEnv()
+
+	if tool == elconfig.CacheToolSccache {
+		// The limit is read when the server starts
+		_ = m.exec.RunWithEnvSilent(ctx, env, "sccache", "--stop-server")
+		return nil
+	}
+
+	// Apply now so an oversized cache is trimmed immediately
+	if err := m.exec.RunWithEnv(ctx, env, "ccache", "--max-size", size, "--cleanup"); err != nil {
+		return fmt.Errorf("ccache --max-size failed: %w", err)
+	}
+	return nil
+}
+
+// FormatSize renders a byte count with a binary unit suffix.
+func FormatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -16,10 +16,10 @@ import (
 
 // CheckResult represents the result of a single check.
 type CheckResult struct {
-	Name     string
-	Passed   bool
-	Required bool
-	Message  string
+	Name     string `json:"name" yaml:"name"`
+	Passed   bool   `json:"passed" yaml:"passed"`
+	Required bool   `json:"required" yaml:"required"`
+	Message  string `json:"message,omitempty" yaml:"message,omitempty"`
 }
 
 // HealthChecker validates the development environment.
@@ -258,11 +258,13 @@ func (h *HealthChecker) CheckHeaders(ctx context.Context) []CheckResult {
 func (h *HealthChecker) CheckCrossGDB(ctx context.Context) []CheckResult {
 	var results []CheckResult
 
+	seen := make(map[string]bool) // Variants share their base's toolchain
 	for _, arch := range elconfig.SupportedArchitectures() {
 		archCfg := elconfig.GetArchConfig(arch)
-		if archCfg == nil || archCfg.GDBBinary == "" {
+		if archCfg == nil || archCfg.GDBBinary == "" || seen[archCfg.GDBBinary] {
 			continue
 		}
+		seen[archCfg.GDBBinary] = true
 
 		binary := archCfg.GDBBinary
 		passed := false
@@ -294,11 +296,13 @@ func (h *HealthChecker) CheckCrossGDB(ctx context.Context) []CheckResult {
 func (h *HealthChecker) CheckCrossGCC(ctx context.Context) []CheckResult {
 	var results []CheckResult
 
+	seen := make(map[string]bool) // Variants share their base's toolchain
 	for _, arch := range elconfig.SupportedArchitectures() {
 		archCfg := elconfig.GetArchConfig(arch)
-		if archCfg == nil || archCfg.GCCBinary == "" {
+		if archCfg == nil || archCfg.GCCBinary == "" || seen[archCfg.GCCBinary] {
 			continue
 		}
+		seen[archCfg.GCCBinary] = true
 
 		binary := archCfg.GCCBinary
 		passed := false
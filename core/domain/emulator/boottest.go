@@ -0,0 +1,202 @@
+// Package emulator provides QEMU emulation orchestration for elmos.
+// This file contains the headless boot test harness.
+package emulator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// defaultTestInstance is the instance name used by boot tests.
+const defaultTestInstance = "boot-test"
+
+// consolePollInterval is how often the serial console log is read during a test.
+const consolePollInterval = 200 * time.Millisecond
+
+// Test boots the kernel headless and watches the serial console for a verdict.
+// It returns an error only if the test could not be run; a failed boot is
+// reported through TestResult.
+func (q *QEMURunner) Test(ctx context.Context, opts TestOptions) (*TestResult, error) {
+	success, err := compilePatterns(opts.SuccessPatterns, DefaultTestSuccessPatterns)
+	if err != nil {
+		return nil, fmt.Errorf("invalid success pattern: %w", err)
+	}
+	failure, err := compilePatterns(opts.FailurePatterns, DefaultTestFailurePatterns)
+	if err != nil {
+		return nil, fmt.Errorf("invalid failure pattern: %w", err)
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTestTimeout
+	}
+
+	runOpts := RunOptions{Name: opts.Name}
+	if runOpts.Name == "" {
+		runOpts.Name = defaultTestInstance
+	}
+	archCfg, kernelImage, inst, err := q.prepareRun(ctx, runOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	logPath := opts.LogPath
+	if logPath == "" {
+		name := fmt.Sprintf("qemu-test-%s-%s.log", q.cfg.Build.Arch, time.Now().Format("20060102-150405"))
+		logPath = filepath.Join(q.ctx.GetLogDir(), name)
+	}
+	if err := q.fs.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	// Create the log up front so it can be tailed before QEMU writes to it
+	logFile, err := q.fs.Create(logPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create console log: %w", err)
+	}
+	defer logFile.Close()
+
+	var echo io.Writer = os.Stdout
+	if opts.Quiet {
+		echo = io.Discard
+	}
+	watcher := &consoleWatcher{success: success, failure: failure, echo: echo}
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	runOpts.ConsoleLog = logPath
+	args := q.buildArgs(archCfg, kernelImage, inst, runOpts)
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- q.exec.Run(runCtx, archCfg.QEMUBinary, args...)
+	}()
+
+	ticker := time.NewTicker(consolePollInterval)
+	defer ticker.Stop()
+
+	var runErr error
+	exited := false
+	for !exited {
+		select {
+		case runErr = <-done:
+			exited = true
+		case <-ticker.C:
+			watcher.consume(logFile)
+			if watcher.verdict != "" {
+				cancel()
+				runErr = <-done
+				exited = true
+			}
+		case <-runCtx.Done():
+			runErr = <-done
+			exited = true
+		}
+	}
+	watcher.consume(logFile)
+	watcher.flush()
+
+	result := &TestResult{Duration: time.Since(start), LogPath: logPath, Matched: watcher.matched}
+	switch {
+	case watcher.verdict == verdictFail:
+		result.Reason = "failure pattern matched"
+	case watcher.verdict == verdictPass:
+		result.Passed = true
+		result.Reason = "success pattern matched"
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		result.Reason = fmt.Sprintf("timed out after %s", timeout)
+	case runErr != nil:
+		result.Reason = fmt.Sprintf("QEMU exited before a verdict: %v", runErr)
+	default:
+		result.Reason = "QEMU exited before a verdict"
+	}
+	return result, nil
+}
+
+// Console verdicts.
+const (
+	verdictPass = "pass"
+	verdictFail = "fail"
+)
+
+// consoleWatcher scans serial console output line by line for verdict patterns.
+type consoleWatcher struct {
+	success []*regexp.Regexp
+	failure []*regexp.Regexp
+	echo    io.Writer
+	partial []byte
+	verdict string
+	matched string
+}
+
+// consume reads newly written console output and checks complete lines.
+func (w *consoleWatcher) consume(r io.Reader) {
+	data, _ := io.ReadAll(r)
+	if len(data) == 0 {
+		return
+	}
+	_, _ = w.echo.Write(data)
+
+	w.partial = append(w.partial, data...)
+	for {
+		idx := bytes.IndexByte(w.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		w.check(string(w.partial[:idx]))
+		w.partial = w.partial[idx+1:]
+	}
+}
+
+// flush checks any trailing output without a newline.
+func (w *consoleWatcher) flush() {
+	if len(w.partial) > 0 {
+		w.check(string(w.partial))
+		w.partial = nil
+	}
+}
+
+// check matches a single line; the first verdict wins and failures take precedence within a line.
+func (w *consoleWatcher) check(line string) {
+	if w.verdict != "" {
+		return
+	}
+	line = strings.TrimRight(line, "\r")
+	for _, re := range w.failure {
+		if re.MatchString(line) {
+			w.verdict, w.matched = verdictFail, line
+			return
+		}
+	}
+	for _, re := range w.success {
+		if re.MatchString(line) {
+			w.verdict, w.matched = verdictPass, line
+			return
+		}
+	}
+}
+
+// compilePatterns compiles patterns, falling back to defaults when none are given.
+func compilePatterns(patterns, defaults []string) ([]*regexp.Regexp, error) {
+	if len(patterns) == 0 {
+		patterns = defaults
+	}
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
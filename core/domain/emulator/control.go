@@ -0,0 +1,133 @@
+// Package emulator provides QEMU emulation orchestration for elmos.
+// This file contains control of running instances over QMP.
+package emulator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/qmp"
+)
+
+// InstanceStatus describes a running QEMU instance.
+type InstanceStatus struct {
+	Status  string // QEMU run state, e.g. "running" or "paused"
+	Running bool
+	Version string
+	Socket  string
+}
+
+// withClient runs fn with a QMP client connected to a named instance.
+func (q *QEMURunner) withClient(ctx context.Context, name string, fn func(c *qmp.Client) error) error {
+	name, err := resolveInstanceName(name)
+	if err != nil {
+		return err
+	}
+	c, err := q.dialInstance(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return fn(c)
+}
+
+// Status returns the state of a running instance.
+func (q *QEMURunner) Status(ctx context.Context, name string) (*InstanceStatus, error) {
+	var status *InstanceStatus
+	err := q.withClient(ctx, name, func(c *qmp.Client) error {
+		s, err := c.QueryStatus(ctx)
+		if err != nil {
+			return err
+		}
+		status = &InstanceStatus{
+			Status:  s.Status,
+			Running: s.Running,
+			Version: c.Version().String(),
+			Socket:  q.QMPSocketPath(name),
+		}
+		return nil
+	})
+	return status, err
+}
+
+// Stop shuts the instance down. Graceful asks the guest to power off
+// instead of terminating QEMU immediately.
+func (q *QEMURunner) Stop(ctx context.Context, name string, graceful bool) error {
+	return q.withClient(ctx, name, func(c *qmp.Client) error {
+		if graceful {
+			return c.SystemPowerdown(ctx)
+		}
+		return c.Quit(ctx)
+	})
+}
+
+// Reset performs a hardware reset of the guest.
+func (q *QEMURunner) Reset(ctx context.Context, name string) error {
+	return q.withClient(ctx, name, func(c *qmp.Client) error {
+		return c.SystemReset(ctx)
+	})
+}
+
+// Pause pauses guest execution.
+func (q *QEMURunner) Pause(ctx context.Context, name string) error {
+	return q.withClient(ctx, name, func(c *qmp.Client) error {
+		return c.Stop(ctx)
+	})
+}
+
+// Resume resumes guest execution.
+func (q *QEMURunner) Resume(ctx context.Context, name string) error {
+	return q.withClient(ctx, name, func(c *qmp.Client) error {
+		return c.Cont(ctx)
+	})
+}
+
+// SaveSnapshot saves the VM state under name. The disk must support snapshots (qcow2).
+func (q *QEMURunner) SaveSnapshot(ctx context.Context, instance, name string) error {
+	return q.snapshotCommand(ctx, instance, "savevm "+name)
+}
+
+// LoadSnapshot restores the VM state saved under name.
+func (q *QEMURunner) LoadSnapshot(ctx context.Context, instance, name string) error {
+	return q.snapshotCommand(ctx, instance, "loadvm "+name)
+}
+
+// DeleteSnapshot deletes the VM state saved under name.
+func (q *QEMURunner) DeleteSnapshot(ctx context.Context, instance, name string) error {
+	return q.snapshotCommand(ctx, instance, "delvm "+name)
+}
+
+// ListSnapshots returns the "info snapshots" table of a running instance.
+func (q *QEMURunner) ListSnapshots(ctx context.Context, instance string) (string, error) {
+	var out string
+	err := q.withClient(ctx, instance, func(c *qmp.Client) error {
+		var err error
+		out, err = c.HumanMonitorCommand(ctx, "info snapshots")
+		return err
+	})
+	return strings.TrimSpace(out), err
+}
+
+// snapshotTimeout bounds savevm/loadvm, which copy all guest RAM.
+const snapshotTimeout = 2 * time.Minute
+
+// snapshotCommand runs an HMP snapshot command; these print nothing on success.
+func (q *QEMURunner) snapshotCommand(ctx context.Context, instance, cmdline string) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, snapshotTimeout)
+		defer cancel()
+	}
+	return q.withClient(ctx, instance, func(c *qmp.Client) error {
+		out, err := c.HumanMonitorCommand(ctx, cmdline)
+		if err != nil {
+			return err
+		}
+		if out = strings.TrimSpace(out); out != "" {
+			return fmt.Errorf("%s", out)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,175 @@
+// Package emulator provides QEMU emulation orchestration for elmos.
+// This file contains running commands inside a guest and module hot-reload.
+package emulator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GuestModulesDir is where the host modules directory is mounted in the guest.
+const GuestModulesDir = "/mnt/modules"
+
+// Guest transports for GuestExec.
+const (
+	GuestViaAuto  = "auto"  // SSH when it answers, otherwise the agent
+	GuestViaSSH   = "ssh"   // ssh root@localhost on the instance's forwarded port
+	GuestViaAgent = "agent" // Script queue on the 9p share, served by guesync.sh
+)
+
+// Guest agent settings. The agent started by guesync.sh polls
+// <modules>/.elmos-agent/<instance>/ for "<id>.req" files, runs "<id>.sh"
+// and writes its output to "<id>.out" and exit status to "<id>.rc".
+const (
+	agentDir        = ".elmos-agent"
+	agentHeartbeat  = "heartbeat"
+	agentStaleAfter = 5 * time.Second
+	agentTimeout    = 60 * time.Second
+)
+
+// sshArgs returns the ssh options for connecting to an instance as root.
+func sshArgs(inst *Instance) []string {
+	return []string{
+		"-p", strconv.Itoa(inst.SSHPort),
+		"-o", "BatchMode=yes",
+		"-o", "StrictHostKeyChecking=no",
+		"-o", "UserKnownHostsFile=/dev/null",
+		"-o", "LogLevel=ERROR",
+		"-o", "ConnectTimeout=3",
+		"root@localhost",
+	}
+}
+
+// agentPath returns the host side of an instance's agent queue.
+func (q *QEMURunner) agentPath(inst *Instance) string {
+	return filepath.Join(q.cfg.Paths.ModulesDir, agentDir, inst.Name)
+}
+
+// sshReachable reports whether the guest accepts non-interactive SSH logins.
+func (q *QEMURunner) sshReachable(ctx context.Context, inst *Instance) bool {
+	_, err := q.exec.Output(ctx, "ssh", append(sshArgs(inst), "true")...)
+	return err == nil
+}
+
+// agentAlive reports whether the guest agent has recently written its heartbeat.
+func (q *QEMURunner) agentAlive(inst *Instance) bool {
+	info, err := q.fs.Stat(filepath.Join(q.agentPath(inst), agentHeartbeat))
+	return err == nil && time.Since(info.ModTime()) < agentStaleAfter
+}
+
+// GuestExec runs a shell script inside a running instance, streaming its
+// output to the terminal. via selects the transport (GuestViaAuto, GuestViaSSH
+// or GuestViaAgent).
+func (q *QEMURunner) GuestExec(ctx context.Context, name, via, script string) error {
+	inst, err := q.GetInstance(name)
+	if err != nil {
+		return err
+	}
+	if !inst.Running {
+		return fmt.Errorf("instance %s is not running (start it with 'elmos qemu run')", inst.Name)
+	}
+
+	switch via {
+	case GuestViaSSH:
+		return q.sshExec(ctx, inst, script)
+	case GuestViaAgent:
+		return q.agentExec(ctx, inst, script)
+	case GuestViaAuto, "":
+		if q.sshReachable(ctx, inst) {
+			return q.sshExec(ctx, inst, script)
+		}
+		if q.agentAlive(inst) {
+			return q.agentExec(ctx, inst, script)
+		}
+		return fmt.Errorf("cannot reach instance %s: no SSH login on port %d and no elmos agent (restart the guest so guesync.sh starts it)", inst.Name, inst.SSHPort)
+	default:
+		return fmt.Errorf("invalid guest transport %q (valid: %s, %s, %s)", via, GuestViaAuto, GuestViaSSH, GuestViaAgent)
+	}
+}
+
+// sshExec runs a script over SSH.
+func (q *QEMURunner) sshExec(ctx context.Context, inst *Instance, script string) error {
+	if err := q.exec.Run(ctx, "ssh", append(sshArgs(inst), script)...); err != nil {
+		return fmt.Errorf("guest command failed: %w", err)
+	}
+	return nil
+}
+
+// agentExec queues a script for the guest agent and streams its output until it exits.
+func (q *QEMURunner) agentExec(ctx context.Context, inst *Instance, script string) error {
+	if !q.agentAlive(inst) {
+		return fmt.Errorf("elmos agent in instance %s is not running", inst.Name)
+	}
+	dir := q.agentPath(inst)
+	id := filepath.Join(dir, strconv.FormatInt(time.Now().UnixNano(), 36))
+	defer func() {
+		for _, ext := range []string{".req", ".sh", ".out", ".rc"} {
+			_ = q.fs.Remove(id + ext)
+		}
+	}()
+
+	// The request file is written last so the agent never sees a partial script
+	if err := q.fs.WriteFile(id+".sh", []byte(script), 0644); err != nil {
+		return fmt.Errorf("failed to queue guest command: %w", err)
+	}
+	if err := q.fs.WriteFile(id+".req", nil, 0644); err != nil {
+		return fmt.Errorf("failed to queue guest command: %w", err)
+	}
+
+	printed := 0
+	flush := func() {
+		if out, err := q.fs.ReadFile(id + ".out"); err == nil && len(out) > printed {
+			_, _ = os.Stdout.Write(out[printed:])
+			printed = len(out)
+		}
+	}
+	deadline := time.Now().Add(agentTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+		flush()
+		rc, err := q.fs.ReadFile(id + ".rc")
+		if err != nil || len(rc) == 0 {
+			continue
+		}
+		flush()
+		if code := strings.TrimSpace(string(rc)); code != "0" {
+			return fmt.Errorf("guest command failed: exit status %s", code)
+		}
+		return nil
+	}
+	return fmt.Errorf("timed out waiting for the elmos agent in instance %s", inst.Name)
+}
+
+// ReloadModule unloads a module in a running instance, loads the freshly
+// built .ko from the 9p share and prints the kernel messages this produced.
+// koPath is the module file on the host, inside the modules directory.
+func (q *QEMURunner) ReloadModule(ctx context.Context, name, via, koPath string) error {
+	rel, err := filepath.Rel(q.cfg.Paths.ModulesDir, koPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("%s is not in the modules directory %s", koPath, q.cfg.Paths.ModulesDir)
+	}
+	guestKo := GuestModulesDir + "/" + filepath.ToSlash(rel)
+	// /proc/modules lists names with underscores
+	mod := strings.ReplaceAll(strings.TrimSuffix(filepath.Base(koPath), ".ko"), "-", "_")
+
+	script := fmt.Sprintf(`before=$(dmesg | wc -l)
+if grep -q '^%[1]s ' /proc/modules 2>/dev/null; then
+    rmmod %[1]s || exit 1
+fi
+insmod %[2]s
+rc=$?
+sleep 1
+dmesg | tail -n +$((before + 1))
+exit $rc
+`, mod, guestKo)
+	return q.GuestExec(ctx, name, via, script)
+}
@@ -0,0 +1,289 @@
+// Package emulator provides QEMU emulation orchestration for elmos.
+// This file contains named instance state, port allocation and disk overlays.
+package emulator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/qmp"
+)
+
+// DefaultInstanceName is used when no --name is given.
+const DefaultInstanceName = "default"
+
+// portSearchRange is how many ports above the configured one are tried.
+const portSearchRange = 100
+
+// instanceNamePattern restricts names to safe directory names.
+var instanceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Instance is the persisted state of a named QEMU instance.
+// It lives in <workspace>/run/<name>/state.json next to the pidfile,
+// QMP socket and disk overlay.
+type Instance struct {
+	Name      string    `json:"name"`
+	Arch      string    `json:"arch"`
+	SSHPort   int       `json:"ssh_port"`
+	GDBPort   int       `json:"gdb_port,omitempty"`
+	Overlay   string    `json:"overlay"`
+	Started   time.Time `json:"started"`
+	PID       int       `json:"-"` // Read from the pidfile
+	Running   bool      `json:"-"`
+	Directory string    `json:"-"`
+}
+
+// QMPSocket returns the instance's QMP socket path.
+func (i *Instance) QMPSocket() string {
+	return filepath.Join(i.Directory, "qmp.sock")
+}
+
+// PIDFile returns the pidfile written by QEMU.
+func (i *Instance) PIDFile() string {
+	return filepath.Join(i.Directory, "qemu.pid")
+}
+
+// statePath returns the instance state file.
+func (i *Instance) statePath() string {
+	return filepath.Join(i.Directory, "state.json")
+}
+
+// instanceDir returns the state directory for a named instance.
+func (q *QEMURunner) instanceDir(name string) string {
+	return filepath.Join(q.ctx.GetRunDir(), name)
+}
+
+// resolveInstanceName applies the default name and validates it.
+func resolveInstanceName(name string) (string, error) {
+	if name == "" {
+		return DefaultInstanceName, nil
+	}
+	if !instanceNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid instance name: %q (use letters, digits, '.', '_' and '-')", name)
+	}
+	return name, nil
+}
+
+// QMPSocketPath returns the QMP socket of a named instance.
+func (q *QEMURunner) QMPSocketPath(name string) string {
+	if name == "" {
+		name = DefaultInstanceName
+	}
+	return filepath.Join(q.instanceDir(name), "qmp.sock")
+}
+
+// GetInstance loads the state of a named instance and checks whether it is running.
+func (q *QEMURunner) GetInstance(name string) (*Instance, error) {
+	name, err := resolveInstanceName(name)
+	if err != nil {
+		return nil, err
+	}
+	inst := &Instance{Name: name, Directory: q.instanceDir(name)}
+	data, err := q.fs.ReadFile(inst.statePath())
+	if err != nil {
+		return nil, fmt.Errorf("instance not found: %s", name)
+	}
+	if err := json.Unmarshal(data, inst); err != nil {
+		return nil, fmt.Errorf("invalid state for instance %s: %w", name, err)
+	}
+	inst.Name = name
+	inst.PID = q.readPID(inst.PIDFile())
+	inst.Running = inst.PID > 0 && processAlive(inst.PID)
+	return inst, nil
+}
+
+// ListInstances returns all known instances sorted by name.
+func (q *QEMURunner) ListInstances() ([]*Instance, error) {
+	entries, err := q.fs.ReadDir(q.ctx.GetRunDir())
+	if err != nil {
+		return nil, nil // No instances have been started yet
+	}
+	var instances []*Instance
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if inst, err := q.GetInstance(e.Name()); err == nil {
+			instances = append(instances, inst)
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].Name < instances[j].Name })
+	return instances, nil
+}
+
+// Kill stops a named instance via QMP, falling back to SIGTERM.
+// Remove also deletes its state directory and disk overlay.
+func (q *QEMURunner) Kill(ctx context.Context, name string, remove bool) error {
+	inst, err := q.GetInstance(name)
+	if err != nil {
+		return err
+	}
+	if inst.Running {
+		if err := q.Stop(ctx, inst.Name, false); err != nil {
+			if serr := syscall.Kill(inst.PID, syscall.SIGTERM); serr != nil {
+				return fmt.Errorf("failed to stop instance %s: %w", inst.Name, serr)
+			}
+		}
+	}
+	if remove {
+		if err := q.fs.RemoveAll(inst.Directory); err != nil {
+			return fmt.Errorf("failed to remove instance %s: %w", inst.Name, err)
+		}
+	}
+	return nil
+}
+
+// prepareInstance creates the state directory for a new run of a named instance.
+// It allocates free SSH/GDB ports, refreshes the disk overlay and saves the state.
+func (q *QEMURunner) prepareInstance(ctx context.Context, name string, debug bool) (*Instance, error) {
+	name, err := resolveInstanceName(name)
+	if err != nil {
+		return nil, err
+	}
+	if existing, err := q.GetInstance(name); err == nil && existing.Running {
+		return nil, fmt.Errorf("instance %s is already running (pid %d, stop it with 'elmos qemu kill %s')",
+			name, existing.PID, name)
+	}
+
+	inst := &Instance{
+		Name:      name,
+		Arch:      q.cfg.Build.Arch,
+		Directory: q.instanceDir(name),
+		Started:   time.Now(),
+	}
+	if err := q.fs.MkdirAll(inst.Directory, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create instance directory: %w", err)
+	}
+	// Clear leftovers from a previous run
+	for _, stale := range []string{inst.QMPSocket(), inst.PIDFile()} {
+		if q.fs.Exists(stale) {
+			_ = q.fs.Remove(stale)
+		}
+	}
+
+	used := q.usedPorts()
+	if inst.SSHPort, err = findFreePort(q.cfg.QEMU.SSHPort, used); err != nil {
+		return nil, fmt.Errorf("no free SSH port: %w", err)
+	}
+	used[inst.SSHPort] = true
+	if debug {
+		if inst.GDBPort, err = findFreePort(q.cfg.QEMU.GDBPort, used); err != nil {
+			return nil, fmt.Errorf("no free GDB port: %w", err)
+		}
+	}
+
+	inst.Overlay = filepath.Join(inst.Directory, "disk.qcow2")
+	if err := q.ensureOverlay(ctx, inst.Overlay); err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(inst, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := q.fs.WriteFile(inst.statePath(), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to save instance state: %w", err)
+	}
+	return inst, nil
+}
+
+// ensureOverlay creates a qcow2 overlay backed by the rootfs disk image.
+// The overlay is kept between runs and recreated when the base image is newer.
+func (q *QEMURunner) ensureOverlay(ctx context.Context, overlay string) error {
+	base := q.cfg.Paths.DiskImage
+	if info, err := q.fs.Stat(overlay); err == nil {
+		baseInfo, err := q.fs.Stat(base)
+		if err == nil && !baseInfo.ModTime().After(info.ModTime()) {
+			return nil
+		}
+		if err := q.fs.Remove(overlay); err != nil {
+			return fmt.Errorf("failed to remove outdated overlay: %w", err)
+		}
+	}
+
+	if _, err := q.exec.LookPath("qemu-img"); err != nil {
+		return fmt.Errorf("qemu-img not found (install qemu)")
+	}
+	if err := q.exec.Run(ctx, "qemu-img", "create", "-q",
+		"-f", "qcow2",
+		"-F", "raw",
+		"-b", base,
+		overlay,
+	); err != nil {
+		return fmt.Errorf("failed to create disk overlay: %w", err)
+	}
+	return nil
+}
+
+// usedPorts returns the ports held by running instances.
+func (q *QEMURunner) usedPorts() map[int]bool {
+	used := make(map[int]bool)
+	instances, _ := q.ListInstances()
+	for _, inst := range instances {
+		if !inst.Running {
+			continue
+		}
+		used[inst.SSHPort] = true
+		if inst.GDBPort > 0 {
+			used[inst.GDBPort] = true
+		}
+	}
+	return used
+}
+
+// findFreePort returns the first port from start that is neither reserved nor bound on the host.
+func findFreePort(start int, reserved map[int]bool) (int, error) {
+	for port := start; port < start+portSearchRange && port <= 65535; port++ {
+		if reserved[port] {
+			continue
+		}
+		l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			continue
+		}
+		l.Close()
+		return port, nil
+	}
+	return 0, fmt.Errorf("ports %d-%d are all in use", start, start+portSearchRange-1)
+}
+
+// readPID reads a pidfile, returning 0 if it is missing or invalid.
+func (q *QEMURunner) readPID(path string) int {
+	data, err := q.fs.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// processAlive checks whether a process exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// dialInstance connects to the QMP socket of a named instance.
+func (q *QEMURunner) dialInstance(ctx context.Context, name string) (*qmp.Client, error) {
+	sock := q.QMPSocketPath(name)
+	if !q.fs.Exists(sock) {
+		return nil, fmt.Errorf("instance %s is not running (socket not found: %s)", name, sock)
+	}
+	c, err := qmp.Dial(ctx, sock)
+	if err != nil {
+		return nil, fmt.Errorf("instance %s is not running: %w", name, err)
+	}
+	return c, nil
+}
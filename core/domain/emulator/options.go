@@ -2,8 +2,50 @@
 // This file contains type definitions for the emulator package.
 package emulator
 
+import "time"
+
 // RunOptions contains options for running QEMU.
 type RunOptions struct {
-	Debug     bool // Enable GDB stub
-	Graphical bool // Use graphical display instead of serial console
+	Name       string // Instance name (defaults to "default")
+	Debug      bool   // Enable GDB stub
+	Graphical  bool   // Use graphical display instead of serial console
+	Snapshot   bool   // Discard all disk writes when QEMU exits
+	ConsoleLog string // Headless: write the serial console to this file instead of stdio
+
+	// OnStart is called with the prepared instance just before QEMU starts
+	OnStart func(inst *Instance)
+}
+
+// TestOptions contains options for a headless boot test.
+type TestOptions struct {
+	Name            string        // Instance name (defaults to "boot-test")
+	SuccessPatterns []string      // Regexes that mark the boot as passed
+	FailurePatterns []string      // Regexes that mark the boot as failed
+	Timeout         time.Duration // Maximum time to wait for a verdict
+	LogPath         string        // Console log path (defaults to <workspace>/logs)
+	Quiet           bool          // Do not echo the console to stdout
+}
+
+// TestResult contains the outcome of a headless boot test.
+type TestResult struct {
+	Passed   bool
+	Reason   string        // Why the test passed or failed
+	Matched  string        // Console line that decided the verdict, if any
+	Duration time.Duration // Time from QEMU start to verdict
+	LogPath  string        // Captured serial console
 }
+
+// DefaultTestSuccessPatterns matches the banner printed by the elmos init script.
+var DefaultTestSuccessPatterns = []string{`System ready\.`}
+
+// DefaultTestFailurePatterns matches common fatal boot errors.
+var DefaultTestFailurePatterns = []string{
+	`Kernel panic`,
+	`Unable to mount root fs`,
+	`Attempted to kill init`,
+	`Oops:`,
+	`BUG:`,
+}
+
+// DefaultTestTimeout is the boot test timeout when none is given.
+const DefaultTestTimeout = 120 * time.Second
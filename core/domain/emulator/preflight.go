@@ -0,0 +1,161 @@
+// Package emulator provides QEMU emulation orchestration for elmos.
+// This file contains kernel config checks run before booting.
+package emulator
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/NguyenTrongPhuc552003/elmos/core/infra/kconfig"
+)
+
+// BootRequirement is a kernel config symbol the QEMU command line depends on.
+type BootRequirement struct {
+	kconfig.Requirement
+	Reason   string // What breaks without it
+	Optional bool   // The guest still boots, but a feature is missing
+}
+
+// BootFailure is a boot requirement the kernel config does not meet.
+type BootFailure struct {
+	BootRequirement
+	Actual string
+}
+
+// consoleDrivers maps console device prefixes to the serial drivers providing them.
+var consoleDrivers = map[string][]string{
+	"ttyAMA":  {"CONFIG_SERIAL_AMBA_PL011", "CONFIG_SERIAL_AMBA_PL011_CONSOLE"},
+	"ttyS":    {"CONFIG_SERIAL_8250", "CONFIG_SERIAL_8250_CONSOLE"},
+	"hvc":     {"CONFIG_HVC_CONSOLE"},
+	"ttysclp": {"CONFIG_SCLP_TTY", "CONFIG_SCLP_CONSOLE"},
+}
+
+// BootRequirements returns the kernel config symbols needed by the QEMU
+// command line that buildArgs generates for these options.
+// The root disk is mounted without an initramfs, so its drivers must be built in.
+func (q *QEMURunner) BootRequirements(opts RunOptions) []BootRequirement {
+	var reqs []BootRequirement
+	add := func(symbol, want, reason string, optional bool) {
+		for _, r := range reqs {
+			if r.Symbol == symbol {
+				return
+			}
+		}
+		reqs = append(reqs, BootRequirement{
+			Requirement: kconfig.Requirement{Symbol: symbol, Want: want},
+			Reason:      reason,
+			Optional:    optional,
+		})
+	}
+
+	// -drive if=virtio and virtio-9p-pci sit on virtio-pci
+	add("CONFIG_VIRTIO_PCI", kconfig.Yes, "root disk (virtio-blk-pci)", false)
+	add("CONFIG_VIRTIO_BLK", kconfig.Yes, "root disk /dev/vda", false)
+	add("CONFIG_EXT4_FS", kconfig.Yes, "root filesystem", false)
+	add("CONFIG_DEVTMPFS", kconfig.Yes, "/dev mounted by /init", false)
+
+	archCfg := q.cfg.GetArchConfig()
+	if archCfg != nil {
+		if prefix := consolePrefix(archCfg.Console); consoleDrivers[prefix] != nil {
+			for _, sym := range consoleDrivers[prefix] {
+				add(sym, kconfig.Yes, "console "+archCfg.Console, false)
+			}
+		}
+		for _, r := range archCfg.RequiredConfig {
+			if req, err := kconfig.ParseRequirement(r); err == nil {
+				add(req.Symbol, req.Want, archCfg.Name+" machine", false)
+			}
+		}
+		if !strings.HasSuffix(archCfg.NetDevice(), "-pci") {
+			add("CONFIG_VIRTIO_MMIO", kconfig.Yes, archCfg.NetDevice(), true)
+		}
+	}
+
+	add("CONFIG_VIRTIO_NET", "", "network and SSH forwarding", true)
+	// /init mounts the share before any module can be loaded
+	add("CONFIG_NET_9P", kconfig.Yes, "modules share (virtio-9p-pci)", true)
+	add("CONFIG_NET_9P_VIRTIO", kconfig.Yes, "modules share (virtio-9p-pci)", true)
+	add("CONFIG_9P_FS", kconfig.Yes, "modules share (virtio-9p-pci)", true)
+
+	if opts.Graphical && opts.ConsoleLog == "" {
+		add("CONFIG_DRM_VIRTIO_GPU", kconfig.Yes, "display (virtio-gpu-pci)", false)
+		add("CONFIG_FRAMEBUFFER_CONSOLE", kconfig.Yes, "console=tty0", false)
+		add("CONFIG_VIRTIO_INPUT", "", "keyboard and mouse (virtio-*-pci)", true)
+	}
+	return reqs
+}
+
+// consolePrefix strips the device number from a console name ("ttyAMA0" -> "ttyAMA").
+func consolePrefix(console string) string {
+	return strings.TrimRight(console, "0123456789")
+}
+
+// loadKernelConfig parses the kernel .config.
+func (q *QEMURunner) loadKernelConfig() (*kconfig.Config, error) {
+	if !q.ctx.HasConfig() {
+		return nil, fmt.Errorf("kernel config not found")
+	}
+	data, err := q.fs.ReadFile(q.ctx.GetKernelConfig())
+	if err != nil {
+		return nil, err
+	}
+	return kconfig.ParseBytes(data), nil
+}
+
+// CheckBootConfig returns the boot requirements the kernel .config does not meet.
+func (q *QEMURunner) CheckBootConfig(opts RunOptions) ([]BootFailure, error) {
+	cfg, err := q.loadKernelConfig()
+	if err != nil {
+		return nil, err
+	}
+	var failures []BootFailure
+	for _, r := range q.BootRequirements(opts) {
+		if !r.Satisfied(cfg) {
+			failures = append(failures, BootFailure{BootRequirement: r, Actual: cfg.Value(r.Symbol)})
+		}
+	}
+	return failures, nil
+}
+
+// FixBootConfig applies the missing requirements to .config with scripts/config.
+// The caller must run olddefconfig and rebuild the kernel afterwards.
+func (q *QEMURunner) FixBootConfig(ctx context.Context, failures []BootFailure) error {
+	script := filepath.Join(q.cfg.Paths.KernelDir, "scripts", "config")
+	args := []string{"--file", q.ctx.GetKernelConfig()}
+	for _, f := range failures {
+		switch f.Want {
+		case "", kconfig.Yes:
+			args = append(args, "--enable", f.Symbol)
+		case kconfig.Module:
+			args = append(args, "--module", f.Symbol)
+		case kconfig.No:
+			args = append(args, "--disable", f.Symbol)
+		default:
+			args = append(args, "--set-val", f.Symbol, f.Want)
+		}
+	}
+	if err := q.exec.Run(ctx, script, args...); err != nil {
+		return fmt.Errorf("scripts/config failed: %w", err)
+	}
+	return nil
+}
+
+// CheckDebugConfig verifies that the kernel has debugging enabled.
+func (q *QEMURunner) CheckDebugConfig() error {
+	cfg, err := q.loadKernelConfig()
+	if err != nil {
+		return err
+	}
+
+	hasDebugKernel := cfg.BuiltIn("DEBUG_KERNEL")
+	hasDWARF := cfg.BuiltIn("DEBUG_INFO_DWARF_TOOLCHAIN_DEFAULT") ||
+		cfg.BuiltIn("DEBUG_INFO_DWARF5") ||
+		cfg.BuiltIn("DEBUG_INFO_DWARF4")
+
+	if !hasDebugKernel || !hasDWARF {
+		return fmt.Errorf("kernel debugging not enabled in .config (need CONFIG_DEBUG_KERNEL and DWARF info)")
+	}
+	return nil
+}
@@ -7,7 +7,6 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
-	"strings"
 	"syscall"
 
 	elconfig "github.com/NguyenTrongPhuc552003/elmos/core/config"
@@ -36,25 +35,50 @@ func NewQEMURunner(exec executor.Executor, fs filesystem.FileSystem, cfg *elconf
 
 // Run starts QEMU with the built kernel.
 func (q *QEMURunner) Run(ctx context.Context, opts RunOptions) error {
+	archCfg, kernelImage, inst, err := q.prepareRun(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.OnStart != nil {
+		opts.OnStart(inst)
+	}
+
+	// Build QEMU command arguments
+	args := q.buildArgs(archCfg, kernelImage, inst, opts)
+
+	// Execute QEMU
+	return q.executeQEMU(ctx, inst.Name, archCfg.QEMUBinary, args)
+}
+
+// prepareRun checks QEMU prerequisites and prepares the named instance.
+// It returns the arch config, kernel image and instance state.
+func (q *QEMURunner) prepareRun(ctx context.Context, opts RunOptions) (*elconfig.ArchConfig, string, *Instance, error) {
 	archCfg := q.cfg.GetArchConfig()
 	if archCfg == nil {
-		return fmt.Errorf("unsupported architecture for QEMU: %s", q.cfg.Build.Arch)
+		return nil, "", nil, fmt.Errorf("unsupported architecture for QEMU: %s", q.cfg.Build.Arch)
 	}
 
 	// Check QEMU binary
 	if _, err := q.exec.LookPath(archCfg.QEMUBinary); err != nil {
-		return fmt.Errorf("QEMU not found: %s (run 'brew install qemu')", archCfg.QEMUBinary)
+		return nil, "", nil, fmt.Errorf("QEMU not found: %s (run 'brew install qemu')", archCfg.QEMUBinary)
 	}
 
 	// Check kernel image
 	kernelImage := q.ctx.GetKernelImage()
 	if !q.fs.Exists(kernelImage) {
-		return fmt.Errorf("kernel image not found: %s (run 'elmos build')", kernelImage)
+		return nil, "", nil, fmt.Errorf("kernel image not found: %s (run 'elmos build')", kernelImage)
 	}
 
 	// Check disk image
 	if !q.fs.Exists(q.cfg.Paths.DiskImage) {
-		return fmt.Errorf("disk image not found: %s (run 'elmos rootfs create')", q.cfg.Paths.DiskImage)
+		return nil, "", nil, fmt.Errorf("disk image not found: %s (run 'elmos rootfs create')", q.cfg.Paths.DiskImage)
+	}
+
+	// Allocate ports, state and disk overlay for the instance
+	inst, err := q.prepareInstance(ctx, opts.Name, opts.Debug)
+	if err != nil {
+		return nil, "", nil, err
 	}
 
 	// Prepare modules sync script
@@ -63,23 +87,25 @@ func (q *QEMURunner) Run(ctx context.Context, opts RunOptions) error {
 		fmt.Printf("Warning: Failed to prepare module sync: %v\n", err)
 	}
 
-	// Build QEMU command arguments
-	args := q.buildArgs(archCfg, kernelImage, opts)
-
-	// Execute QEMU
-	return q.executeQEMU(ctx, archCfg.QEMUBinary, args)
+	return archCfg, kernelImage, inst, nil
 }
 
 // Debug starts QEMU in debug mode and waits for GDB connection.
-func (q *QEMURunner) Debug(ctx context.Context, graphical bool) error {
-	return q.Run(ctx, RunOptions{
-		Debug:     true,
-		Graphical: graphical,
-	})
+func (q *QEMURunner) Debug(ctx context.Context, opts RunOptions) error {
+	opts.Debug = true
+	return q.Run(ctx, opts)
 }
 
-// ConnectGDB launches cross-GDB and connects to a running QEMU instance.
-func (q *QEMURunner) ConnectGDB() error {
+// ConnectGDB launches cross-GDB and connects to a named QEMU instance started in debug mode.
+func (q *QEMURunner) ConnectGDB(name string) error {
+	inst, err := q.GetInstance(name)
+	if err != nil {
+		return err
+	}
+	if !inst.Running || inst.GDBPort == 0 {
+		return fmt.Errorf("instance %s is not running in debug mode (start it with 'elmos qemu debug')", inst.Name)
+	}
+
 	archCfg := q.cfg.GetArchConfig()
 	if archCfg == nil {
 		return fmt.Errorf("unsupported architecture for GDB: %s", q.cfg.Build.Arch)
@@ -104,7 +130,7 @@ func (q *QEMURunner) ConnectGDB() error {
 	args := []string{
 		gdbPath,
 		vmlinux,
-		"-ex", fmt.Sprintf("target remote localhost:%d", q.cfg.QEMU.GDBPort),
+		"-ex", fmt.Sprintf("target remote localhost:%d", inst.GDBPort),
 		"-ex", "layout src",
 		"-ex", "break start_kernel",
 	}
@@ -115,8 +141,10 @@ func (q *QEMURunner) ConnectGDB() error {
 }
 
 // buildArgs constructs the QEMU command line arguments.
-func (q *QEMURunner) buildArgs(archCfg *elconfig.ArchConfig, kernelImage string, opts RunOptions) []string {
+func (q *QEMURunner) buildArgs(archCfg *elconfig.ArchConfig, kernelImage string, inst *Instance, opts RunOptions) []string {
 	args := []string{
+		"-name", inst.Name,
+		"-pidfile", inst.PIDFile(),
 		"-m", q.cfg.QEMU.Memory,
 		"-smp", fmt.Sprintf("%d", q.cfg.QEMU.SMP),
 		"-kernel", kernelImage,
@@ -133,22 +161,36 @@ func (q *QEMURunner) buildArgs(archCfg *elconfig.ArchConfig, kernelImage string,
 
 	// Disk and networking
 	args = append(args,
-		"-drive", fmt.Sprintf("file=%s,format=raw,if=virtio", q.cfg.Paths.DiskImage),
-		"-device", "virtio-net-device,netdev=net0",
-		"-netdev", fmt.Sprintf("user,id=net0,hostfwd=tcp::%d-:22", q.cfg.QEMU.SSHPort),
+		"-drive", fmt.Sprintf("file=%s,format=qcow2,if=virtio", inst.Overlay),
+		"-device", archCfg.NetDevice()+",netdev=net0",
+		"-netdev", fmt.Sprintf("user,id=net0,hostfwd=tcp::%d-:22", inst.SSHPort),
 	)
 
+	// Ephemeral mode: writes go to a temporary file instead of the overlay
+	if opts.Snapshot {
+		args = append(args, "-snapshot")
+	}
+
 	// 9p share for modules
 	args = append(args,
 		"-fsdev", fmt.Sprintf("local,id=moddev,path=%s,security_model=none", q.cfg.Paths.ModulesDir),
 		"-device", "virtio-9p-pci,fsdev=moddev,mount_tag=modules_mount",
 	)
 
-	// Boot parameters
-	appendStr := "root=/dev/vda rw init=/init earlycon"
+	// Boot parameters; elmos.instance tells guesync.sh which agent queue to serve
+	appendStr := fmt.Sprintf("root=/dev/vda rw init=/init earlycon elmos.instance=%s", inst.Name)
 
 	// Display mode
-	if opts.Graphical {
+	if opts.ConsoleLog != "" {
+		// Headless: no monitor or stdio, exit instead of rebooting on panic
+		args = append(args,
+			"-display", "none",
+			"-monitor", "none",
+			"-serial", fmt.Sprintf("file:%s", opts.ConsoleLog),
+			"-no-reboot",
+		)
+		appendStr += fmt.Sprintf(" console=%s panic=-1", archCfg.Console)
+	} else if opts.Graphical {
 		args = append(args, "-display", "cocoa")
 		args = append(args,
 			"-device", "virtio-gpu-pci",
@@ -168,14 +210,17 @@ func (q *QEMURunner) buildArgs(archCfg *elconfig.ArchConfig, kernelImage string,
 
 	// Debug flags
 	if opts.Debug {
-		args = append(args, "-s", "-S")
+		args = append(args, "-gdb", fmt.Sprintf("tcp::%d", inst.GDBPort), "-S")
 	}
 
+	// QMP control channel for 'elmos qemu status|stop|...'
+	args = append(args, "-qmp", fmt.Sprintf("unix:%s,server=on,wait=off", inst.QMPSocket()))
+
 	return args
 }
 
 // executeQEMU runs the QEMU binary with signal handling.
-func (q *QEMURunner) executeQEMU(ctx context.Context, binary string, args []string) error {
+func (q *QEMURunner) executeQEMU(ctx context.Context, name, binary string, args []string) error {
 	// For now, we need to use the shell executor's direct run
 	// since we need interactive stdin/stdout
 	shellExec, ok := q.exec.(*executor.ShellExecutor)
@@ -206,6 +251,9 @@ func (q *QEMURunner) executeQEMU(ctx context.Context, binary string, args []stri
 		return err
 	case <-sigChan:
 		fmt.Println("\nReceived interrupt, stopping QEMU...")
+		if err := q.Stop(context.Background(), name, false); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
 		return nil
 	case <-ctx.Done():
 		return ctx.Err()
@@ -232,6 +280,25 @@ if [ -d "/mnt/modules" ]; then
             echo "  [GUEST]   -> $modname FAILED (already loaded or error)"
         fi
     done
+
+    # Agent for 'elmos module reload': runs scripts the host queues on the share
+    instance=$(sed -n 's/.*elmos\.instance=\([^ ]*\).*/\1/p' /proc/cmdline)
+    agent="/mnt/modules/.elmos-agent/${instance:-default}"
+    mkdir -p "$agent"
+    (
+        while true; do
+            : > "$agent/heartbeat"
+            for req in "$agent"/*.req; do
+                [ -f "$req" ] || continue
+                id="${req%.req}"
+                rm -f "$req"
+                sh "$id.sh" > "$id.out" 2>&1
+                echo $? > "$id.rc"
+            done
+            sleep 1
+        done
+    ) > /dev/null 2>&1 &
+    echo "  [GUEST] Reload agent started ($agent)"
 else
     echo "  [GUEST] Warning: /mnt/modules not found"
 fi
@@ -239,27 +306,3 @@ fi
 
 	return q.fs.WriteFile(syncPath, []byte(content), 0755)
 }
-
-// CheckDebugConfig verifies that the kernel has debugging enabled.
-func (q *QEMURunner) CheckDebugConfig() error {
-	if !q.ctx.HasConfig() {
-		return fmt.Errorf("kernel config not found")
-	}
-
-	configFile := filepath.Join(q.cfg.Paths.KernelDir, ".config")
-	content, err := q.fs.ReadFile(configFile)
-	if err != nil {
-		return err
-	}
-
-	configStr := string(content)
-	hasDebugKernel := strings.Contains(configStr, "CONFIG_DEBUG_KERNEL=y")
-	hasDWARF := strings.Contains(configStr, "CONFIG_DEBUG_INFO_DWARF_TOOLCHAIN_DEFAULT=y") ||
-		strings.Contains(configStr, "CONFIG_DEBUG_INFO_DWARF5=y")
-
-	if !hasDebugKernel || !hasDWARF {
-		return fmt.Errorf("kernel debugging not enabled in .config (need CONFIG_DEBUG_KERNEL and DWARF info)")
-	}
-
-	return nil
-}
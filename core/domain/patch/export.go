@@ -0,0 +1,78 @@
+// Package patch provides kernel patch management for elmos.
+// This file contains exporting kernel tree commits into the patches directory.
+package patch
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Export writes the commits selected by revs (any 'git format-patch' revision
+// arguments, e.g. "HEAD~3" or "-1 abc123") into patches/<version>/<arch>,
+// numbered after the patches already there. When the directory has a series
+// file, the new patches are appended to it.
+func (m *Manager) Export(ctx context.Context, revs []string, version, arch string) ([]PatchInfo, error) {
+	dir := filepath.Join(m.cfg.Paths.PatchesDir, version, arch)
+	if err := m.fs.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	args := []string{"-C", m.cfg.Paths.KernelDir, "format-patch", "-o", dir, "--start-number", strconv.Itoa(m.nextNumber(dir))}
+	out, err := m.exec.Output(ctx, "git", append(args, revs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("git format-patch failed: %w", err)
+	}
+
+	var exported []PatchInfo
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			exported = append(exported, m.patchInfo(version, arch, filepath.Base(line)))
+		}
+	}
+	if len(exported) == 0 {
+		return nil, fmt.Errorf("no commits selected by %s", strings.Join(revs, " "))
+	}
+
+	if err := m.appendSeries(dir, exported); err != nil {
+		return exported, err
+	}
+	return exported, nil
+}
+
+// nextNumber returns the number after the highest "NNNN-" prefix in dir.
+func (m *Manager) nextNumber(dir string) int {
+	entries, err := m.fs.ReadDir(dir)
+	if err != nil {
+		return 1
+	}
+	highest := 0
+	for _, e := range entries {
+		prefix, _, ok := strings.Cut(e.Name(), "-")
+		if !ok || !strings.HasSuffix(e.Name(), ".patch") {
+			continue
+		}
+		if n, err := strconv.Atoi(prefix); err == nil && n > highest {
+			highest = n
+		}
+	}
+	return highest + 1
+}
+
+// appendSeries adds patches to the series file of dir, if it has one.
+func (m *Manager) appendSeries(dir string, patches []PatchInfo) error {
+	path := filepath.Join(dir, seriesFile)
+	data, err := m.fs.ReadFile(path)
+	if err != nil {
+		return nil // Ordered by file name
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	for _, p := range patches {
+		data = append(data, p.Name+"\n"...)
+	}
+	return m.fs.WriteFile(path, data, 0644)
+}
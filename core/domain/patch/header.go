@@ -0,0 +1,65 @@
+// Package patch provides kernel patch management for elmos.
+// This file contains parsing of patch and mailbox headers.
+package patch
+
+import (
+	"bytes"
+	"mime"
+	"net/mail"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// patchTagRe matches the "[PATCH v2 1/3]" tag git format-patch puts before the subject.
+var patchTagRe = regexp.MustCompile(`^\[[^\]]*\]\s*`)
+
+// patchInfo describes a patch file in patches/<version>/<arch>, with the
+// subject and author read from its headers.
+func (m *Manager) patchInfo(version, arch, name string) PatchInfo {
+	p := PatchInfo{
+		Name:    name,
+		Path:    filepath.Join(m.cfg.Paths.PatchesDir, version, arch, name),
+		Version: version,
+		Arch:    arch,
+	}
+	if data, err := m.fs.ReadFile(p.Path); err == nil {
+		p.Subject, p.Author = parsePatchHeader(data)
+	}
+	return p
+}
+
+// parsePatchHeader returns the subject and author of a mailbox-style patch.
+// Both are empty for plain diffs.
+func parsePatchHeader(data []byte) (subject, author string) {
+	// Skip the mbox "From <sha> <date>" separator
+	if bytes.HasPrefix(data, []byte("From ")) {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return "", ""
+	}
+
+	dec := new(mime.WordDecoder)
+	subject = msg.Header.Get("Subject")
+	if decoded, err := dec.DecodeHeader(subject); err == nil {
+		subject = decoded
+	}
+	author = msg.Header.Get("From")
+	if decoded, err := dec.DecodeHeader(author); err == nil {
+		author = decoded
+	}
+	return patchTagRe.ReplaceAllString(strings.TrimSpace(subject), ""), strings.TrimSpace(author)
+}
+
+// isMailbox reports whether a patch file is in mbox format (git format-patch output).
+func (m *Manager) isMailbox(path string) bool {
+	data, err := m.fs.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return bytes.HasPrefix(data, []byte("From ")) && bytes.Contains(data, []byte("\nSubject: "))
+}
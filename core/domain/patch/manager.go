@@ -28,31 +28,21 @@ func NewManager(exec executor.Executor, fs filesystem.FileSystem, cfg *elconfig.
 	}
 }
 
-// Apply applies a patch file to the kernel source.
-func (m *Manager) Apply(ctx context.Context, patchFile string) error {
-	// Resolve patch path
-	patchPath := patchFile
-
-	// Handle absolute paths
-	if filepath.IsAbs(patchPath) {
-		if !m.fs.Exists(patchPath) {
-			return fmt.Errorf("patch file not found: %s", patchFile)
-		}
-	} else {
-		// Strip redundant patches/ prefix if user included it
-		patchPath = strings.TrimPrefix(patchPath, "patches/")
-
-		// Build full path from patches directory
-		fullPath := filepath.Join(m.cfg.Paths.PatchesDir, patchPath)
-		if !m.fs.Exists(fullPath) {
-			return fmt.Errorf("patch file not found: %s", patchFile)
-		}
-		patchPath = fullPath
+// Apply applies a patch file to the kernel source. Mailbox patches (as
+// written by git format-patch) are applied with 'git am' so the commit and its
+// authorship are kept, unless opts.NoCommit is set.
+func (m *Manager) Apply(ctx context.Context, patchFile string, opts ApplyOptions) error {
+	patchPath, err := m.resolvePath(patchFile)
+	if err != nil {
+		return err
+	}
+	if !opts.NoCommit && m.isMailbox(patchPath) {
+		return m.applyMailbox(ctx, patchPath)
 	}
 
 	// Check if patch is already applied
 	checkArgs := []string{"-p1", "--dry-run", "-i", patchPath}
-	err := m.exec.RunInDir(ctx, m.cfg.Paths.KernelDir, "patch", checkArgs...)
+	err = m.exec.RunInDir(ctx, m.cfg.Paths.KernelDir, "patch", checkArgs...)
 	if err != nil {
 		// Try reverse check to see if already applied
 		reverseArgs := []string{"-p1", "--dry-run", "-R", "-i", patchPath}
@@ -71,21 +61,28 @@ func (m *Manager) Apply(ctx context.Context, patchFile string) error {
 	return nil
 }
 
+// applyMailbox commits a mailbox patch to the kernel tree with 'git am'.
+func (m *Manager) applyMailbox(ctx context.Context, patchPath string) error {
+	kernelDir := m.cfg.Paths.KernelDir
+	if err := m.exec.RunInDir(ctx, kernelDir, "git", "apply", "--check", patchPath); err != nil {
+		if m.exec.RunInDir(ctx, kernelDir, "git", "apply", "--check", "-R", patchPath) == nil {
+			return fmt.Errorf("patch appears to already be applied")
+		}
+		return fmt.Errorf("patch cannot be applied cleanly: %w", err)
+	}
+
+	if err := m.exec.RunInDir(ctx, kernelDir, "git", "am", "--3way", patchPath); err != nil {
+		_ = m.exec.RunInDir(ctx, kernelDir, "git", "am", "--abort")
+		return fmt.Errorf("git am failed: %w", err)
+	}
+	return nil
+}
+
 // Reverse reverses a previously applied patch.
 func (m *Manager) Reverse(ctx context.Context, patchFile string) error {
-	patchPath := patchFile
-
-	if filepath.IsAbs(patchPath) {
-		if !m.fs.Exists(patchPath) {
-			return fmt.Errorf("patch file not found: %s", patchFile)
-		}
-	} else {
-		patchPath = strings.TrimPrefix(patchPath, "patches/")
-		fullPath := filepath.Join(m.cfg.Paths.PatchesDir, patchPath)
-		if !m.fs.Exists(fullPath) {
-			return fmt.Errorf("patch file not found: %s", patchFile)
-		}
-		patchPath = fullPath
+	patchPath, err := m.resolvePath(patchFile)
+	if err != nil {
+		return err
 	}
 
 	reverseArgs := []string{"-p1", "-R", "-i", patchPath}
@@ -96,6 +93,30 @@ func (m *Manager) Reverse(ctx context.Context, patchFile string) error {
 	return nil
 }
 
+// resolvePath returns the full path of a patch given as an absolute path or
+// relative to the patches directory.
+func (m *Manager) resolvePath(patchFile string) (string, error) {
+	patchPath := patchFile
+
+	// Handle absolute paths
+	if filepath.IsAbs(patchPath) {
+		if !m.fs.Exists(patchPath) {
+			return "", fmt.Errorf("patch file not found: %s", patchFile)
+		}
+		return patchPath, nil
+	}
+
+	// Strip redundant patches/ prefix if user included it
+	patchPath = strings.TrimPrefix(patchPath, "patches/")
+
+	// Build full path from patches directory
+	fullPath := filepath.Join(m.cfg.Paths.PatchesDir, patchPath)
+	if !m.fs.Exists(fullPath) {
+		return "", fmt.Errorf("patch file not found: %s", patchFile)
+	}
+	return fullPath, nil
+}
+
 // List returns all available patches.
 func (m *Manager) List() ([]PatchInfo, error) {
 	if !m.fs.Exists(m.cfg.Paths.PatchesDir) {
@@ -151,18 +172,16 @@ func (m *Manager) listPatchesForArch(version, arch string) []PatchInfo {
 		if pf.IsDir() || !strings.HasSuffix(pf.Name(), ".patch") {
 			continue
 		}
-		patches = append(patches, PatchInfo{
-			Name:    pf.Name(),
-			Path:    filepath.Join(archDir, pf.Name()),
-			Version: version,
-			Arch:    arch,
-		})
+		patches = append(patches, m.patchInfo(version, arch, pf.Name()))
 	}
 	return patches
 }
 
-// GetPatchesForVersion returns patches for a specific kernel version.
-func (m *Manager) GetPatchesForVersion(version string) ([]PatchInfo, error) {
+// ListForArch returns the patches that apply to the current build architecture.
+// These are the "generic" patches plus those in a directory named after the
+// architecture, its kernel ARCH= value or its arch/ source directory
+// (so x86_64 picks up patches kept under "x86").
+func (m *Manager) ListForArch() ([]PatchInfo, error) {
 	allPatches, err := m.List()
 	if err != nil {
 		return nil, err
@@ -170,10 +189,21 @@ func (m *Manager) GetPatchesForVersion(version string) ([]PatchInfo, error) {
 
 	var filtered []PatchInfo
 	for _, p := range allPatches {
-		if p.Version == version {
+		if m.archMatches(p.Arch) {
 			filtered = append(filtered, p)
 		}
 	}
-
 	return filtered, nil
 }
+
+// archMatches checks whether a patch arch directory applies to the build architecture.
+func (m *Manager) archMatches(dir string) bool {
+	if dir == "generic" || dir == m.cfg.Build.Arch {
+		return true
+	}
+	archCfg := m.cfg.GetArchConfig()
+	if archCfg == nil {
+		return false
+	}
+	return dir == archCfg.KernelArch || dir == archCfg.SrcArch()
+}
@@ -0,0 +1,526 @@
+// Package patch provides kernel patch management for elmos.
+// This file contains quilt-like patch series (stack) management.
+package patch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Series and state file names.
+const (
+	seriesFile  = "series"          // Ordered patch list in a patches/<version>/<arch> directory
+	stateDir    = ".elmos"          // Patch state directory inside the kernel tree
+	appliedFile = "applied-patches" // Applied patch IDs, bottom of the stack first
+	backupDir   = "pc"              // Pre-push copies of the files each patch touches
+)
+
+// ErrSeriesMismatch is returned when the applied stack is not a prefix of the series.
+var ErrSeriesMismatch = errors.New("applied patches do not match the series")
+
+// StatePath returns the directory holding the applied patch state in the kernel tree.
+func (m *Manager) StatePath() string {
+	return filepath.Join(m.cfg.Paths.KernelDir, stateDir)
+}
+
+// Series returns the ordered patch series for a kernel version: the generic
+// directory first, then the directories matching the current architecture.
+// Each directory is ordered by its series file, or by file name without one.
+func (m *Manager) Series(version string) ([]PatchInfo, error) {
+	entries, err := m.fs.ReadDir(filepath.Join(m.cfg.Paths.PatchesDir, version))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read patches for %s: %w", version, err)
+	}
+
+	var arches []string
+	for _, e := range entries {
+		if e.IsDir() && m.archMatches(e.Name()) {
+			arches = append(arches, e.Name())
+		}
+	}
+	sort.SliceStable(arches, func(i, j int) bool {
+		return arches[i] == "generic" && arches[j] != "generic"
+	})
+
+	var series []PatchInfo
+	for _, arch := range arches {
+		patches, err := m.seriesForArch(version, arch)
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, patches...)
+	}
+	return series, nil
+}
+
+// seriesForArch returns the ordered patches of one version/arch directory.
+func (m *Manager) seriesForArch(version, arch string) ([]PatchInfo, error) {
+	dir := filepath.Join(m.cfg.Paths.PatchesDir, version, arch)
+	data, err := m.fs.ReadFile(filepath.Join(dir, seriesFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m.listPatchesForArch(version, arch), nil
+		}
+		return nil, fmt.Errorf("failed to read series: %w", err)
+	}
+
+	var patches []PatchInfo
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// quilt allows options such as -p1 after the name; only -p1 is supported
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		path := filepath.Join(dir, fields[0])
+		if !m.fs.Exists(path) {
+			return nil, fmt.Errorf("%s lists missing patch %s", filepath.Join(dir, seriesFile), fields[0])
+		}
+		patches = append(patches, m.patchInfo(version, arch, fields[0]))
+	}
+	return patches, nil
+}
+
+// Applied returns the patches applied to the kernel tree, bottom of the stack first.
+func (m *Manager) Applied() ([]PatchInfo, error) {
+	data, err := m.fs.ReadFile(filepath.Join(m.StatePath(), appliedFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read applied patches: %w", err)
+	}
+
+	var applied []PatchInfo
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "/", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		applied = append(applied, m.patchInfo(parts[0], parts[1], parts[2]))
+	}
+	return applied, nil
+}
+
+// writeApplied stores the applied stack in the kernel tree.
+func (m *Manager) writeApplied(applied []PatchInfo) error {
+	dir := m.StatePath()
+	if len(applied) == 0 {
+		return m.fs.RemoveAll(dir)
+	}
+	if err := m.fs.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// Keep the state out of 'git status' in the kernel tree
+	if err := m.fs.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*\n"), 0644); err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, p := range applied {
+		b.WriteString(p.ID() + "\n")
+	}
+	return m.fs.WriteFile(filepath.Join(dir, appliedFile), []byte(b.String()), 0644)
+}
+
+// Status returns a series with the applied state of each patch. Applied
+// patches that are not part of the series are listed after it.
+func (m *Manager) Status(series []PatchInfo) ([]SeriesEntry, error) {
+	applied, err := m.Applied()
+	if err != nil {
+		return nil, err
+	}
+	isApplied := make(map[string]bool, len(applied))
+	for _, p := range applied {
+		isApplied[p.ID()] = true
+	}
+
+	entries := make([]SeriesEntry, 0, len(series))
+	inSeries := make(map[string]bool, len(series))
+	for _, p := range series {
+		inSeries[p.ID()] = true
+		entries = append(entries, SeriesEntry{PatchInfo: p, Applied: isApplied[p.ID()]})
+	}
+	// Applied patches from another series, e.g. before a kernel switch
+	for _, p := range applied {
+		if !inSeries[p.ID()] {
+			entries = append(entries, SeriesEntry{PatchInfo: p, Applied: true})
+		}
+	}
+	if len(applied) > 0 {
+		top := applied[len(applied)-1].ID()
+		for i := range entries {
+			entries[i].Top = entries[i].ID() == top
+		}
+	}
+	return entries, nil
+}
+
+// unapplied returns the rest of the series after the applied stack.
+func (m *Manager) unapplied(series []PatchInfo) ([]PatchInfo, error) {
+	applied, err := m.Applied()
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range applied {
+		if i >= len(series) || p.ID() != series[i].ID() {
+			return nil, fmt.Errorf("%w: %s is applied but not next in the series; pop the applied patches first", ErrSeriesMismatch, p.ID())
+		}
+	}
+	return series[len(applied):], nil
+}
+
+// Push applies the next patch of the series, or every patch up to and
+// including upTo when it is set. It returns the patches applied.
+func (m *Manager) Push(ctx context.Context, series []PatchInfo, upTo string) ([]PatchInfo, error) {
+	pending, err := m.unapplied(series)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
+		return nil, fmt.Errorf("series fully applied")
+	}
+
+	count := 1
+	if upTo != "" {
+		count = 0
+		for i, p := range pending {
+			if p.Name == upTo || p.ID() == upTo {
+				count = i + 1
+				break
+			}
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("patch %s is not in the unapplied part of the series", upTo)
+		}
+	}
+	return m.pushPatches(ctx, pending[:count])
+}
+
+// PushAll applies every unapplied patch of the series.
+func (m *Manager) PushAll(ctx context.Context, series []PatchInfo) ([]PatchInfo, error) {
+	pending, err := m.unapplied(series)
+	if err != nil {
+		return nil, err
+	}
+	return m.pushPatches(ctx, pending)
+}
+
+// pushPatches applies patches in order, stopping at the first failure.
+// Patches applied before the failure stay on the stack.
+func (m *Manager) pushPatches(ctx context.Context, patches []PatchInfo) ([]PatchInfo, error) {
+	var pushed []PatchInfo
+	for _, p := range patches {
+		if err := m.pushOne(ctx, p); err != nil {
+			return pushed, fmt.Errorf("%s: %w", p.Name, err)
+		}
+		pushed = append(pushed, p)
+	}
+	return pushed, nil
+}
+
+// pushOne backs up the files a patch touches, applies it and records it.
+func (m *Manager) pushOne(ctx context.Context, p PatchInfo) error {
+	files, err := m.patchFiles(p.Path)
+	if err != nil {
+		return err
+	}
+	kernelDir := m.cfg.Paths.KernelDir
+	if err := m.exec.RunInDir(ctx, kernelDir, "patch", "-p1", "--dry-run", "--silent", "-i", p.Path); err != nil {
+		return fmt.Errorf("patch does not apply cleanly: %w", err)
+	}
+
+	backup := m.backupPath(p)
+	_ = m.fs.RemoveAll(backup)
+	for _, f := range files {
+		dst := filepath.Join(backup, f)
+		if err := m.fs.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := m.copyFile(filepath.Join(kernelDir, f), dst); err != nil {
+			if os.IsNotExist(err) {
+				continue // Created by the patch
+			}
+			return err
+		}
+	}
+
+	if err := m.exec.RunInDir(ctx, kernelDir, "patch", "-p1", "-i", p.Path); err != nil {
+		_ = m.restoreBackup(p, files)
+		return fmt.Errorf("failed to apply patch: %w", err)
+	}
+
+	applied, err := m.Applied()
+	if err != nil {
+		return err
+	}
+	return m.writeApplied(append(applied, p))
+}
+
+// Pop removes the top patch, or every patch above upTo when it is set.
+// With force, the touched files are restored from their backups instead of
+// reverse-applying the patch, discarding unrefreshed changes.
+func (m *Manager) Pop(ctx context.Context, upTo string, force bool) ([]PatchInfo, error) {
+	applied, err := m.Applied()
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) == 0 {
+		return nil, fmt.Errorf("no patches applied")
+	}
+
+	count := 1
+	if upTo != "" {
+		count = -1
+		for i := len(applied) - 1; i >= 0; i-- {
+			if applied[i].Name == upTo || applied[i].ID() == upTo {
+				count = len(applied) - 1 - i
+				break
+			}
+		}
+		if count < 0 {
+			return nil, fmt.Errorf("patch %s is not applied", upTo)
+		}
+	}
+	return m.popPatches(ctx, applied, count, force)
+}
+
+// PopAll removes every applied patch.
+func (m *Manager) PopAll(ctx context.Context, force bool) ([]PatchInfo, error) {
+	applied, err := m.Applied()
+	if err != nil {
+		return nil, err
+	}
+	return m.popPatches(ctx, applied, len(applied), force)
+}
+
+// popPatches removes the top count patches of the stack, updating the state after each.
+func (m *Manager) popPatches(ctx context.Context, applied []PatchInfo, count int, force bool) ([]PatchInfo, error) {
+	var popped []PatchInfo
+	for i := 0; i < count; i++ {
+		p := applied[len(applied)-1]
+		if err := m.popOne(ctx, p, force); err != nil {
+			return popped, fmt.Errorf("%s: %w", p.Name, err)
+		}
+		applied = applied[:len(applied)-1]
+		if err := m.writeApplied(applied); err != nil {
+			return popped, err
+		}
+		popped = append(popped, p)
+	}
+	return popped, nil
+}
+
+// popOne removes a single patch from the kernel tree.
+func (m *Manager) popOne(ctx context.Context, p PatchInfo, force bool) error {
+	if force {
+		files, err := m.patchFiles(p.Path)
+		if err != nil {
+			return err
+		}
+		if err := m.restoreBackup(p, files); err != nil {
+			return err
+		}
+	} else {
+		kernelDir := m.cfg.Paths.KernelDir
+		if err := m.exec.RunInDir(ctx, kernelDir, "patch", "-p1", "-R", "--dry-run", "--silent", "-i", p.Path); err != nil {
+			return fmt.Errorf("patch does not reverse cleanly (refresh it or pop with --force): %w", err)
+		}
+		if err := m.Reverse(ctx, p.Path); err != nil {
+			return err
+		}
+	}
+	return m.fs.RemoveAll(m.backupPath(p))
+}
+
+// Refresh rewrites the top patch from the current contents of the files it
+// touches. The patch description (everything before the first diff) is kept.
+func (m *Manager) Refresh(ctx context.Context) (*PatchInfo, error) {
+	applied, err := m.Applied()
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) == 0 {
+		return nil, fmt.Errorf("no patches applied")
+	}
+	top := applied[len(applied)-1]
+
+	data, err := m.fs.ReadFile(top.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", top.Path, err)
+	}
+	files, err := m.patchFiles(top.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var diff bytes.Buffer
+	for _, f := range files {
+		out, err := m.fileDiff(ctx, top, f)
+		if err != nil {
+			return nil, err
+		}
+		diff.Write(out)
+	}
+	if diff.Len() == 0 {
+		return nil, fmt.Errorf("%s would be empty; pop it instead", top.Name)
+	}
+
+	content := append([]byte(patchHeader(string(data))), diff.Bytes()...)
+	if err := m.fs.WriteFile(top.Path, content, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", top.Path, err)
+	}
+	return &top, nil
+}
+
+// fileDiff returns the unified diff of one file between its backup and the kernel tree.
+func (m *Manager) fileDiff(ctx context.Context, p PatchInfo, file string) ([]byte, error) {
+	oldPath, oldLabel := filepath.Join(m.backupPath(p), file), "a/"+file
+	if !m.fs.Exists(oldPath) {
+		oldPath, oldLabel = os.DevNull, os.DevNull
+	}
+	newPath, newLabel := filepath.Join(m.cfg.Paths.KernelDir, file), "b/"+file
+	if !m.fs.Exists(newPath) {
+		newPath, newLabel = os.DevNull, os.DevNull
+	}
+
+	out, err := m.exec.Output(ctx, "diff", "-u", "--label", oldLabel, "--label", newLabel, oldPath, newPath)
+	// diff exits with 1 when the files differ
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		return nil, fmt.Errorf("diff %s failed: %w", file, err)
+	}
+	return out, nil
+}
+
+// restoreBackup puts the files a patch touches back to their pre-push contents.
+func (m *Manager) restoreBackup(p PatchInfo, files []string) error {
+	backup := m.backupPath(p)
+	for _, f := range files {
+		src, dst := filepath.Join(backup, f), filepath.Join(m.cfg.Paths.KernelDir, f)
+		if !m.fs.Exists(src) {
+			// The patch created this file
+			if err := m.fs.RemoveAll(dst); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := m.fs.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := m.copyFile(src, dst); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", f, err)
+		}
+	}
+	return nil
+}
+
+// copyFile copies src to dst, keeping the permission bits of src so that
+// scripts touched by a patch stay executable across push and pop.
+func (m *Manager) copyFile(src, dst string) error {
+	info, err := m.fs.Stat(src)
+	if err != nil {
+		return err
+	}
+	data, err := m.fs.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	mode := info.Mode().Perm()
+	if err := m.fs.WriteFile(dst, data, mode); err != nil {
+		return err
+	}
+	// WriteFile leaves the mode of an existing file alone and is subject to the umask
+	return m.fs.Chmod(dst, mode)
+}
+
+// backupPath returns the directory holding the pre-push copies for a patch.
+func (m *Manager) backupPath(p PatchInfo) string {
+	return filepath.Join(m.StatePath(), backupDir, p.Version, p.Arch, p.Name)
+}
+
+// patchFiles returns the kernel-relative paths a -p1 patch touches, in order.
+func (m *Manager) patchFiles(path string) ([]string, error) {
+	data, err := m.fs.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	seen := make(map[string]bool)
+	var files []string
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			files = append(files, name)
+		}
+	}
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			// Rename-only and mode-only diffs have no ---/+++ header
+			add(gitDiffName(line))
+		case strings.HasPrefix(line, "rename from "), strings.HasPrefix(line, "copy from "):
+			add(strings.SplitN(line, " from ", 2)[1])
+		case strings.HasPrefix(line, "rename to "), strings.HasPrefix(line, "copy to "):
+			add(strings.SplitN(line, " to ", 2)[1])
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			// A file header is a "--- old" line directly followed by "+++ new"
+			for _, header := range lines[i : i+2] {
+				name := strings.SplitN(header[4:], "\t", 2)[0]
+				if name == os.DevNull {
+					continue
+				}
+				if j := strings.Index(name, "/"); j >= 0 {
+					name = name[j+1:]
+				}
+				add(name)
+			}
+			i++
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s does not touch any files", filepath.Base(path))
+	}
+	return files, nil
+}
+
+// gitDiffName returns the path of a "diff --git a/<path> b/<path>" line when
+// both sides name the same file. Renames are taken from "rename from/to" instead.
+func gitDiffName(line string) string {
+	rest := strings.TrimPrefix(line, "diff --git ")
+	// rest is "a/" + name + " b/" + name
+	if len(rest) < 7 || (len(rest)-5)%2 != 0 {
+		return ""
+	}
+	n := (len(rest) - 5) / 2
+	oldName, newName := rest[2:2+n], rest[len(rest)-n:]
+	if !strings.HasPrefix(rest, "a/") || rest[2+n:len(rest)-n] != " b/" || oldName != newName {
+		return ""
+	}
+	return oldName
+}
+
+// patchHeader returns the description part of a patch, up to the first diff.
+// A git format-patch diffstat is dropped since it no longer matches.
+func patchHeader(content string) string {
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if strings.HasPrefix(line, "diff ") || strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "Index: ") {
+			break
+		}
+		b.WriteString(line)
+		if line == "---\n" {
+			break
+		}
+	}
+	return b.String()
+}
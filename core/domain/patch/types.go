@@ -4,8 +4,27 @@ package patch
 
 // PatchInfo contains information about a patch file.
 type PatchInfo struct {
-	Name    string // Name of the patch file
-	Path    string // Full path to the patch file
-	Version string // Kernel version this patch applies to
-	Arch    string // Target architecture (e.g., "arm", "riscv", "generic")
+	Name    string `json:"name" yaml:"name"`                           // Name of the patch file
+	Path    string `json:"path" yaml:"path"`                           // Full path to the patch file
+	Version string `json:"version" yaml:"version"`                     // Kernel version this patch applies to
+	Arch    string `json:"arch" yaml:"arch"`                           // Target architecture (e.g., "arm", "riscv", "x86", "generic")
+	Subject string `json:"subject,omitempty" yaml:"subject,omitempty"` // Commit subject without the [PATCH] tag
+	Author  string `json:"author,omitempty" yaml:"author,omitempty"`   // From: header, e.g. "Jane Doe <jane@example.com>"
+}
+
+// ApplyOptions controls how a single patch file is applied.
+type ApplyOptions struct {
+	NoCommit bool // Apply mailbox patches with patch(1) instead of 'git am'
+}
+
+// SeriesEntry is a patch in a series together with its state in the kernel tree.
+type SeriesEntry struct {
+	PatchInfo `yaml:",inline"`
+	Applied   bool `json:"applied" yaml:"applied"`
+	Top       bool `json:"top" yaml:"top"` // Most recently pushed patch
+}
+
+// ID returns the patch path relative to the patches directory, e.g. "v6.18/generic/0001-fix.patch".
+func (p *PatchInfo) ID() string {
+	return p.Version + "/" + p.Arch + "/" + p.Name
 }
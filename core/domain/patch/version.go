@@ -0,0 +1,78 @@
+// Package patch provides kernel patch management for elmos.
+// This file contains matching of patch version directories to kernel versions.
+package patch
+
+import (
+	"sort"
+	"strings"
+
+	elcontext "github.com/NguyenTrongPhuc552003/elmos/core/context"
+)
+
+// MatchesKernel reports whether a patch version directory applies to a kernel.
+//
+//	v6.18    6.18 and its stable releases (6.18.x)
+//	v6.18.3  exactly 6.18.3
+//	v6.18+   6.18 and every later kernel
+//
+// Release candidates count as the release they precede.
+func MatchesKernel(dir string, kv *elcontext.KernelVersion) bool {
+	name, orLater := strings.CutSuffix(dir, "+")
+	want, err := elcontext.ParseKernelVersion(name)
+	if err != nil || want.Extra != "" {
+		return false
+	}
+	if orLater {
+		return kv.Compare(want) >= 0
+	}
+	if strings.Count(name, ".") == 1 {
+		return kv.Major == want.Major && kv.Minor == want.Minor
+	}
+	return kv.Compare(want) == 0
+}
+
+// VersionsFor returns the patch version directories that apply to a kernel,
+// oldest first so that broad "v6.1+" fixes come before version-specific ones.
+func (m *Manager) VersionsFor(kv *elcontext.KernelVersion) ([]string, error) {
+	if !m.fs.Exists(m.cfg.Paths.PatchesDir) {
+		return nil, nil
+	}
+	entries, err := m.fs.ReadDir(m.cfg.Paths.PatchesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for _, e := range entries {
+		if e.IsDir() && MatchesKernel(e.Name(), kv) {
+			dirs = append(dirs, e.Name())
+		}
+	}
+	sort.SliceStable(dirs, func(i, j int) bool {
+		a, _ := elcontext.ParseKernelVersion(strings.TrimSuffix(dirs[i], "+"))
+		b, _ := elcontext.ParseKernelVersion(strings.TrimSuffix(dirs[j], "+"))
+		if c := a.Compare(b); c != 0 {
+			return c < 0
+		}
+		return dirs[i] < dirs[j]
+	})
+	return dirs, nil
+}
+
+// KernelSeries returns the patch series for a kernel: the series of every
+// matching version directory, for the current architecture and "generic".
+func (m *Manager) KernelSeries(kv *elcontext.KernelVersion) ([]PatchInfo, error) {
+	dirs, err := m.VersionsFor(kv)
+	if err != nil {
+		return nil, err
+	}
+	var series []PatchInfo
+	for _, dir := range dirs {
+		patches, err := m.Series(dir)
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, patches...)
+	}
+	return series, nil
+}
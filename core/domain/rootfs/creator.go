@@ -35,6 +35,10 @@ func (c *Creator) Create(ctx context.Context, opts CreateOptions) error {
 		size = "5G"
 	}
 
+	if c.getDebianArch() == "" {
+		return fmt.Errorf("no Debian port for architecture %s (set debian_arch or provide a custom rootfs)", c.cfg.Build.Arch)
+	}
+
 	diskImage := c.cfg.Paths.DiskImage
 	rootfsDir := c.cfg.Paths.RootfsDir
 
@@ -156,18 +160,14 @@ func (c *Creator) fixAptLists(rootfsDir string) error {
 	return nil
 }
 
-// getDebianArch returns the Debian architecture name.
+// getDebianArch returns the Debian architecture name for the build arch.
+// It is empty when the architecture has no Debian port.
 func (c *Creator) getDebianArch() string {
-	switch c.cfg.Build.Arch {
-	case "arm64":
-		return "arm64"
-	case "arm":
-		return "armhf"
-	case "riscv":
-		return "riscv64"
-	default:
-		return "arm64"
+	archCfg := c.cfg.GetArchConfig()
+	if archCfg == nil || archCfg.DebianArch == "none" {
+		return ""
 	}
+	return archCfg.DebianArch
 }
 
 // createInitScript creates the /init script for the rootfs.
@@ -190,12 +190,12 @@ func (c *Creator) createInitScript(rootfsDir string) error {
 
 // RootfsInfo contains information about the rootfs.
 type RootfsInfo struct {
-	DiskImageExists bool
-	DiskImagePath   string
-	DiskImageSize   int64
-	RootfsDirExists bool
-	RootfsDirPath   string
-	Architecture    string
+	DiskImageExists bool   `json:"disk_image_exists" yaml:"disk_image_exists"`
+	DiskImagePath   string `json:"disk_image_path" yaml:"disk_image_path"`
+	DiskImageSize   int64  `json:"disk_image_size" yaml:"disk_image_size"`
+	RootfsDirExists bool   `json:"rootfs_dir_exists" yaml:"rootfs_dir_exists"`
+	RootfsDirPath   string `json:"rootfs_dir_path" yaml:"rootfs_dir_path"`
+	Architecture    string `json:"architecture" yaml:"architecture"`
 }
 
 // Status returns information about the current rootfs.
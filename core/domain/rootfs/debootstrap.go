@@ -2,7 +2,17 @@
 // This file contains type definitions for the rootfs package.
 package rootfs
 
+import "time"
+
 // CreateOptions contains options for creating a rootfs.
 type CreateOptions struct {
 	Size string // Disk image size, e.g., "5G"
 }
+
+// SnapshotInfo describes a saved rootfs disk snapshot.
+type SnapshotInfo struct {
+	Name    string    `json:"name" yaml:"name"`
+	Path    string    `json:"path" yaml:"path"`
+	Size    int64     `json:"size" yaml:"size"`       // Size of the qcow2 file on disk
+	Created time.Time `json:"created" yaml:"created"` // Modification time of the snapshot file
+}
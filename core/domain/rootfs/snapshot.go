@@ -0,0 +1,120 @@
+// Package rootfs provides rootfs creation functionality for elmos.
+// This file contains qcow2 snapshots of the rootfs disk image.
+package rootfs
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// snapshotExt is the file extension of rootfs snapshots.
+const snapshotExt = ".qcow2"
+
+// snapshotNamePattern restricts snapshot names to safe file names.
+var snapshotNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// SnapshotDir returns the directory holding rootfs snapshots, next to the disk image.
+func (c *Creator) SnapshotDir() string {
+	return filepath.Join(filepath.Dir(c.cfg.Paths.DiskImage), "snapshots")
+}
+
+// snapshotPath validates a snapshot name and returns its file path.
+func (c *Creator) snapshotPath(name string) (string, error) {
+	if !snapshotNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid snapshot name: %q (use letters, digits, '.', '_' and '-')", name)
+	}
+	return filepath.Join(c.SnapshotDir(), name+snapshotExt), nil
+}
+
+// CreateSnapshot saves a compressed, standalone qcow2 copy of a disk.
+// Source defaults to the base disk image; pass an instance overlay to
+// capture the state of a guest (its backing file is flattened in).
+func (c *Creator) CreateSnapshot(ctx context.Context, name, source string, force bool) error {
+	path, err := c.snapshotPath(name)
+	if err != nil {
+		return err
+	}
+	if source == "" {
+		source = c.cfg.Paths.DiskImage
+	}
+	if !c.fs.Exists(source) {
+		return fmt.Errorf("disk image not found: %s (run 'elmos rootfs create')", source)
+	}
+	if c.fs.Exists(path) && !force {
+		return fmt.Errorf("snapshot already exists: %s (use --force to overwrite)", name)
+	}
+	if err := c.fs.MkdirAll(c.SnapshotDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	if err := c.exec.Run(ctx, "qemu-img", "convert", "-c", "-O", "qcow2", source, path); err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	return nil
+}
+
+// RestoreSnapshot writes a snapshot back over the base disk image.
+// Instance overlays are recreated on their next run because the base is newer.
+func (c *Creator) RestoreSnapshot(ctx context.Context, name string) error {
+	path, err := c.snapshotPath(name)
+	if err != nil {
+		return err
+	}
+	if !c.fs.Exists(path) {
+		return fmt.Errorf("snapshot not found: %s", name)
+	}
+
+	// Convert to a temporary file first so a failure leaves the base intact
+	tmp := c.cfg.Paths.DiskImage + ".restore"
+	if err := c.exec.Run(ctx, "qemu-img", "convert", "-O", "raw", path, tmp); err != nil {
+		_ = c.fs.Remove(tmp)
+		return fmt.Errorf("failed to restore snapshot: %w", err)
+	}
+	if err := c.exec.Run(ctx, "mv", "-f", tmp, c.cfg.Paths.DiskImage); err != nil {
+		return fmt.Errorf("failed to replace disk image: %w", err)
+	}
+	return nil
+}
+
+// DeleteSnapshot removes a snapshot.
+func (c *Creator) DeleteSnapshot(name string) error {
+	path, err := c.snapshotPath(name)
+	if err != nil {
+		return err
+	}
+	if !c.fs.Exists(path) {
+		return fmt.Errorf("snapshot not found: %s", name)
+	}
+	return c.fs.Remove(path)
+}
+
+// ListSnapshots returns all rootfs snapshots, oldest first.
+func (c *Creator) ListSnapshots() ([]SnapshotInfo, error) {
+	entries, err := c.fs.ReadDir(c.SnapshotDir())
+	if err != nil {
+		return nil, nil // No snapshots taken yet
+	}
+
+	var snapshots []SnapshotInfo
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), snapshotExt) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, SnapshotInfo{
+			Name:    strings.TrimSuffix(e.Name(), snapshotExt),
+			Path:    filepath.Join(c.SnapshotDir(), e.Name()),
+			Size:    info.Size(),
+			Created: info.ModTime(),
+		})
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Created.Before(snapshots[j].Created) })
+	return snapshots, nil
+}
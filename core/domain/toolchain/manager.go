@@ -35,10 +35,10 @@ func NewManager(exec executor.Executor, fs filesystem.FileSystem, cfg *elconfig.
 
 // ToolchainInfo contains information about a built toolchain.
 type ToolchainInfo struct {
-	Target    string // e.g., "riscv64-unknown-linux-gnu"
-	Path      string // Full path to toolchain directory
-	Installed bool   // Whether fully built
-	Version   string // GCC version if installed
+	Target    string `json:"target" yaml:"target"`                       // e.g., "riscv64-unknown-linux-gnu"
+	Path      string `json:"path" yaml:"path"`                           // Full path to toolchain directory
+	Installed bool   `json:"installed" yaml:"installed"`                 // Whether fully built
+	Version   string `json:"version,omitempty" yaml:"version,omitempty"` // GCC version if installed
 }
 
 // Paths returns important toolchain-related paths.
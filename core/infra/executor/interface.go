@@ -1,7 +1,10 @@
 // Package executor provides abstractions for executing shell commands.
 package executor
 
-import "context"
+import (
+	"context"
+	"io"
+)
 
 // Executor defines the interface for executing shell commands.
 // This abstraction allows for easy mocking in tests and potential
@@ -20,6 +23,14 @@ type Executor interface {
 	// RunWithEnvInDir executes a command with custom environment in a specific directory.
 	RunWithEnvInDir(ctx context.Context, env []string, dir string, cmd string, args ...string) error
 
+	// RunWithEnvTee executes a command with custom environment, copying
+	// stdout and stderr to w in addition to the terminal.
+	RunWithEnvTee(ctx context.Context, env []string, w io.Writer, cmd string, args ...string) error
+
+	// RunWithEnvInDirTo executes a command with custom environment in a
+	// specific directory, sending stdout and stderr to w instead of the terminal.
+	RunWithEnvInDirTo(ctx context.Context, env []string, dir string, w io.Writer, cmd string, args ...string) error
+
 	// Output executes a command and returns its stdout.
 	Output(ctx context.Context, cmd string, args ...string) ([]byte, error)
 
@@ -4,6 +4,7 @@ package executor
 import (
 	"context"
 	"fmt"
+	"io"
 )
 
 // CommandCall records a single command execution for verification in tests.
@@ -76,6 +77,24 @@ func (m *MockExecutor) RunWithEnvInDir(ctx context.Context, env []string, dir st
 	return m.RunError
 }
 
+// RunWithEnvTee records the command execution and writes the configured mock output to w.
+func (m *MockExecutor) RunWithEnvTee(ctx context.Context, env []string, w io.Writer, cmd string, args ...string) error {
+	m.Calls = append(m.Calls, CommandCall{Cmd: cmd, Args: args, Env: env})
+	if out, ok := m.OutputResponses[cmd]; ok {
+		_, _ = w.Write(out)
+	}
+	return m.RunError
+}
+
+// RunWithEnvInDirTo records the command execution and writes the configured mock output to w.
+func (m *MockExecutor) RunWithEnvInDirTo(ctx context.Context, env []string, dir string, w io.Writer, cmd string, args ...string) error {
+	m.Calls = append(m.Calls, CommandCall{Cmd: cmd, Args: args, Env: env, Dir: dir})
+	if out, ok := m.OutputResponses[cmd]; ok {
+		_, _ = w.Write(out)
+	}
+	return m.RunError
+}
+
 // Output returns the configured mock output for the command.
 func (m *MockExecutor) Output(ctx context.Context, cmd string, args ...string) ([]byte, error) {
 	m.Calls = append(m.Calls, CommandCall{Cmd: cmd, Args: args})
@@ -3,8 +3,10 @@ package executor
 
 import (
 	"context"
+	"io"
 	"os"
 	"os/exec"
+	"sync"
 	"syscall"
 )
 
@@ -61,6 +63,50 @@ func (e *ShellExecutor) RunWithEnvInDir(ctx context.Context, env []string, dir s
 	return c.Run()
 }
 
+// RunWithEnvTee executes a command with custom environment, copying stdout and stderr to w.
+func (e *ShellExecutor) RunWithEnvTee(ctx context.Context, env []string, w io.Writer, cmd string, args ...string) error {
+	c := exec.CommandContext(ctx, cmd, args...)
+	// stdout and stderr are copied concurrently, so serialize writes to w
+	tee := &lockedWriter{w: w}
+	c.Stdout = io.MultiWriter(e.Stdout, tee)
+	c.Stderr = io.MultiWriter(e.Stderr, tee)
+	c.Stdin = e.Stdin
+
+	if len(env) > 0 {
+		c.Env = append(os.Environ(), env...)
+	}
+
+	return c.Run()
+}
+
+// RunWithEnvInDirTo executes a command with custom environment in a specific
+// directory, sending stdout and stderr to w instead of the terminal.
+func (e *ShellExecutor) RunWithEnvInDirTo(ctx context.Context, env []string, dir string, w io.Writer, cmd string, args ...string) error {
+	c := exec.CommandContext(ctx, cmd, args...)
+	out := &lockedWriter{w: w}
+	c.Stdout, c.Stderr = out, out
+	c.Dir = dir
+
+	if len(env) > 0 {
+		c.Env = append(os.Environ(), env...)
+	}
+
+	return c.Run()
+}
+
+// lockedWriter serializes writes to an underlying writer.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Write writes p to the underlying writer while holding the lock.
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
 // Output executes a command and returns its stdout.
 func (e *ShellExecutor) Output(ctx context.Context, cmd string, args ...string) ([]byte, error) {
 	return e.OutputWithEnv(ctx, nil, cmd, args...)
@@ -19,6 +19,9 @@ type FileSystem interface {
 	// WriteFile writes data to the named file, creating it if necessary.
 	WriteFile(name string, data []byte, perm os.FileMode) error
 
+	// Chmod changes the mode of the named file.
+	Chmod(name string, mode os.FileMode) error
+
 	// MkdirAll creates a directory and all necessary parents.
 	MkdirAll(path string, perm os.FileMode) error
 
@@ -29,6 +29,11 @@ func (f *OSFileSystem) WriteFile(name string, data []byte, perm os.FileMode) err
 	return os.WriteFile(name, data, perm)
 }
 
+// Chmod changes the mode of the named file.
+func (f *OSFileSystem) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
 // MkdirAll creates a directory and all necessary parents.
 func (f *OSFileSystem) MkdirAll(path string, perm os.FileMode) error {
 	return os.MkdirAll(path, perm)
@@ -0,0 +1,109 @@
+// Package kconfig parses and edits Linux kernel .config files.
+// This file contains config comparison and required-symbol assertions.
+package kconfig
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Change is a symbol whose value differs between two configs.
+// Old or New is empty when the symbol is absent from that side.
+type Change struct {
+	Symbol string
+	Old    string
+	New    string
+}
+
+// String formats the change like scripts/diffconfig.
+func (c Change) String() string {
+	switch {
+	case c.Old == "":
+		return fmt.Sprintf("+%s %s", strings.TrimPrefix(c.Symbol, "CONFIG_"), c.New)
+	case c.New == "":
+		return fmt.Sprintf("-%s %s", strings.TrimPrefix(c.Symbol, "CONFIG_"), c.Old)
+	default:
+		return fmt.Sprintf(" %s %s -> %s", strings.TrimPrefix(c.Symbol, "CONFIG_"), c.Old, c.New)
+	}
+}
+
+// Diff returns the symbols that differ between two configs, sorted by name.
+func Diff(a, b *Config) []Change {
+	names := make(map[string]bool)
+	for _, n := range a.order {
+		names[n] = true
+	}
+	for _, n := range b.order {
+		names[n] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for n := range names {
+		sorted = append(sorted, n)
+	}
+	sort.Strings(sorted)
+
+	var changes []Change
+	for _, n := range sorted {
+		old, inA := a.symbols[n]
+		cur, inB := b.symbols[n]
+		if inA && inB && old == cur {
+			continue
+		}
+		changes = append(changes, Change{Symbol: n, Old: old, New: cur})
+	}
+	return changes
+}
+
+// Requirement asserts the value of a symbol.
+type Requirement struct {
+	Symbol string
+	Want   string // Exact value, or empty for "enabled" (y or m)
+}
+
+// String formats the requirement as it is written on the command line.
+func (r Requirement) String() string {
+	if r.Want == "" {
+		return r.Symbol
+	}
+	return r.Symbol + "=" + r.Want
+}
+
+// ParseRequirement parses "SYMBOL" (enabled) or "SYMBOL=value".
+// The CONFIG_ prefix is optional.
+func ParseRequirement(s string) (Requirement, error) {
+	name, want, _ := strings.Cut(strings.TrimSpace(s), "=")
+	if name == "" {
+		return Requirement{}, fmt.Errorf("invalid requirement: %q", s)
+	}
+	name = Name(name)
+	if !setPattern.MatchString(name + "=") {
+		return Requirement{}, fmt.Errorf("invalid symbol name: %q", name)
+	}
+	return Requirement{Symbol: name, Want: want}, nil
+}
+
+// Satisfied checks the requirement against a config.
+func (r Requirement) Satisfied(c *Config) bool {
+	if r.Want == "" {
+		return c.Enabled(r.Symbol)
+	}
+	return c.Value(r.Symbol) == r.Want
+}
+
+// Failure is a requirement that a config does not meet.
+type Failure struct {
+	Requirement
+	Actual string
+}
+
+// Check returns the requirements the config does not satisfy.
+func (c *Config) Check(reqs []Requirement) []Failure {
+	var failures []Failure
+	for _, r := range reqs {
+		if !r.Satisfied(c) {
+			failures = append(failures, Failure{Requirement: r, Actual: c.Value(r.Symbol)})
+		}
+	}
+	return failures
+}
@@ -0,0 +1,181 @@
+// Package kconfig parses and edits Linux kernel .config files.
+package kconfig
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Tristate and boolean values as they appear in .config.
+const (
+	Yes    = "y"
+	Module = "m"
+	No     = "n" // Also used for "# CONFIG_X is not set" and absent symbols
+)
+
+var (
+	// setPattern matches "CONFIG_FOO=value".
+	setPattern = regexp.MustCompile(`^(CONFIG_[A-Za-z0-9_]+)=(.*)$`)
+
+	// unsetPattern matches "# CONFIG_FOO is not set".
+	unsetPattern = regexp.MustCompile(`^# (CONFIG_[A-Za-z0-9_]+) is not set$`)
+)
+
+// Symbol is a single assignment in a .config file.
+type Symbol struct {
+	Name  string // Full name including the CONFIG_ prefix
+	Value string // "y", "m", "n", a number or a quoted string
+}
+
+// Line renders the symbol as a .config line.
+func (s Symbol) Line() string {
+	if s.Value == No {
+		return fmt.Sprintf("# %s is not set", s.Name)
+	}
+	return fmt.Sprintf("%s=%s", s.Name, s.Value)
+}
+
+// Config is a parsed .config or config fragment.
+// It keeps the original lines so that edits preserve comments and ordering.
+type Config struct {
+	lines   []string
+	dropped map[int]bool   // Lines superseded by a later assignment
+	index   map[string]int // Symbol name -> line number
+	symbols map[string]string
+	order   []string // Symbol names in file order
+}
+
+// New returns an empty config.
+func New() *Config {
+	return &Config{dropped: make(map[int]bool), index: make(map[string]int), symbols: make(map[string]string)}
+}
+
+// Parse reads a .config or fragment.
+// A symbol assigned more than once keeps its last value, as in Kconfig.
+func Parse(r io.Reader) (*Config, error) {
+	c := New()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		c.lines = append(c.lines, line)
+		if sym, ok := parseLine(line); ok {
+			if prev, seen := c.index[sym.Name]; seen {
+				c.dropped[prev] = true
+			} else {
+				c.order = append(c.order, sym.Name)
+			}
+			c.index[sym.Name] = len(c.lines) - 1
+			c.symbols[sym.Name] = sym.Value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	return c, nil
+}
+
+// ParseBytes parses config data held in memory.
+func ParseBytes(data []byte) *Config {
+	c, _ := Parse(bytes.NewReader(data)) // Reading from memory cannot fail
+	return c
+}
+
+// parseLine parses one line, reporting whether it assigns a symbol.
+func parseLine(line string) (Symbol, bool) {
+	line = strings.TrimSpace(line)
+	if m := setPattern.FindStringSubmatch(line); m != nil {
+		return Symbol{Name: m[1], Value: m[2]}, true
+	}
+	if m := unsetPattern.FindStringSubmatch(line); m != nil {
+		return Symbol{Name: m[1], Value: No}, true
+	}
+	return Symbol{}, false
+}
+
+// Name returns a symbol name with the CONFIG_ prefix, accepting either form.
+func Name(name string) string {
+	name = strings.TrimSpace(name)
+	if strings.HasPrefix(name, "CONFIG_") {
+		return name
+	}
+	return "CONFIG_" + name
+}
+
+// Lookup returns a symbol's value and whether the file assigns it.
+func (c *Config) Lookup(name string) (string, bool) {
+	v, ok := c.symbols[Name(name)]
+	return v, ok
+}
+
+// Value returns a symbol's value, or "n" when it is absent.
+func (c *Config) Value(name string) string {
+	if v, ok := c.Lookup(name); ok {
+		return v
+	}
+	return No
+}
+
+// Enabled reports whether a symbol is built in or a module.
+func (c *Config) Enabled(name string) bool {
+	v := c.Value(name)
+	return v == Yes || v == Module
+}
+
+// BuiltIn reports whether a symbol is built in.
+func (c *Config) BuiltIn(name string) bool {
+	return c.Value(name) == Yes
+}
+
+// Set assigns a symbol, replacing its existing line or appending a new one.
+// Setting "n" writes a "# CONFIG_X is not set" line.
+func (c *Config) Set(name, value string) {
+	sym := Symbol{Name: Name(name), Value: value}
+	if i, ok := c.index[sym.Name]; ok {
+		c.lines[i] = sym.Line()
+	} else {
+		c.lines = append(c.lines, sym.Line())
+		c.index[sym.Name] = len(c.lines) - 1
+		c.order = append(c.order, sym.Name)
+	}
+	c.symbols[sym.Name] = value
+}
+
+// Symbols returns the assignments in file order.
+func (c *Config) Symbols() []Symbol {
+	out := make([]Symbol, 0, len(c.order))
+	for _, name := range c.order {
+		out = append(out, Symbol{Name: name, Value: c.symbols[name]})
+	}
+	return out
+}
+
+// Names returns the assigned symbol names in sorted order.
+func (c *Config) Names() []string {
+	names := append([]string(nil), c.order...)
+	sort.Strings(names)
+	return names
+}
+
+// Len returns the number of assigned symbols.
+func (c *Config) Len() int {
+	return len(c.order)
+}
+
+// Bytes renders the config, preserving comments and the original order.
+func (c *Config) Bytes() []byte {
+	var buf bytes.Buffer
+	for i, line := range c.lines {
+		if c.dropped[i] {
+			continue
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
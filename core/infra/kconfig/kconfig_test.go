@@ -0,0 +1,206 @@
+package kconfig
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testConfig = `#
+# Automatically generated file; DO NOT EDIT.
+# Linux/arm64 6.12.0 Kernel Configuration
+#
+CONFIG_DEBUG_INFO=y
+CONFIG_DEBUG_INFO_DWARF5=m
+# CONFIG_DEBUG is not set
+CONFIG_NR_CPUS=64
+CONFIG_CMDLINE="console=ttyAMA0"
+# CONFIG_KASAN is not set
+# This comment mentions CONFIG_KGDB=y but assigns nothing
+CONFIG_LOCALVERSION=""
+CONFIG_NR_CPUS=8
+`
+
+func TestParse(t *testing.T) {
+	c := ParseBytes([]byte(testConfig))
+
+	tests := []struct {
+		name     string
+		value    string
+		assigned bool
+		enabled  bool
+		builtIn  bool
+	}{
+		{"DEBUG_INFO", Yes, true, true, true},
+		{"CONFIG_DEBUG_INFO", Yes, true, true, true},
+		{"DEBUG_INFO_DWARF5", Module, true, true, false},
+		{"DEBUG", No, true, false, false},      // "is not set"; a prefix of DEBUG_INFO
+		{"DEBUG_INF", No, false, false, false}, // Substring of an assigned symbol
+		{"NR_CPUS", "8", true, false, false},   // Last assignment wins
+		{"CMDLINE", `"console=ttyAMA0"`, true, false, false},
+		{"KASAN", No, true, false, false},
+		{"KGDB", No, false, false, false},
+		{"LOCALVERSION", `""`, true, false, false},
+		{"MISSING", No, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, ok := c.Lookup(tt.name)
+			if ok != tt.assigned {
+				t.Errorf("Lookup() assigned = %v, want %v", ok, tt.assigned)
+			}
+			if got := c.Value(tt.name); got != tt.value {
+				t.Errorf("Value() = %q, want %q", got, tt.value)
+			}
+			if ok && v != tt.value {
+				t.Errorf("Lookup() = %q, want %q", v, tt.value)
+			}
+			if got := c.Enabled(tt.name); got != tt.enabled {
+				t.Errorf("Enabled() = %v, want %v", got, tt.enabled)
+			}
+			if got := c.BuiltIn(tt.name); got != tt.builtIn {
+				t.Errorf("BuiltIn() = %v, want %v", got, tt.builtIn)
+			}
+		})
+	}
+
+	if got, want := c.Len(), 7; got != want {
+		t.Errorf("Len() = %d, want %d", got, want)
+	}
+}
+
+func TestSetAndBytes(t *testing.T) {
+	c := ParseBytes([]byte("# header\nCONFIG_A=y\nCONFIG_B=m\nCONFIG_A=m\n"))
+	c.Set("B", No)
+	c.Set("CONFIG_C", "y")
+
+	want := "# header\n# CONFIG_B is not set\nCONFIG_A=m\nCONFIG_C=y\n" // A keeps its last line
+	if got := string(c.Bytes()); got != want {
+		t.Errorf("Bytes() = %q, want %q", got, want)
+	}
+	if got := ParseBytes(c.Bytes()).Value("B"); got != No {
+		t.Errorf("reparsed B = %q, want %q", got, No)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Change
+	}{
+		{
+			name: "identical",
+			a:    "CONFIG_A=y\n# CONFIG_B is not set\n",
+			b:    "# CONFIG_B is not set\nCONFIG_A=y\n",
+			want: nil,
+		},
+		{
+			name: "y to m",
+			a:    "CONFIG_A=y\n",
+			b:    "CONFIG_A=m\n",
+			want: []Change{{Symbol: "CONFIG_A", Old: Yes, New: Module}},
+		},
+		{
+			name: "not set is a value",
+			a:    "CONFIG_A=y\n",
+			b:    "# CONFIG_A is not set\n",
+			want: []Change{{Symbol: "CONFIG_A", Old: Yes, New: No}},
+		},
+		{
+			name: "added and removed, sorted",
+			a:    "CONFIG_ZED=y\nCONFIG_DEBUG=y\n",
+			b:    "CONFIG_DEBUG=y\nCONFIG_DEBUG_INFO=y\n",
+			want: []Change{
+				{Symbol: "CONFIG_DEBUG_INFO", New: Yes},
+				{Symbol: "CONFIG_ZED", Old: Yes},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Diff(ParseBytes([]byte(tt.a)), ParseBytes([]byte(tt.b)))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangeString(t *testing.T) {
+	tests := []struct {
+		change Change
+		want   string
+	}{
+		{Change{Symbol: "CONFIG_A", New: "y"}, "+A y"},
+		{Change{Symbol: "CONFIG_A", Old: "m"}, "-A m"},
+		{Change{Symbol: "CONFIG_A", Old: "y", New: "m"}, " A y -> m"},
+	}
+	for _, tt := range tests {
+		if got := tt.change.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestParseRequirement(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Requirement
+		wantErr bool
+	}{
+		{in: "KGDB", want: Requirement{Symbol: "CONFIG_KGDB"}},
+		{in: "CONFIG_KGDB=y", want: Requirement{Symbol: "CONFIG_KGDB", Want: "y"}},
+		{in: " NR_CPUS=8 ", want: Requirement{Symbol: "CONFIG_NR_CPUS", Want: "8"}},
+		{in: "=y", wantErr: true},
+		{in: "BAD-NAME", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRequirement(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRequirement(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRequirement(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRequirementSatisfied(t *testing.T) {
+	c := ParseBytes([]byte(testConfig))
+
+	tests := []struct {
+		req  string
+		want bool
+	}{
+		{"DEBUG_INFO", true},
+		{"DEBUG_INFO=y", true},
+		{"DEBUG_INFO=m", false},
+		{"DEBUG_INFO_DWARF5", true}, // m counts as enabled
+		{"DEBUG_INFO_DWARF5=y", false},
+		{"DEBUG_INFO_DWARF5=m", true},
+		{"DEBUG", false}, // Not set, although DEBUG_INFO is
+		{"DEBUG=n", true},
+		{"DEBUG_INF", false},
+		{"KASAN=n", true},
+		{"MISSING=n", true},
+		{"KGDB", false},
+		{"NR_CPUS=8", true},
+		{"NR_CPUS=64", false},
+	}
+	for _, tt := range tests {
+		r, err := ParseRequirement(tt.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.Satisfied(c); got != tt.want {
+			t.Errorf("%s.Satisfied() = %v, want %v", tt.req, got, tt.want)
+		}
+	}
+
+	failures := c.Check([]Requirement{{Symbol: "CONFIG_KASAN"}, {Symbol: "CONFIG_DEBUG_INFO", Want: "y"}})
+	want := []Failure{{Requirement: Requirement{Symbol: "CONFIG_KASAN"}, Actual: No}}
+	if !reflect.DeepEqual(failures, want) {
+		t.Errorf("Check() = %+v, want %+v", failures, want)
+	}
+}
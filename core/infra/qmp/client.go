@@ -0,0 +1,124 @@
+// Package qmp provides a client for the QEMU Machine Protocol.
+package qmp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds each QMP exchange when the context has no deadline.
+const DefaultTimeout = 5 * time.Second
+
+// Client is a connection to a QEMU QMP socket.
+// Commands are serialized; asynchronous events received while waiting for a
+// reply are kept and can be read with Events.
+type Client struct {
+	conn    net.Conn
+	dec     *json.Decoder
+	enc     *json.Encoder
+	mu      sync.Mutex
+	version Version
+	events  []Event
+}
+
+// Dial connects to a QMP Unix socket and negotiates capabilities.
+func Dial(ctx context.Context, socketPath string) (*Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to QMP socket %s: %w", socketPath, err)
+	}
+
+	c := &Client{
+		conn: conn,
+		dec:  json.NewDecoder(conn),
+		enc:  json.NewEncoder(conn),
+	}
+	if err := c.handshake(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// handshake reads the greeting and leaves capabilities negotiation mode.
+func (c *Client) handshake(ctx context.Context) error {
+	c.setDeadline(ctx)
+
+	var greeting message
+	if err := c.dec.Decode(&greeting); err != nil {
+		return fmt.Errorf("failed to read QMP greeting: %w", err)
+	}
+	if greeting.QMP == nil {
+		return fmt.Errorf("unexpected QMP greeting")
+	}
+	c.version = greeting.QMP.Version
+
+	_, err := c.Execute(ctx, "qmp_capabilities", nil)
+	return err
+}
+
+// Version returns the QEMU version reported at connect time.
+func (c *Client) Version() Version {
+	return c.version
+}
+
+// Execute runs a QMP command and returns the raw "return" payload.
+func (c *Client) Execute(ctx context.Context, cmd string, args interface{}) (json.RawMessage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setDeadline(ctx)
+	if err := c.enc.Encode(command{Execute: cmd, Arguments: args}); err != nil {
+		return nil, fmt.Errorf("failed to send %s: %w", cmd, err)
+	}
+
+	for {
+		var raw json.RawMessage
+		if err := c.dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("failed to read reply to %s: %w", cmd, err)
+		}
+		var msg message
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			return nil, fmt.Errorf("invalid reply to %s: %w", cmd, err)
+		}
+		switch {
+		case msg.Event != "":
+			var ev Event
+			if json.Unmarshal(raw, &ev) == nil {
+				c.events = append(c.events, ev)
+			}
+		case msg.Error != nil:
+			return nil, msg.Error
+		default:
+			return msg.Return, nil
+		}
+	}
+}
+
+// Events returns and clears the events received so far.
+func (c *Client) Events() []Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	events := c.events
+	c.events = nil
+	return events
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// setDeadline applies the context deadline, or DefaultTimeout, to the connection.
+func (c *Client) setDeadline(ctx context.Context) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(DefaultTimeout)
+	}
+	_ = c.conn.SetDeadline(deadline)
+}
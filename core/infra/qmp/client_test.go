@@ -0,0 +1,219 @@
+package qmp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+const testGreeting = `{"QMP": {"version": {"qemu": {"micro": 2, "minor": 1, "major": 8}, "package": "v8.1.2"}, "capabilities": ["oob"]}}`
+
+// fakeServer is a QMP server on a Unix socket that answers commands with a handler.
+type fakeServer struct {
+	path     string
+	listener net.Listener
+
+	mu       sync.Mutex
+	received []command
+}
+
+// handler returns the raw lines to send in response to a command.
+type handler func(cmd command) []string
+
+// newFakeServer starts a QMP server that greets, accepts qmp_capabilities and
+// then answers every other command with h.
+func newFakeServer(t *testing.T, greeting string, h handler) *fakeServer {
+	t.Helper()
+	// Unix socket paths are length-limited, so avoid the long t.TempDir path
+	dir, err := os.MkdirTemp("", "qmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	s := &fakeServer{path: filepath.Join(dir, "qmp.sock")}
+	s.listener, err = net.Listen("unix", s.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.listener.Close() })
+
+	go s.serve(greeting, h)
+	return s
+}
+
+// serve handles a single client connection.
+func (s *fakeServer) serve(greeting string, h handler) {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	fmt.Fprintln(conn, greeting)
+	dec := json.NewDecoder(conn)
+	for {
+		var cmd command
+		if err := dec.Decode(&cmd); err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.received = append(s.received, cmd)
+		s.mu.Unlock()
+
+		if cmd.Execute == "qmp_capabilities" {
+			fmt.Fprintln(conn, `{"return": {}}`)
+			continue
+		}
+		for _, line := range h(cmd) {
+			fmt.Fprintln(conn, line)
+		}
+	}
+}
+
+// commands returns the names of the commands received so far.
+func (s *fakeServer) commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, len(s.received))
+	for i, cmd := range s.received {
+		names[i] = cmd.Execute
+	}
+	return names
+}
+
+// dial connects a client to the fake server.
+func dial(t *testing.T, s *fakeServer) *Client {
+	t.Helper()
+	c, err := Dial(context.Background(), s.path)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestDialHandshake(t *testing.T) {
+	s := newFakeServer(t, testGreeting, func(command) []string { return nil })
+	c := dial(t, s)
+
+	if got := c.Version().String(); got != "8.1.2" {
+		t.Errorf("Version() = %s, want 8.1.2", got)
+	}
+	if got := s.commands(); len(got) != 1 || got[0] != "qmp_capabilities" {
+		t.Errorf("server received %v, want [qmp_capabilities]", got)
+	}
+}
+
+func TestDialRejectsBadGreeting(t *testing.T) {
+	s := newFakeServer(t, `{"return": {}}`, func(command) []string { return nil })
+	if _, err := Dial(context.Background(), s.path); err == nil {
+		t.Fatal("expected error for a reply in place of the greeting")
+	}
+}
+
+func TestExecuteMatchesReplies(t *testing.T) {
+	s := newFakeServer(t, testGreeting, func(cmd command) []string {
+		switch cmd.Execute {
+		case "query-status":
+			return []string{`{"return": {"running": true, "singlestep": false, "status": "running"}}`}
+		case "human-monitor-command":
+			args, _ := json.Marshal(cmd.Arguments)
+			return []string{fmt.Sprintf(`{"return": %q}`, string(args))}
+		default:
+			return []string{`{"return": {}}`}
+		}
+	})
+	c := dial(t, s)
+	ctx := context.Background()
+
+	status, err := c.QueryStatus(ctx)
+	if err != nil {
+		t.Fatalf("QueryStatus: %v", err)
+	}
+	if !status.Running || status.Status != "running" {
+		t.Errorf("QueryStatus() = %+v, want running", status)
+	}
+
+	out, err := c.HumanMonitorCommand(ctx, "savevm base")
+	if err != nil {
+		t.Fatalf("HumanMonitorCommand: %v", err)
+	}
+	if out != `{"command-line":"savevm base"}` {
+		t.Errorf("HumanMonitorCommand() = %q, want the echoed arguments", out)
+	}
+
+	if err := c.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	want := []string{"qmp_capabilities", "query-status", "human-monitor-command", "stop"}
+	got := s.commands()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("server received %v, want %v", got, want)
+	}
+}
+
+func TestExecuteKeepsInterleavedEvents(t *testing.T) {
+	s := newFakeServer(t, testGreeting, func(cmd command) []string {
+		return []string{
+			`{"event": "STOP", "timestamp": {"seconds": 1700000000, "microseconds": 1}}`,
+			`{"event": "RESUME", "data": {"reason": "test"}, "timestamp": {"seconds": 1700000001, "microseconds": 2}}`,
+			`{"return": {}}`,
+		}
+	})
+	c := dial(t, s)
+
+	raw, err := c.Execute(context.Background(), "cont", nil)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if string(raw) != "{}" {
+		t.Errorf("Execute() = %s, want {}", raw)
+	}
+
+	events := c.Events()
+	if len(events) != 2 {
+		t.Fatalf("Events() returned %d events, want 2", len(events))
+	}
+	if events[0].Event != "STOP" || events[1].Event != "RESUME" {
+		t.Errorf("events = %s, %s; want STOP, RESUME", events[0].Event, events[1].Event)
+	}
+	if events[1].Timestamp.Seconds != 1700000001 || string(events[1].Data) != `{"reason": "test"}` {
+		t.Errorf("RESUME event decoded as %+v", events[1])
+	}
+	if len(c.Events()) != 0 {
+		t.Error("Events() did not clear the queue")
+	}
+}
+
+func TestExecuteErrorReply(t *testing.T) {
+	s := newFakeServer(t, testGreeting, func(cmd command) []string {
+		if cmd.Execute == "bogus" {
+			return []string{`{"error": {"class": "CommandNotFound", "desc": "The command bogus has not been found"}}`}
+		}
+		return []string{`{"return": {}}`}
+	})
+	c := dial(t, s)
+	ctx := context.Background()
+
+	_, err := c.Execute(ctx, "bogus", nil)
+	var qerr *Error
+	if !errors.As(err, &qerr) {
+		t.Fatalf("Execute() error = %v, want *qmp.Error", err)
+	}
+	if qerr.Class != "CommandNotFound" {
+		t.Errorf("error class = %s, want CommandNotFound", qerr.Class)
+	}
+
+	// The connection stays usable after an error reply
+	if err := c.Cont(ctx); err != nil {
+		t.Errorf("Cont after error reply: %v", err)
+	}
+}
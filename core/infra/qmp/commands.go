@@ -0,0 +1,66 @@
+// Package qmp provides a client for the QEMU Machine Protocol.
+// This file contains typed wrappers for common QMP commands.
+package qmp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// QueryStatus returns the run state of the guest.
+func (c *Client) QueryStatus(ctx context.Context) (*Status, error) {
+	raw, err := c.Execute(ctx, "query-status", nil)
+	if err != nil {
+		return nil, err
+	}
+	var status Status
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return nil, fmt.Errorf("invalid query-status reply: %w", err)
+	}
+	return &status, nil
+}
+
+// Stop pauses guest execution.
+func (c *Client) Stop(ctx context.Context) error {
+	_, err := c.Execute(ctx, "stop", nil)
+	return err
+}
+
+// Cont resumes guest execution.
+func (c *Client) Cont(ctx context.Context) error {
+	_, err := c.Execute(ctx, "cont", nil)
+	return err
+}
+
+// SystemReset resets the guest like a hardware reset button.
+func (c *Client) SystemReset(ctx context.Context) error {
+	_, err := c.Execute(ctx, "system_reset", nil)
+	return err
+}
+
+// SystemPowerdown requests an ACPI-style graceful shutdown of the guest.
+func (c *Client) SystemPowerdown(ctx context.Context) error {
+	_, err := c.Execute(ctx, "system_powerdown", nil)
+	return err
+}
+
+// Quit terminates QEMU immediately.
+func (c *Client) Quit(ctx context.Context) error {
+	_, err := c.Execute(ctx, "quit", nil)
+	return err
+}
+
+// HumanMonitorCommand runs an HMP command and returns its text output.
+// It is used for commands without a stable QMP equivalent, such as savevm.
+func (c *Client) HumanMonitorCommand(ctx context.Context, cmdline string) (string, error) {
+	raw, err := c.Execute(ctx, "human-monitor-command", map[string]string{"command-line": cmdline})
+	if err != nil {
+		return "", err
+	}
+	var out string
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return "", fmt.Errorf("invalid human-monitor-command reply: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,67 @@
+// Package qmp provides a client for the QEMU Machine Protocol.
+// This file contains type definitions for the qmp package.
+package qmp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Error is an error reply from QEMU.
+type Error struct {
+	Class string `json:"class"`
+	Desc  string `json:"desc"`
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("qmp: %s: %s", e.Class, e.Desc)
+}
+
+// Status is the reply to query-status.
+type Status struct {
+	Running    bool   `json:"running"`
+	Singlestep bool   `json:"singlestep"`
+	Status     string `json:"status"` // e.g. "running", "paused", "shutdown"
+}
+
+// Version is the QEMU version reported in the QMP greeting.
+type Version struct {
+	QEMU struct {
+		Major int `json:"major"`
+		Minor int `json:"minor"`
+		Micro int `json:"micro"`
+	} `json:"qemu"`
+	Package string `json:"package"`
+}
+
+// String returns the version as "major.minor.micro".
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.QEMU.Major, v.QEMU.Minor, v.QEMU.Micro)
+}
+
+// Event is an asynchronous event sent by QEMU.
+type Event struct {
+	Event     string          `json:"event"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Timestamp struct {
+		Seconds      int64 `json:"seconds"`
+		Microseconds int64 `json:"microseconds"`
+	} `json:"timestamp"`
+}
+
+// command is a QMP request.
+type command struct {
+	Execute   string      `json:"execute"`
+	Arguments interface{} `json:"arguments,omitempty"`
+}
+
+// message is any line QEMU sends: a greeting, a reply or an event.
+type message struct {
+	QMP *struct {
+		Version Version `json:"version"`
+	} `json:"QMP,omitempty"`
+	Return json.RawMessage `json:"return,omitempty"`
+	Error  *Error          `json:"error,omitempty"`
+	Event  string          `json:"event,omitempty"`
+}
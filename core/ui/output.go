@@ -0,0 +1,76 @@
+// Package ui provides console output helpers for elmos.
+// This file contains machine-readable (JSON/YAML) output support.
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OutputFormat selects how commands render their results.
+type OutputFormat string
+
+// Supported output formats.
+const (
+	OutputText OutputFormat = "text"
+	OutputJSON OutputFormat = "json"
+	OutputYAML OutputFormat = "yaml"
+)
+
+// OutputFormats lists the valid values for --output.
+var OutputFormats = []OutputFormat{OutputText, OutputJSON, OutputYAML}
+
+// ParseOutputFormat validates an --output value. An empty value means text.
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	if s == "" {
+		return OutputText, nil
+	}
+	for _, f := range OutputFormats {
+		if strings.EqualFold(s, string(f)) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("invalid output format %q (valid: text, json, yaml)", s)
+}
+
+// SetFormat selects the output format.
+func (p *Printer) SetFormat(f OutputFormat) {
+	p.format = f
+}
+
+// Format returns the selected output format.
+func (p *Printer) Format() OutputFormat {
+	if p.format == "" {
+		return OutputText
+	}
+	return p.format
+}
+
+// Structured reports whether results should be emitted as JSON or YAML
+// instead of human-readable text.
+func (p *Printer) Structured() bool {
+	return p.Format() != OutputText
+}
+
+// Emit writes v to stdout as a JSON or YAML document.
+func (p *Printer) Emit(v interface{}) error {
+	switch p.Format() {
+	case OutputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case OutputYAML:
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return fmt.Errorf("output format %s is not structured", p.Format())
+	}
+}
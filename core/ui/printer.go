@@ -7,7 +7,9 @@ import (
 )
 
 // Printer provides formatted console output.
-type Printer struct{}
+type Printer struct {
+	format OutputFormat
+}
 
 // NewPrinter creates a new Printer.
 func NewPrinter() *Printer {
@@ -16,7 +18,7 @@ func NewPrinter() *Printer {
 
 // Success prints a success message with a checkmark.
 func (p *Printer) Success(format string, args ...interface{}) {
-	fmt.Println(SuccessStyle.Render(fmt.Sprintf("✓ "+format, args...)))
+	fmt.Fprintln(p.out(), SuccessStyle.Render(fmt.Sprintf("✓ "+format, args...)))
 }
 
 // Error prints an error message with an X.
@@ -26,26 +28,36 @@ func (p *Printer) Error(format string, args ...interface{}) {
 
 // Warn prints a warning message with a warning sign.
 func (p *Printer) Warn(format string, args ...interface{}) {
-	fmt.Println(WarnStyle.Render(fmt.Sprintf("⚠ "+format, args...)))
+	fmt.Fprintln(p.out(), WarnStyle.Render(fmt.Sprintf("⚠ "+format, args...)))
 }
 
 // Info prints an info message with an info sign.
 func (p *Printer) Info(format string, args ...interface{}) {
-	fmt.Println(InfoStyle.Render(fmt.Sprintf("ℹ "+format, args...)))
+	fmt.Fprintln(p.out(), InfoStyle.Render(fmt.Sprintf("ℹ "+format, args...)))
 }
 
 // Step prints a step message with an arrow.
 func (p *Printer) Step(format string, args ...interface{}) {
-	fmt.Println(AccentStyle.Render(fmt.Sprintf("→ "+format, args...)))
+	fmt.Fprintln(p.out(), AccentStyle.Render(fmt.Sprintf("→ "+format, args...)))
 }
 
 // Print prints a plain message.
 func (p *Printer) Print(format string, args ...interface{}) {
-	fmt.Printf(format+"\n", args...)
+	fmt.Fprintf(p.out(), format+"\n", args...)
 }
 
-// Writer returns an io.Writer that writes to stdout.
+// Writer returns the file human-readable output is written to.
+// This is stdout, or stderr when a structured output format is selected.
 func (p *Printer) Writer() *os.File {
+	return p.out()
+}
+
+// out returns where human-readable messages go, keeping stdout clean for
+// JSON and YAML documents.
+func (p *Printer) out() *os.File {
+	if p.Structured() {
+		return os.Stderr
+	}
 	return os.Stdout
 }
 
@@ -5,6 +5,7 @@ package tui
 import (
 	"io"
 	"os"
+	"time"
 
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
@@ -50,7 +51,8 @@ func buildMenuStructure() []MenuItem {
 		}},
 		{Label: "Arch", Desc: "Set target architecture", Children: []MenuItem{
 			{Label: "Show", Desc: "Show current config", Action: "arch:show", Command: "elmos arch show", Args: []string{"arch", "show"}},
-			{Label: "Set", Desc: "Set architecture", Action: "arch:set", Command: "elmos arch <target>", NeedsInput: true, InputPrompt: "Architecture (arm64/arm/riscv):", InputPlaceholder: "arm64"},
+			{Label: "List", Desc: "List available architectures", Action: "arch:list", Command: "elmos arch list", Args: []string{"arch", "list"}},
+			{Label: "Set", Desc: "Set architecture", Action: "arch:set", Command: "elmos arch <target>", NeedsInput: true, InputPrompt: "Architecture (see 'elmos arch list'):", InputPlaceholder: "arm64"},
 		}},
 		{Label: "Kernel", Desc: "Configure and build Linux kernel", Children: []MenuItem{
 			{Label: "Status", Desc: "Show kernel status", Action: "kernel:status", Command: "elmos kernel status", Args: []string{"kernel", "status"}},
@@ -74,6 +76,10 @@ func buildMenuStructure() []MenuItem {
 			{Label: "New", Desc: "Create app", Action: "app:new", Command: "elmos app new <name>", NeedsInput: true, InputPrompt: "App name:", InputPlaceholder: "hello_app"},
 			{Label: "Clean", Desc: "Remove binaries", Action: "app:clean", Command: "elmos app clean", Args: []string{"app", "clean"}},
 		}},
+		{Label: "Watch", Desc: "Rebuild on source change", Children: []MenuItem{
+			{Label: "Start", Desc: "Watch modules & apps", Action: "watch:start", Command: "elmos watch", Interactive: true, Args: []string{"watch"}},
+			{Label: "Status", Desc: "Show last build results", Action: "watch:status", Command: "elmos watch status", Args: []string{"watch", "status"}},
+		}},
 		{Label: "QEMU", Desc: "Run kernel in emulator", Children: []MenuItem{
 			{Label: "Run", Desc: "Boot kernel", Action: "qemu:run", Command: "elmos qemu run", Interactive: true, Args: []string{"qemu", "run"}},
 			{Label: "Debug", Desc: "With GDB server", Action: "qemu:debug", Command: "elmos qemu debug", Interactive: true, Args: []string{"qemu", "debug"}},
@@ -101,9 +107,20 @@ func buildMenuStructure() []MenuItem {
 // CommandRunner is a function type for running commands.
 type CommandRunner func(action string, output io.Writer) error
 
-// Run starts the TUI application.
-func Run() error {
+// StatusFunc returns a one-line status to show in the footer, or "" for none.
+type StatusFunc func() string
+
+// statusInterval is how often the footer status line is refreshed.
+const statusInterval = time.Second
+
+// Run starts the TUI application. status, if not nil, provides a live
+// status line such as the state of 'elmos watch'.
+func Run(status StatusFunc) error {
 	m := NewModel()
+	m.statusFn = status
+	if status != nil {
+		m.statusLine = status()
+	}
 	p := tea.NewProgram(m, tea.WithAltScreen())
 	_, err := p.Run()
 	return err
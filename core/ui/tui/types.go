@@ -36,6 +36,10 @@ type Model struct {
 	quitting              bool
 	execPath              string
 
+	// Live status shown in the footer, refreshed every statusInterval
+	statusFn   StatusFunc
+	statusLine string
+
 	// Text input state
 	textInput   textinput.Model
 	inputMode   bool
@@ -50,6 +54,9 @@ type CommandDoneMsg struct {
 	Output string
 }
 
+// statusTickMsg triggers a refresh of the footer status line.
+type statusTickMsg struct{}
+
 // keyMap defines keyboard shortcuts for the TUI.
 type keyMap struct {
 	Up, Down, Enter, Back, Quit, Clear     key.Binding
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
@@ -24,13 +25,27 @@ func (m *Model) refreshViewport() {
 
 // Init implements tea.Model.
 func (m Model) Init() tea.Cmd {
+	if m.statusFn != nil {
+		return tea.Batch(m.spinner.Tick, statusTick())
+	}
 	return m.spinner.Tick
 }
 
+// statusTick schedules the next footer status refresh.
+func statusTick() tea.Cmd {
+	return tea.Tick(statusInterval, func(time.Time) tea.Msg { return statusTickMsg{} })
+}
+
 // Update implements tea.Model and handles all input events.
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 
+	// Handled first so that input mode does not stop the refresh
+	if _, ok := msg.(statusTickMsg); ok {
+		m.statusLine = m.statusFn()
+		return m, statusTick()
+	}
+
 	if m.inputMode {
 		return m.handleInputMode(msg)
 	}
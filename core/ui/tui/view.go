@@ -132,5 +132,14 @@ func (m Model) renderFooter() string {
 			lipgloss.NewStyle().Foreground(cyan).Render("Esc") + " Back  " +
 			lipgloss.NewStyle().Foreground(cyan).Render("[ ]") + " Scroll  " +
 			lipgloss.NewStyle().Foreground(cyan).Render("c") + " Clear  " +
-			lipgloss.NewStyle().Foreground(cyan).Render("q") + " Quit")
+			lipgloss.NewStyle().Foreground(cyan).Render("q") + " Quit" +
+			m.renderStatusLine())
+}
+
+// renderStatusLine renders the live status after the keybindings, if any.
+func (m Model) renderStatusLine() string {
+	if m.statusLine == "" {
+		return ""
+	}
+	return "  │  " + lipgloss.NewStyle().Foreground(orange).Render(m.statusLine)
 }